@@ -11,6 +11,7 @@ import (
 	imagecreation "github.com/iantozer/stitch-up/pkg/3_imagecreation"
 	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/transport"
 )
 
 func main() {
@@ -26,6 +27,11 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Apply the configured rate limits to every client of the provider
+	for provider, limit := range cfg.RateLimits {
+		transport.SetLimit(provider, limit)
+	}
+
 	// Override output directory from command-line flag
 	cfg.ImageCreation.OutputDir = *outputDir
 
@@ -38,7 +44,10 @@ func main() {
 	log.Printf("Using Hugging Face model: %s", cfg.ImageCreation.HuggingFaceModel)
 
 	// Create image creator
-	creator := imagecreation.New(cfg.ImageCreation)
+	creator, err := imagecreation.Backends.New(cfg.ImageCreation.Backend, cfg.ImageCreation, cfg.MockFallback)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Load scenes from file
 	scenes, err := loadScenesFromFile(*scenesPath)
@@ -9,12 +9,15 @@ import (
 	scenegeneration "github.com/iantozer/stitch-up/pkg/2_scenegeneration"
 	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/transport"
 )
 
 func main() {
 	// Parse command-line flags
 	outputPath := flag.String("output", "output/scenes.json", "Path to save the generated scenes")
 	maxScenes := flag.Int("max-scenes", 10, "Maximum number of scenes to generate")
+	inputDir := flag.String("input-dir", "", "Directory of headline screenshots (overrides config)")
+	concurrency := flag.Int("concurrency", 0, "Number of scenes to generate in parallel (overrides config)")
 	flag.Parse()
 
 	// Load configuration
@@ -23,11 +26,29 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Apply the configured rate limits to every client of the provider
+	for provider, limit := range cfg.RateLimits {
+		transport.SetLimit(provider, limit)
+	}
+
 	// Override max scenes from command-line flag
 	cfg.SceneGeneration.MaxScenes = *maxScenes
 
+	// Override input directory from command-line flag
+	if *inputDir != "" {
+		cfg.SceneGeneration.InputDir = *inputDir
+	}
+
+	// Override concurrency from command-line flag
+	if *concurrency > 0 {
+		cfg.SceneGeneration.Concurrency = *concurrency
+	}
+
 	// Create scene generator
-	generator := scenegeneration.New(cfg.SceneGeneration)
+	generator, err := scenegeneration.Backends.New(cfg.SceneGeneration.Backend, cfg.SceneGeneration, cfg.MockFallback)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
@@ -2,24 +2,47 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 
-	// contentextraction "github.com/iantozer/stitch-up/pkg/1_contentextraction"
+	contentextraction "github.com/iantozer/stitch-up/pkg/1_contentextraction"
 	scenegeneration "github.com/iantozer/stitch-up/pkg/2_scenegeneration"
 	imagecreation "github.com/iantozer/stitch-up/pkg/3_imagecreation"
 	videoconversion "github.com/iantozer/stitch-up/pkg/4_videoconversion"
 	lyriccreation "github.com/iantozer/stitch-up/pkg/5_lyriccreation"
 	musicgeneration "github.com/iantozer/stitch-up/pkg/6_musicgeneration"
 	assembly "github.com/iantozer/stitch-up/pkg/7_assembly"
-	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/registry"
+	"github.com/iantozer/stitch-up/pkg/transport"
 )
 
 func main() {
-	// Initialize context
-	ctx := context.Background()
+	// Parse command line flags
+	listBackends := flag.Bool("list-backends", false, "List the backends available to each stage and exit")
+	flag.Parse()
+
+	if *listBackends {
+		if err := registry.Write(os.Stdout,
+			contentextraction.Backends,
+			scenegeneration.Backends,
+			imagecreation.Backends,
+			videoconversion.Backends,
+			lyriccreation.Backends,
+			musicgeneration.Backends,
+			assembly.Backends,
+		); err != nil {
+			log.Fatalf("Failed to list backends: %v", err)
+		}
+		return
+	}
+
+	// Initialize context, cancelled on Ctrl-C so paid jobs in flight are cancelled too
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	// Load configuration
 	cfg, err := config.Load()
@@ -27,25 +50,47 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Initialize modules
-	// contentExtractor := contentextraction.New(cfg.ContentExtraction)
-	sceneGenerator := scenegeneration.New(cfg.SceneGeneration)
-	imageCreator := imagecreation.New(cfg.ImageCreation)
-	videoConverter := videoconversion.New(cfg.VideoConversion)
-	lyricCreator := lyriccreation.New(cfg.LyricCreation)
-	musicGenerator := musicgeneration.New(cfg.MusicGeneration)
-	assembler := assembly.New(cfg.Assembly)
+	// Apply the configured rate limits to every client of the provider
+	for provider, limit := range cfg.RateLimits {
+		transport.SetLimit(provider, limit)
+	}
+
+	// Initialize modules with the configured backends, so a missing key stops the run before
+	// anything is paid for
+	contentExtractor, err := contentextraction.Backends.New(cfg.ContentExtraction.Backend, cfg.ContentExtraction, cfg.MockFallback)
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+	sceneGenerator, err := scenegeneration.Backends.New(cfg.SceneGeneration.Backend, cfg.SceneGeneration, cfg.MockFallback)
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+	imageCreator, err := imagecreation.Backends.New(cfg.ImageCreation.Backend, cfg.ImageCreation, cfg.MockFallback)
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+	videoConverter, err := videoconversion.Backends.New(cfg.VideoConversion.Backend, cfg.VideoConversion, cfg.MockFallback)
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+	lyricCreator, err := lyriccreation.Backends.New(cfg.LyricCreation.Backend, cfg.LyricCreation, cfg.MockFallback)
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+	// The music generator is created once the videos' length is known; check its backend now
+	if _, err := musicgeneration.Backends.New(cfg.MusicGeneration.Backend, cfg.MusicGeneration, cfg.MockFallback); err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+	assembler, err := assembly.Backends.New(cfg.Assembly.Backend, cfg.Assembly, cfg.MockFallback)
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
 
 	// Extract content
 	fmt.Println("Step 1: Extracting content...")
-	// content, err := contentExtractor.Extract(ctx)
-	// if err != nil {
-	// 	log.Fatalf("Content extraction failed: %v", err)
-	// }
-
-	content := common.Content{
-		Title: "Test Content",
-		// Body:  "This is a test content body",
+	content, err := contentExtractor.Extract(ctx)
+	if err != nil {
+		log.Fatalf("Content extraction failed: %v", err)
 	}
 
 	// Generate scene descriptions
@@ -76,8 +121,17 @@ func main() {
 		log.Fatalf("Lyric creation failed: %v", err)
 	}
 
-	// Generate music from lyrics
+	// Generate music from lyrics, as long as the videos unless a duration is configured
 	fmt.Println("Step 6: Generating music...")
+	if cfg.MusicGeneration.Duration == 0 {
+		for _, video := range videos {
+			cfg.MusicGeneration.Duration += video.Length
+		}
+	}
+	musicGenerator, err := musicgeneration.Backends.New(cfg.MusicGeneration.Backend, cfg.MusicGeneration, cfg.MockFallback)
+	if err != nil {
+		log.Fatalf("Music generation failed: %v", err)
+	}
 	music, err := musicGenerator.Generate(ctx, lyrics)
 	if err != nil {
 		log.Fatalf("Music generation failed: %v", err)
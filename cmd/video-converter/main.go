@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 
 	videoconversion "github.com/iantozer/stitch-up/pkg/4_videoconversion"
 	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/registry"
+	"github.com/iantozer/stitch-up/pkg/transport"
 	"github.com/joho/godotenv"
 )
 
@@ -23,25 +26,70 @@ func main() {
 	inputDir := flag.String("input-dir", "output/images", "Directory containing input images")
 	outputDir := flag.String("output-dir", "output/videos", "Directory for output videos")
 	videoLength := flag.Int("video-length", 10, "Length of generated videos in seconds")
-	runwayAPIKey := flag.String("runway-api-key", os.Getenv("RUNWAY_API_KEY"), "Runway ML API key")
-	useNode := flag.Bool("use-node", true, "Use Node.js implementation")
+	runwayAPIKey := flag.String("runway-api-key", "", "Runway ML API key (default: RUNWAY_API_KEY)")
+	backend := flag.String("backend", "", "Video backend: runway, replicate, local, node or mock (default: VIDEO_BACKEND, or node with --use-node)")
+	replicateToken := flag.String("replicate-api-token", "", "Replicate API token (default: REPLICATE_API_TOKEN)")
+	useNode := flag.Bool("use-node", true, "Use Node.js implementation unless another backend is configured (the same as --backend node)")
+	motion := flag.String("motion", "", "Camera motion for clips rendered locally: zoom-in, zoom-out, pan-left, pan-right, pan-up or pan-down (default: one per scene)")
+	mockFallback := flag.Bool("mock-fallback", false, "Use a local or mock backend when the selected one cannot run (default: MOCK_FALLBACK)")
+	listBackends := flag.Bool("list-backends", false, "List the video backends and exit")
 	flag.Parse()
 
-	// Validate input
-	if *runwayAPIKey == "" {
-		log.Fatal("No Runway ML API key provided. Set RUNWAY_API_KEY in .env file or use --runway-api-key flag.")
+	if *listBackends {
+		if err := registry.Write(os.Stdout, videoconversion.Backends); err != nil {
+			log.Fatalf("Failed to list backends: %v", err)
+		}
+		return
+	}
+
+	// Load configuration, so the defaults and settings of the full pipeline apply here too
+	loaded, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Apply the configured rate limits to every client of the provider
+	for provider, limit := range loaded.RateLimits {
+		transport.SetLimit(provider, limit)
+	}
+
+	// Override the configuration with the flags that were given
+	cfg := loaded.VideoConversion
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["output-dir"] {
+		cfg.OutputDir = *outputDir
+	}
+	if set["video-length"] {
+		cfg.VideoLength = *videoLength
+	}
+	if set["runway-api-key"] {
+		cfg.RunwayAPIKey = *runwayAPIKey
+	}
+	if set["replicate-api-token"] {
+		cfg.ReplicateAPIToken = *replicateToken
+	}
+	if set["backend"] {
+		cfg.Backend = *backend
+	}
+	if set["motion"] {
+		cfg.Motion = *motion
+	}
+	if set["mock-fallback"] {
+		loaded.MockFallback = *mockFallback
 	}
 
-	// Create configuration
-	cfg := config.VideoConversionConfig{
-		RunwayAPIKey:          *runwayAPIKey,
-		OutputDir:             *outputDir,
-		VideoLength:           *videoLength,
-		UseNodeImplementation: *useNode,
+	// The Node.js scripts stay the default of this command unless another backend is named
+	if cfg.Backend == "" && *useNode {
+		log.Println("Using the Node.js scripts; pass --backend or --use-node=false to use another backend")
+		cfg.Backend = videoconversion.BackendNode
 	}
 
 	// Create video converter
-	converter := videoconversion.New(cfg)
+	converter, err := videoconversion.Backends.New(cfg.Backend, cfg, loaded.MockFallback)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Get images from input directory
 	images, err := getImagesFromDirectory(*inputDir)
@@ -56,7 +104,9 @@ func main() {
 	log.Printf("Found %d images to convert", len(images))
 
 	// Convert images to videos
-	videos, err := converter.Convert(context.Background(), images)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	videos, err := converter.Convert(ctx, images)
 	if err != nil {
 		log.Fatalf("Failed to convert images to videos: %v", err)
 	}
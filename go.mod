@@ -4,4 +4,4 @@ go 1.22.5
 
 require github.com/google/uuid v1.6.0
 
-require github.com/joho/godotenv v1.5.1 // indirect
+require github.com/joho/godotenv v1.5.1
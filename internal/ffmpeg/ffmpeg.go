@@ -0,0 +1,64 @@
+/*
+Package ffmpeg runs ffmpeg for the stages of the Stitch-Up pipeline that render video
+locally: the Ken Burns video converter and the assembler.
+*/
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// Defaults used when a stage's config leaves the ffmpeg path or video format empty
+const (
+	DefaultPath   = "ffmpeg"
+	DefaultWidth  = 1280
+	DefaultHeight = 720
+	DefaultFPS    = 30
+)
+
+// maxErrorOutput is how much of ffmpeg's output is kept in an error
+const maxErrorOutput = 2000
+
+// Find returns the full path of the ffmpeg binary at path, or on the PATH for an empty path
+func Find(path string) (string, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+	found, err := exec.LookPath(path)
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg not found: %w", err)
+	}
+	return found, nil
+}
+
+// Run runs ffmpeg, including the end of its output in any error
+func Run(ctx context.Context, ffmpegPath string, args ...string) error {
+	_, err := Output(ctx, ffmpegPath, append([]string{"-loglevel", "error"}, args...)...)
+	return err
+}
+
+// Output runs ffmpeg and returns what it printed, including the end of it in any error
+func Output(ctx context.Context, ffmpegPath string, args ...string) (string, error) {
+	args = append([]string{"-hide_banner", "-nostdin"}, args...)
+	log.Printf("Running %s %s", ffmpegPath, strings.Join(args, " "))
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		text := strings.TrimSpace(output.String())
+		if len(text) > maxErrorOutput {
+			text = "..." + text[len(text)-maxErrorOutput:]
+		}
+		return output.String(), fmt.Errorf("ffmpeg failed: %w: %s", err, text)
+	}
+
+	return output.String(), nil
+}
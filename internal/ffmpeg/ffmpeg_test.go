@@ -0,0 +1,49 @@
+package ffmpeg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFFmpeg writes a script that prints its arguments and a long log, then fails
+func fakeFFmpeg(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\necho \"$@\"\nhead -c 5000 /dev/zero | tr '\\0' x >&2\necho ' the end' >&2\nexit 1\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun_Error(t *testing.T) {
+	err := Run(context.Background(), fakeFFmpeg(t), "-i", "input.mp4")
+	if err == nil {
+		t.Fatal("Run() of a failing ffmpeg returned no error")
+	}
+
+	// Only the end of the output is kept
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "ffmpeg failed: exit status 1: ...") || !strings.HasSuffix(msg, "the end") {
+		t.Errorf("Run() error = %.100q..., want the end of the output", msg)
+	}
+	if len(msg) > maxErrorOutput+100 {
+		t.Errorf("Run() error is %d bytes, want it cut to about %d", len(msg), maxErrorOutput)
+	}
+}
+
+func TestOutput_Args(t *testing.T) {
+	out, _ := Output(context.Background(), fakeFFmpeg(t), "-i", "input.mp4")
+	if !strings.HasPrefix(out, "-hide_banner -nostdin -i input.mp4\n") {
+		t.Errorf("Output() = %.100q, want the common flags before the arguments", out)
+	}
+}
+
+func TestFind_Missing(t *testing.T) {
+	if _, err := Find(filepath.Join(t.TempDir(), "ffmpeg")); err == nil || !strings.Contains(err.Error(), "ffmpeg not found") {
+		t.Errorf("Find() error = %v, want ffmpeg not found", err)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Package workpool runs the independent requests of a pipeline stage, such as one
+scene or video per input, on a bounded pool of workers.
+*/
+package workpool
+
+import (
+	"context"
+	"sync"
+)
+
+// ForEach runs fn for 0..n-1 on at most workers goroutines, at least one, and returns once
+// every call has finished. No more calls are started once the context is cancelled.
+func ForEach(ctx context.Context, workers, n int, fn func(ctx context.Context, i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if ctx.Err() != nil {
+					continue
+				}
+				fn(ctx, i)
+			}
+		}()
+	}
+
+	// Stop handing out work as soon as the context is cancelled
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+}
@@ -0,0 +1,56 @@
+package workpool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEach(t *testing.T) {
+	var (
+		mu             sync.Mutex
+		inFlight, peak int
+		done           = make([]bool, 10)
+	)
+	ForEach(context.Background(), 3, len(done), func(ctx context.Context, i int) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		done[i] = true
+		mu.Unlock()
+	})
+
+	for i, ok := range done {
+		if !ok {
+			t.Errorf("input %d was not run", i)
+		}
+	}
+	if peak > 3 {
+		t.Errorf("%d calls at once, want at most 3", peak)
+	}
+}
+
+func TestForEach_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	calls := 0
+	ForEach(ctx, 1, 100, func(ctx context.Context, i int) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		cancel()
+	})
+
+	if calls > 2 {
+		t.Errorf("made %d calls after cancellation", calls)
+	}
+}
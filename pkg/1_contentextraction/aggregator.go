@@ -0,0 +1,288 @@
+package contentextraction
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+// defaultSimilarityThreshold is used when no similarity threshold is configured
+const defaultSimilarityThreshold = 0.5
+
+// minSharedWords is how many significant words two different articles must share to be one story
+const minSharedWords = 3
+
+// stopWords are common words ignored when comparing stories
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "has": true, "have": true, "in": true, "is": true,
+	"it": true, "its": true, "of": true, "on": true, "or": true, "over": true, "says": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true, "were": true, "will": true,
+	"with": true, "after": true, "amid": true, "new": true, "more": true, "than": true,
+}
+
+// namedExtractor is a configured source together with the name of its outlet
+type namedExtractor struct {
+	name      string
+	extractor common.ContentExtractor
+}
+
+// story is a cluster of articles from different sources that report the same news
+type story struct {
+	articles []common.Article
+	sources  []string
+	tokens   []map[string]bool
+	first    int
+}
+
+// Aggregator implements the ContentExtractor interface by merging several sources into ranked stories
+type Aggregator struct {
+	config  config.ContentExtractionConfig
+	sources []namedExtractor
+}
+
+// NewAggregator creates a content extractor for every entry in config.Sources
+func NewAggregator(config config.ContentExtractionConfig) *Aggregator {
+	aggregator := &Aggregator{
+		config: config,
+	}
+
+	for _, source := range config.Sources {
+		// Each source gets its own copy of the config describing just that source
+		sub := config
+		sub.Sources = nil
+		sub.Source = ""
+		sub.Feeds = nil
+		sub.PageDir = ""
+		sub.ScreenshotDir = ""
+		sub.FetchArticles = source.FetchArticles
+		sub.MaxArticles = 0
+
+		var extractor common.ContentExtractor
+		switch source.Type {
+		case "", "feed":
+			sub.Feeds = []string{source.Location}
+			extractor = New(sub)
+		case "pages":
+			sub.PageDir = source.Location
+			extractor = NewPageExtractor(sub)
+		case "screenshots":
+			sub.ScreenshotDir = source.Location
+			extractor = NewScreenshotExtractor(sub)
+		default:
+			log.Printf("Warning: Ignoring source %q with unknown type %q", source.Location, source.Type)
+			continue
+		}
+
+		name := source.Name
+		if name == "" {
+			name = sourceName(source.Location)
+		}
+
+		aggregator.sources = append(aggregator.sources, namedExtractor{
+			name:      name,
+			extractor: extractor,
+		})
+	}
+
+	return aggregator
+}
+
+// Extract reads every source, clusters duplicate stories and returns them ranked by coverage
+func (a *Aggregator) Extract(ctx context.Context) (common.Content, error) {
+	if len(a.sources) == 0 {
+		return common.Content{}, fmt.Errorf("no content sources configured")
+	}
+
+	log.Printf("Aggregating content from %d source(s)", len(a.sources))
+
+	var (
+		articles []common.Article
+		names    []string
+	)
+	for _, source := range a.sources {
+		content, err := source.extractor.Extract(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return common.Content{}, ctx.Err()
+			}
+			log.Printf("Warning: Failed to extract from source %s: %v", source.name, err)
+			continue
+		}
+
+		for _, article := range content.Articles {
+			article.Sources = []string{source.name}
+			articles = append(articles, article)
+		}
+		names = append(names, source.name)
+	}
+
+	if len(articles) == 0 {
+		return common.Content{}, fmt.Errorf("no articles found in %d source(s)", len(a.sources))
+	}
+
+	threshold := a.config.SimilarityThreshold
+	if threshold <= 0 {
+		threshold = defaultSimilarityThreshold
+	}
+
+	stories := clusterArticles(articles, threshold)
+	rankStories(stories)
+
+	// Keep only the most widely covered stories
+	if a.config.TopStories > 0 && len(stories) > a.config.TopStories {
+		stories = stories[:a.config.TopStories]
+	}
+
+	content := common.Content{
+		Title:       fmt.Sprintf("Top stories from %d sources", len(names)),
+		Description: "Stories covered by " + strings.Join(names, ", "),
+		Date:        time.Now().Format("January 2, 2006"),
+	}
+	for _, s := range stories {
+		content.Articles = append(content.Articles, s.merge())
+	}
+
+	log.Printf("Aggregated %d articles into %d stories", len(articles), len(content.Articles))
+	return content, nil
+}
+
+// clusterArticles groups near-duplicate articles into stories, preserving first-seen order
+func clusterArticles(articles []common.Article, threshold float64) []*story {
+	var stories []*story
+
+	for i, article := range articles {
+		tokens := storyTokens(article)
+
+		// Join the story containing the most similar article
+		var best *story
+		bestScore := 0.0
+		for _, s := range stories {
+			for _, other := range s.tokens {
+				if score := similarity(tokens, other); score > bestScore {
+					best, bestScore = s, score
+				}
+			}
+		}
+
+		if best == nil || bestScore < threshold {
+			best = &story{first: i}
+			stories = append(stories, best)
+		}
+
+		best.articles = append(best.articles, article)
+		best.tokens = append(best.tokens, tokens)
+		for _, name := range article.Sources {
+			if !containsString(best.sources, name) {
+				best.sources = append(best.sources, name)
+			}
+		}
+	}
+
+	return stories
+}
+
+// rankStories orders stories by how many sources covered them, then by how early they appeared
+func rankStories(stories []*story) {
+	sort.SliceStable(stories, func(i, j int) bool {
+		if len(stories[i].sources) != len(stories[j].sources) {
+			return len(stories[i].sources) > len(stories[j].sources)
+		}
+		if len(stories[i].articles) != len(stories[j].articles) {
+			return len(stories[i].articles) > len(stories[j].articles)
+		}
+		return stories[i].first < stories[j].first
+	})
+}
+
+// merge combines a story's articles into one, starting from the most complete article
+func (s *story) merge() common.Article {
+	best := 0
+	for i, article := range s.articles {
+		if len(article.Content)+len(article.Summary) > len(s.articles[best].Content)+len(s.articles[best].Summary) {
+			best = i
+		}
+	}
+
+	merged := s.articles[best]
+	for _, article := range s.articles {
+		merged.Summary = firstNonEmpty(merged.Summary, article.Summary)
+		merged.Content = firstNonEmpty(merged.Content, article.Content)
+		merged.URL = firstNonEmpty(merged.URL, article.URL)
+		merged.Section = firstNonEmpty(merged.Section, article.Section)
+		merged.Byline = firstNonEmpty(merged.Byline, article.Byline)
+		merged.PublishedAt = firstNonEmpty(merged.PublishedAt, article.PublishedAt)
+	}
+	merged.Sources = append([]string(nil), s.sources...)
+
+	return merged
+}
+
+// storyTokens returns the significant words of an article's title and summary
+func storyTokens(article common.Article) map[string]bool {
+	tokens := map[string]bool{}
+	words := strings.FieldsFunc(strings.ToLower(article.Title+" "+article.Summary), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '%'
+	})
+
+	for _, word := range words {
+		word = strings.Trim(word, ".")
+		if word == "" || stopWords[word] {
+			continue
+		}
+		// Crude plural folding so "rate" and "rates" match
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = strings.TrimSuffix(word, "s")
+		}
+		tokens[word] = true
+	}
+
+	return tokens
+}
+
+// similarity returns the overlap coefficient of two token sets. Sets sharing fewer than
+// minSharedWords words score 0 unless they are the same, so a short headline is not matched to
+// every longer one that happens to contain its words.
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for token := range a {
+		if b[token] {
+			shared++
+		}
+	}
+
+	if shared < minSharedWords && (shared != len(a) || shared != len(b)) {
+		return 0
+	}
+	return float64(shared) / float64(min(len(a), len(b)))
+}
+
+// sourceName derives an outlet name from a source location
+func sourceName(location string) string {
+	if u, err := url.Parse(location); err == nil && u.Host != "" {
+		return strings.TrimPrefix(u.Host, "www.")
+	}
+	return location
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package contentextraction
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+// writeFeed writes an RSS feed with the given title/description pairs and returns its path
+func writeFeed(t *testing.T, dir, name string, items ...[2]string) string {
+	t.Helper()
+
+	var sb strings.Builder
+	sb.WriteString(`<rss version="2.0"><channel><title>` + name + `</title>`)
+	for i, item := range items {
+		fmt.Fprintf(&sb, `<item><title>%s</title><description>%s</description><link>https://%s/%d</link></item>`, item[0], item[1], name, i)
+	}
+	sb.WriteString(`</channel></rss>`)
+
+	path := filepath.Join(dir, name+".xml")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatalf("Failed to write feed: %v", err)
+	}
+	return path
+}
+
+func TestAggregator_Extract(t *testing.T) {
+	dir := t.TempDir()
+	bbc := writeFeed(t, dir, "bbc",
+		[2]string{"Local council approves new cycle lanes", "Work starts in the spring."},
+		[2]string{"Bank of England holds interest rates at 4.5%", "The decision was widely expected."},
+		[2]string{"Probe lands on distant moon", "Engineers celebrate."},
+	)
+	guardian := writeFeed(t, dir, "guardian",
+		[2]string{"Interest rates held at 4.5% by Bank of England", "Markets had expected the move."},
+		[2]string{"Space probe lands on moon", "A textbook landing for the mission."},
+	)
+	reuters := writeFeed(t, dir, "reuters",
+		[2]string{"Bank of England keeps rates on hold at 4.5%", ""},
+		[2]string{"Oil prices climb", "Brent crude rose 2%."},
+	)
+
+	cfg := config.ContentExtractionConfig{
+		Sources: []config.SourceConfig{
+			{Name: "BBC", Location: bbc},
+			{Name: "Guardian", Type: "feed", Location: guardian},
+			{Name: "Reuters", Location: reuters},
+			{Name: "Broken", Location: filepath.Join(dir, "missing.xml")},
+		},
+		TopStories: 3,
+	}
+	extractor := NewAggregator(cfg)
+
+	content, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	// Five distinct stories are capped to the top three
+	if len(content.Articles) != 3 {
+		t.Fatalf("got %d stories, want 3: %+v", len(content.Articles), content.Articles)
+	}
+
+	// The rates story was covered by all three outlets, the probe by two
+	top := content.Articles[0]
+	if !strings.Contains(top.Title, "Bank of England") || len(top.Sources) != 3 {
+		t.Errorf("top story = %q from %v", top.Title, top.Sources)
+	}
+	second := content.Articles[1]
+	if !strings.Contains(second.Title, "moon") || len(second.Sources) != 2 {
+		t.Errorf("second story = %q from %v", second.Title, second.Sources)
+	}
+
+	// Single-source stories keep their original order
+	if content.Articles[2].Title != "Local council approves new cycle lanes" {
+		t.Errorf("third story = %q", content.Articles[2].Title)
+	}
+}
+
+func TestClusterArticles(t *testing.T) {
+	articles := []common.Article{
+		{Title: "Ceasefire talks resume in Geneva", Sources: []string{"a"}},
+		{Title: "Geneva ceasefire talks resume after pause", Sources: []string{"b"}},
+		{Title: "Heavy snow expected across the north", Sources: []string{"b"}},
+	}
+
+	stories := clusterArticles(articles, defaultSimilarityThreshold)
+	if len(stories) != 2 {
+		t.Fatalf("got %d stories, want 2", len(stories))
+	}
+	if len(stories[0].articles) != 2 || len(stories[0].sources) != 2 {
+		t.Errorf("first story has %d articles from %v", len(stories[0].articles), stories[0].sources)
+	}
+
+	// A strict threshold keeps every article apart
+	if stories := clusterArticles(articles, 1.1); len(stories) != 3 {
+		t.Errorf("got %d stories with strict threshold, want 3", len(stories))
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool // whether the articles reach the default threshold
+	}{
+		{"Ceasefire talks resume in Geneva", "Geneva ceasefire talks resume after pause", true},
+		{"Bank holds rates", "Bank holds rates", true},
+		// A short headline is not one story with every longer one containing its words
+		{"Storm warning", "Storm warning lifted as farmers count the cost of flooding", false},
+		{"Minister resigns", "Minister resigns over housing scandal", false},
+	}
+
+	for _, tt := range tests {
+		score := similarity(storyTokens(common.Article{Title: tt.a}), storyTokens(common.Article{Title: tt.b}))
+		if got := score >= defaultSimilarityThreshold; got != tt.want {
+			t.Errorf("similarity(%q, %q) = %.2f, want same story = %v", tt.a, tt.b, score, tt.want)
+		}
+	}
+}
+
+func TestAggregator_Extract_NoSources(t *testing.T) {
+	aggregator := NewAggregator(config.ContentExtractionConfig{})
+
+	if _, err := aggregator.Extract(context.Background()); err == nil {
+		t.Error("Extract() with no sources should return error")
+	}
+}
@@ -0,0 +1,561 @@
+package contentextraction
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/transport"
+)
+
+// Page holds the main content recovered from an article's HTML
+type Page struct {
+	Headline    string
+	Byline      string
+	PublishedAt string
+	Paragraphs  []string
+}
+
+// Text returns the body paragraphs joined into a single block of text
+func (p Page) Text() string {
+	return strings.Join(p.Paragraphs, "\n\n")
+}
+
+// ArticleExtractor wraps another ContentExtractor and fills in each article's full text
+type ArticleExtractor struct {
+	source common.ContentExtractor
+	client *http.Client
+}
+
+// NewArticleExtractor creates an extractor that fetches the page behind every article from source
+func NewArticleExtractor(source common.ContentExtractor) *ArticleExtractor {
+	return &ArticleExtractor{
+		source: source,
+		client: transport.NewClient("", 30*time.Second),
+	}
+}
+
+// Extract extracts content from the wrapped source and enriches each article with its page text
+func (e *ArticleExtractor) Extract(ctx context.Context) (common.Content, error) {
+	content, err := e.source.Extract(ctx)
+	if err != nil {
+		return content, err
+	}
+
+	for i := range content.Articles {
+		article := &content.Articles[i]
+		if article.URL == "" {
+			continue
+		}
+
+		log.Printf("Fetching article: %s", article.URL)
+
+		page, err := e.readPage(ctx, article.URL)
+		if err != nil {
+			log.Printf("Warning: Failed to extract article %s: %v", article.URL, err)
+			continue
+		}
+
+		mergePage(article, page)
+	}
+
+	return content, nil
+}
+
+// readPage opens and parses a single article page
+func (e *ArticleExtractor) readPage(ctx context.Context, source string) (Page, error) {
+	reader, err := openSource(ctx, e.client, source)
+	if err != nil {
+		return Page{}, err
+	}
+	defer reader.Close()
+
+	return ParsePage(reader)
+}
+
+// PageExtractor implements the ContentExtractor interface for a directory of saved HTML pages
+type PageExtractor struct {
+	config config.ContentExtractionConfig
+}
+
+// NewPageExtractor creates a content extractor that reads saved pages from config.PageDir
+func NewPageExtractor(config config.ContentExtractionConfig) *PageExtractor {
+	return &PageExtractor{
+		config: config,
+	}
+}
+
+// Extract parses every HTML file in the page directory into an article
+func (e *PageExtractor) Extract(ctx context.Context) (common.Content, error) {
+	files, err := filepath.Glob(filepath.Join(e.config.PageDir, "*.htm*"))
+	if err != nil {
+		return common.Content{}, fmt.Errorf("failed to list pages: %w", err)
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return common.Content{}, fmt.Errorf("no HTML files found in directory: %s", e.config.PageDir)
+	}
+
+	log.Printf("Extracting content from %d saved page(s)", len(files))
+
+	content := common.Content{
+		Title: filepath.Base(e.config.PageDir),
+		Date:  time.Now().Format("January 2, 2006"),
+	}
+
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return content, err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			log.Printf("Warning: Failed to open page %s: %v", path, err)
+			continue
+		}
+		page, err := ParsePage(file)
+		file.Close()
+		if err != nil {
+			log.Printf("Warning: Failed to extract page %s: %v", path, err)
+			continue
+		}
+
+		article := common.Article{URL: path}
+		mergePage(&article, page)
+		content.Articles = append(content.Articles, article)
+	}
+
+	if len(content.Articles) == 0 {
+		return content, fmt.Errorf("no articles extracted from %s", e.config.PageDir)
+	}
+
+	// Limit to max articles
+	if e.config.MaxArticles > 0 && len(content.Articles) > e.config.MaxArticles {
+		content.Articles = content.Articles[:e.config.MaxArticles]
+	}
+
+	log.Printf("Extracted %d articles", len(content.Articles))
+	return content, nil
+}
+
+// mergePage copies the fields recovered from a page into an article without overwriting feed data
+func mergePage(article *common.Article, page Page) {
+	if article.Title == "" {
+		article.Title = page.Headline
+	}
+	if article.Byline == "" {
+		article.Byline = page.Byline
+	}
+	if article.PublishedAt == "" {
+		article.PublishedAt = page.PublishedAt
+	}
+	if text := page.Text(); text != "" {
+		article.Content = text
+	}
+	if article.Summary == "" && len(page.Paragraphs) > 0 {
+		article.Summary = page.Paragraphs[0]
+	}
+}
+
+// rawTextPattern matches elements whose content is not part of the document text
+var rawTextPattern = regexp.MustCompile(`(?is)<(script|style|noscript|svg|template|iframe)\b.*?</(script|style|noscript|svg|template|iframe)\s*>|<!--.*?-->`)
+
+// boilerplateTags are elements that never contain the article body
+var boilerplateTags = map[string]bool{
+	"nav":    true,
+	"header": true,
+	"footer": true,
+	"aside":  true,
+	"form":   true,
+	"button": true,
+	"figure": true,
+	"select": true,
+}
+
+// blockTags are elements whose start implicitly closes an open paragraph
+var blockTags = map[string]bool{
+	"p":       true,
+	"div":     true,
+	"ul":      true,
+	"ol":      true,
+	"table":   true,
+	"section": true,
+	"article": true,
+	"aside":   true,
+	"header":  true,
+	"footer":  true,
+	"h1":      true,
+	"h2":      true,
+	"h3":      true,
+	"figure":  true,
+}
+
+// boilerplateRoles are ARIA roles used for navigation and page chrome
+var boilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+	"dialog":        true,
+}
+
+// boilerplateWords are class, id and data-component words that mark navigation, ads and promos
+var boilerplateWords = map[string]bool{
+	"ad":            true,
+	"ads":           true,
+	"advert":        true,
+	"advertisement": true,
+	"banner":        true,
+	"breadcrumb":    true,
+	"caption":       true,
+	"comment":       true,
+	"comments":      true,
+	"cookie":        true,
+	"cookies":       true,
+	"footer":        true,
+	"menu":          true,
+	"nav":           true,
+	"navigation":    true,
+	"newsletter":    true,
+	"promo":         true,
+	"related":       true,
+	"share":         true,
+	"sidebar":       true,
+	"skip":          true,
+	"social":        true,
+	"sponsored":     true,
+	"subscribe":     true,
+}
+
+// minParagraphLength is the shortest paragraph considered part of the article body
+const minParagraphLength = 25
+
+// element is an open element while walking the document
+type element struct {
+	id          int
+	name        string
+	boilerplate bool
+}
+
+// capture accumulates the text of an element until it closes
+type capture struct {
+	depth int
+	kind  string
+	text  strings.Builder
+}
+
+// paragraph is a candidate body paragraph and the elements that contain it
+type paragraph struct {
+	text      string
+	ancestors []int
+}
+
+// ParsePage extracts the headline, byline, publish date and body paragraphs from an HTML page.
+// Body paragraphs are chosen readability-style: every paragraph scores its ancestors, and only
+// the paragraphs inside the highest scoring container are kept.
+func ParsePage(r io.Reader) (Page, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to read page: %w", err)
+	}
+	data = rawTextPattern.ReplaceAll(data, nil)
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var (
+		page       Page
+		meta       = map[string]string{}
+		stack      []element
+		captures   []*capture
+		paragraphs []paragraph
+		title      string
+		nextID     int
+	)
+
+	// closeTo closes open elements until only depth remain, finishing their captures
+	closeTo := func(depth int) {
+		for len(stack) > depth {
+			closing := len(stack)
+			remaining := captures[:0]
+			for _, c := range captures {
+				if c.depth != closing {
+					remaining = append(remaining, c)
+					continue
+				}
+
+				text := collapseSpace(c.text.String())
+				switch c.kind {
+				case "title":
+					if title == "" {
+						title = text
+					}
+				case "headline":
+					page.Headline = text
+				case "byline":
+					// Nested byline elements close innermost first, which is the tightest match
+					if page.Byline == "" {
+						page.Byline = cleanByline(text)
+					}
+				case "paragraph":
+					if len(text) >= minParagraphLength && !isBylineText(text, page.Byline) {
+						paragraphs = append(paragraphs, paragraph{text: text, ancestors: ancestorIDs(stack[:closing-1])})
+					}
+				}
+			}
+			captures = remaining
+			stack = stack[:closing-1]
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// Broken markup ends the walk; keep whatever was recovered so far
+			if err != io.EOF && len(paragraphs) == 0 && page.Headline == "" {
+				return Page{}, fmt.Errorf("failed to parse page: %w", err)
+			}
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			attrs := attributes(t)
+
+			if name == "meta" {
+				collectMeta(meta, attrs)
+			}
+			if name == "time" && page.PublishedAt == "" && attrs["datetime"] != "" {
+				page.PublishedAt = normalizeDate(attrs["datetime"])
+			}
+
+			// As in HTML, a new block element implicitly ends an open paragraph
+			if blockTags[name] {
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i].name == "p" {
+						closeTo(i)
+						break
+					}
+				}
+			}
+
+			// Line breaks and blocks separate words in the captured text
+			if name == "br" || blockTags[name] {
+				for _, c := range captures {
+					c.text.WriteString(" ")
+				}
+			}
+
+			parentBoilerplate := len(stack) > 0 && stack[len(stack)-1].boilerplate
+			el := element{
+				id:          nextID,
+				name:        name,
+				boilerplate: parentBoilerplate || isBoilerplate(name, attrs),
+			}
+			nextID++
+			stack = append(stack, el)
+
+			// Start capturing text for the elements we care about
+			switch {
+			case name == "title":
+				captures = append(captures, &capture{depth: len(stack), kind: "title"})
+			case name == "h1" && page.Headline == "":
+				captures = append(captures, &capture{depth: len(stack), kind: "headline"})
+			case page.Byline == "" && isByline(attrs):
+				captures = append(captures, &capture{depth: len(stack), kind: "byline"})
+			case name == "p" && !el.boilerplate:
+				captures = append(captures, &capture{depth: len(stack), kind: "paragraph"})
+			}
+
+		case xml.CharData:
+			for _, c := range captures {
+				c.text.Write(t)
+			}
+
+		case xml.EndElement:
+			// Close back to the matching open element; stray end tags are ignored
+			name := strings.ToLower(t.Name.Local)
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == name {
+					closeTo(i)
+					break
+				}
+			}
+		}
+	}
+
+	closeTo(0)
+
+	// Fall back to metadata for anything the markup didn't give us
+	if page.Headline == "" {
+		page.Headline = firstNonEmpty(meta["og:title"], title)
+	}
+	if page.Byline == "" {
+		page.Byline = cleanByline(firstNonEmpty(meta["author"], meta["article:author"]))
+	}
+	if published := firstNonEmpty(meta["article:published_time"], meta["datepublished"]); published != "" {
+		page.PublishedAt = normalizeDate(published)
+	}
+
+	page.Paragraphs = mainParagraphs(paragraphs)
+
+	if page.Headline == "" && len(page.Paragraphs) == 0 {
+		return page, fmt.Errorf("no article content found")
+	}
+
+	return page, nil
+}
+
+// mainParagraphs returns the paragraphs inside the container with the highest score
+func mainParagraphs(paragraphs []paragraph) []string {
+	scores := map[int]float64{}
+	for _, p := range paragraphs {
+		score := 1 + float64(strings.Count(p.text, ",")) + min(float64(len(p.text))/100, 3)
+
+		// Parent gets the full score, grandparent half, great-grandparent a third
+		for level := 0; level < 3 && level < len(p.ancestors); level++ {
+			id := p.ancestors[len(p.ancestors)-1-level]
+			scores[id] += score / float64(level+1)
+		}
+	}
+
+	best, bestScore := -1, 0.0
+	for id, score := range scores {
+		if score > bestScore || (score == bestScore && id < best) {
+			best, bestScore = id, score
+		}
+	}
+
+	var result []string
+	for _, p := range paragraphs {
+		for _, id := range p.ancestors {
+			if id == best {
+				result = append(result, p.text)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// attributes returns an element's attributes keyed by lower-case name
+func attributes(start xml.StartElement) map[string]string {
+	attrs := make(map[string]string, len(start.Attr))
+	for _, attr := range start.Attr {
+		attrs[strings.ToLower(attr.Name.Local)] = attr.Value
+	}
+	return attrs
+}
+
+// collectMeta records the interesting <meta> tags of a page
+func collectMeta(meta map[string]string, attrs map[string]string) {
+	key := strings.ToLower(firstNonEmpty(attrs["property"], attrs["name"], attrs["itemprop"]))
+	if key == "" {
+		return
+	}
+	if _, seen := meta[key]; !seen {
+		meta[key] = collapseSpace(attrs["content"])
+	}
+}
+
+// isBoilerplate reports whether an element is navigation, advertising or other page chrome
+func isBoilerplate(name string, attrs map[string]string) bool {
+	if boilerplateTags[name] {
+		return true
+	}
+	if boilerplateRoles[strings.ToLower(attrs["role"])] {
+		return true
+	}
+	if attrs["aria-hidden"] == "true" {
+		return true
+	}
+	for _, word := range attributeWords(attrs, "class", "id", "data-component") {
+		if boilerplateWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// isByline reports whether an element holds the article's byline
+func isByline(attrs map[string]string) bool {
+	if strings.ToLower(attrs["rel"]) == "author" {
+		return true
+	}
+	for _, word := range attributeWords(attrs, "class", "id", "data-component", "data-testid", "itemprop") {
+		if word == "byline" || word == "author" {
+			return true
+		}
+	}
+	return false
+}
+
+// attributeWords splits the given attribute values into lower-case words
+func attributeWords(attrs map[string]string, names ...string) []string {
+	var words []string
+	for _, name := range names {
+		words = append(words, strings.FieldsFunc(strings.ToLower(attrs[name]), func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+		})...)
+	}
+	return words
+}
+
+// ancestorIDs returns the IDs of the open elements from the root down
+func ancestorIDs(stack []element) []int {
+	ids := make([]int, len(stack))
+	for i, el := range stack {
+		ids[i] = el.id
+	}
+	return ids
+}
+
+// cleanByline removes the conventional "By" prefix from a byline
+func cleanByline(s string) string {
+	s = collapseSpace(s)
+	if len(s) > 3 && strings.EqualFold(s[:3], "by ") {
+		s = s[3:]
+	}
+	return s
+}
+
+// isBylineText reports whether a paragraph is just the byline repeated
+func isBylineText(text, byline string) bool {
+	return byline != "" && strings.EqualFold(cleanByline(text), byline)
+}
+
+// collapseSpace unescapes entities and collapses runs of whitespace
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// firstNonEmpty returns the first of its arguments that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
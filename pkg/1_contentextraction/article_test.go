@@ -0,0 +1,156 @@
+package contentextraction
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+func TestParsePage(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "bbc", "ceasefire-talks.html"))
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	page, err := ParsePage(file)
+	if err != nil {
+		t.Fatalf("ParsePage() error = %v", err)
+	}
+
+	// Validate the article metadata
+	if page.Headline != "Ceasefire talks resume in Geneva" {
+		t.Errorf("Headline = %q", page.Headline)
+	}
+	if page.Byline != "Jane Correspondent" {
+		t.Errorf("Byline = %q", page.Byline)
+	}
+	if page.PublishedAt != "2025-03-12T18:04:12Z" {
+		t.Errorf("PublishedAt = %q", page.PublishedAt)
+	}
+
+	// Validate the body keeps the story and drops the boilerplate
+	if len(page.Paragraphs) != 4 {
+		t.Fatalf("got %d paragraphs, want 4: %q", len(page.Paragraphs), page.Paragraphs)
+	}
+	if !strings.HasPrefix(page.Paragraphs[0], "Negotiators have returned") {
+		t.Errorf("first paragraph = %q", page.Paragraphs[0])
+	}
+	if !strings.Contains(page.Paragraphs[3], "at stake.” Further sessions") {
+		t.Errorf("line break not treated as whitespace: %q", page.Paragraphs[3])
+	}
+
+	text := page.Text()
+	for _, unwanted := range []string{"Sign in", "Advertisement", "Related topics", "Most read", "Copyright", "script", "Delegates arrived"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("body contains boilerplate %q", unwanted)
+		}
+	}
+}
+
+func TestParsePage_UnclosedParagraphs(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "bbc", "interest-rates.html"))
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	page, err := ParsePage(file)
+	if err != nil {
+		t.Fatalf("ParsePage() error = %v", err)
+	}
+
+	// Byline and date come from meta tags when the markup has none
+	if page.Byline != "Sam Economist" {
+		t.Errorf("Byline = %q", page.Byline)
+	}
+	if page.PublishedAt != "2025-03-12T12:00:00Z" {
+		t.Errorf("PublishedAt = %q", page.PublishedAt)
+	}
+
+	// Implicitly closed paragraphs are split, and short or off-story ones are dropped
+	if len(page.Paragraphs) != 3 {
+		t.Fatalf("got %d paragraphs, want 3: %q", len(page.Paragraphs), page.Paragraphs)
+	}
+	for _, p := range page.Paragraphs {
+		if strings.Contains(p, "cookies") || strings.Contains(p, "Elsewhere") {
+			t.Errorf("unexpected paragraph %q", p)
+		}
+	}
+}
+
+func TestPageExtractor_Extract(t *testing.T) {
+	cfg := config.ContentExtractionConfig{
+		PageDir: filepath.Join("testdata", "bbc"),
+	}
+	extractor := NewPageExtractor(cfg)
+
+	content, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	if len(content.Articles) != 2 {
+		t.Fatalf("got %d articles, want 2", len(content.Articles))
+	}
+	for _, article := range content.Articles {
+		if article.Title == "" || article.Content == "" || article.Summary == "" {
+			t.Errorf("incomplete article: %+v", article)
+		}
+	}
+}
+
+func TestArticleExtractor_Extract(t *testing.T) {
+	// Serve a feed whose items link to the saved BBC pages
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>BBC News</title>
+<item><title>Ceasefire talks resume in Geneva</title><description>Talks resume.</description><link>%[1]s/news/ceasefire-talks</link></item>
+<item><title>Missing page</title><link>%[1]s/news/missing</link></item>
+</channel></rss>`, server.URL)
+	})
+	mux.HandleFunc("/news/ceasefire-talks", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join("testdata", "bbc", "ceasefire-talks.html"))
+	})
+
+	cfg := config.ContentExtractionConfig{
+		Source:        server.URL + "/rss.xml",
+		FetchArticles: true,
+	}
+	extractor := New(cfg)
+
+	content, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if len(content.Articles) != 2 {
+		t.Fatalf("got %d articles, want 2", len(content.Articles))
+	}
+
+	// The feed summary is kept, and the page supplies byline and body
+	article := content.Articles[0]
+	if article.Summary != "Talks resume." {
+		t.Errorf("Summary = %q", article.Summary)
+	}
+	if article.Byline != "Jane Correspondent" {
+		t.Errorf("Byline = %q", article.Byline)
+	}
+	if !strings.Contains(article.Content, "hosted by the United Nations") {
+		t.Errorf("Content = %q", article.Content)
+	}
+
+	// A page that fails to load leaves the feed article untouched
+	if content.Articles[1].Content != "" {
+		t.Errorf("missing page produced content: %q", content.Articles[1].Content)
+	}
+}
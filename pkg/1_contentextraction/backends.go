@@ -0,0 +1,60 @@
+package contentextraction
+
+import (
+	"fmt"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/registry"
+)
+
+// Backends are the content extractors that can be selected by name in the configuration
+var Backends = registry.New("content extraction", "feed",
+	registry.Backend[config.ContentExtractionConfig, common.ContentExtractor]{
+		Name:        "feed",
+		Kind:        registry.Local,
+		Description: "RSS and Atom feeds, over HTTP or from local files",
+		New: func(config config.ContentExtractionConfig) (common.ContentExtractor, error) {
+			if config.Source == "" && len(config.Feeds) == 0 {
+				return nil, fmt.Errorf("no feed sources configured")
+			}
+			return New(config), nil
+		},
+	},
+	registry.Backend[config.ContentExtractionConfig, common.ContentExtractor]{
+		Name:        "pages",
+		Kind:        registry.Local,
+		Description: "saved article HTML pages",
+		New: func(config config.ContentExtractionConfig) (common.ContentExtractor, error) {
+			if config.PageDir == "" {
+				return nil, fmt.Errorf("no page directory configured")
+			}
+			return NewPageExtractor(config), nil
+		},
+	},
+	registry.Backend[config.ContentExtractionConfig, common.ContentExtractor]{
+		Name:        "screenshots",
+		Kind:        registry.Provider,
+		Description: "Claude reads the headlines in screenshots of news front pages",
+		New: func(config config.ContentExtractionConfig) (common.ContentExtractor, error) {
+			if config.ScreenshotDir == "" {
+				return nil, fmt.Errorf("no screenshot directory configured")
+			}
+			if config.ClaudeAPIKey == "" {
+				return nil, fmt.Errorf("no Claude API key provided")
+			}
+			return NewScreenshotExtractor(config), nil
+		},
+	},
+	registry.Backend[config.ContentExtractionConfig, common.ContentExtractor]{
+		Name:        "aggregator",
+		Kind:        registry.Local,
+		Description: "several sources merged, with stories ranked by how many outlets covered them",
+		New: func(config config.ContentExtractionConfig) (common.ContentExtractor, error) {
+			if len(config.Sources) == 0 {
+				return nil, fmt.Errorf("no content sources configured")
+			}
+			return NewAggregator(config), nil
+		},
+	},
+)
@@ -1,2 +1,150 @@
+/*
+Package contentextraction implements the first stage of the Stitch-Up pipeline.
+
+This module is responsible for collecting the day's news stories and turning
+them into a common.Content value that drives the rest of the pipeline. The
+feed extractor reads RSS 2.0 and Atom feeds, either over HTTP or from local
+files so that the stage can be exercised offline. The article extractor
+follows each article's link (or reads saved HTML pages) and strips navigation,
+adverts and other boilerplate to recover the full body text. The screenshot
+extractor sends screenshots of news front pages to Claude and turns the
+headlines it reads into articles. The aggregator merges several configured
+sources, clusters articles that report the same story and ranks stories by
+how many outlets covered them.
+*/
 package contentextraction
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/transport"
+)
+
+// FeedExtractor implements the ContentExtractor interface for RSS and Atom feeds
+type FeedExtractor struct {
+	config config.ContentExtractionConfig
+	client *http.Client
+}
+
+// New creates a content extractor for the configured feeds, which also fetches each
+// article's page when config.FetchArticles is set
+func New(config config.ContentExtractionConfig) common.ContentExtractor {
+	extractor := common.ContentExtractor(NewFeedExtractor(config))
+	if config.FetchArticles {
+		extractor = NewArticleExtractor(extractor)
+	}
+	return extractor
+}
+
+// NewFeedExtractor creates a new content extractor that reads RSS and Atom feeds
+func NewFeedExtractor(config config.ContentExtractionConfig) *FeedExtractor {
+	return &FeedExtractor{
+		config: config,
+		client: transport.NewClient("", 30*time.Second),
+	}
+}
+
+// Extract reads every configured feed and combines their items into a single Content
+func (e *FeedExtractor) Extract(ctx context.Context) (common.Content, error) {
+	sources := e.sources()
+	if len(sources) == 0 {
+		return common.Content{}, fmt.Errorf("no feed sources configured")
+	}
+
+	log.Printf("Extracting content from %d feed(s)", len(sources))
+
+	content := common.Content{
+		Date: time.Now().Format("January 2, 2006"),
+	}
+
+	for _, source := range sources {
+		feed, err := e.readFeed(ctx, source)
+		if err != nil {
+			log.Printf("Warning: Failed to read feed %s: %v", source, err)
+			continue
+		}
+
+		// Use the first feed's metadata to describe the content as a whole
+		if content.Title == "" {
+			content.Title = feed.Title
+			content.Description = feed.Description
+		}
+
+		content.Articles = append(content.Articles, feed.Articles...)
+	}
+
+	if len(content.Articles) == 0 {
+		return content, fmt.Errorf("no articles found in %d feed(s)", len(sources))
+	}
+
+	// Limit to max articles
+	if e.config.MaxArticles > 0 && len(content.Articles) > e.config.MaxArticles {
+		content.Articles = content.Articles[:e.config.MaxArticles]
+	}
+
+	log.Printf("Extracted %d articles", len(content.Articles))
+	return content, nil
+}
+
+// sources returns the feed locations to read, preferring the explicit feed list
+func (e *FeedExtractor) sources() []string {
+	if len(e.config.Feeds) > 0 {
+		return e.config.Feeds
+	}
+	if e.config.Source != "" {
+		return []string{e.config.Source}
+	}
+	return nil
+}
+
+// readFeed opens and parses a single feed
+func (e *FeedExtractor) readFeed(ctx context.Context, source string) (feed, error) {
+	reader, err := openSource(ctx, e.client, source)
+	if err != nil {
+		return feed{}, err
+	}
+	defer reader.Close()
+
+	return parseFeed(reader)
+}
+
+// openSource opens a source that is either an HTTP(S) URL, a file:// URL or a local path
+func openSource(ctx context.Context, client *http.Client, source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		file, err := os.Open(strings.TrimPrefix(source, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		return file, nil
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "GET", source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "stitch-up/1.0")
+
+	// Send request
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	// Check response status
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,212 @@
+package contentextraction
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+func TestFeedExtractor_Extract_RSS(t *testing.T) {
+	// Create extractor reading a local RSS file
+	cfg := config.ContentExtractionConfig{
+		Source: filepath.Join("testdata", "rss.xml"),
+	}
+	extractor := New(cfg)
+
+	content, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	// Validate feed metadata
+	if content.Title != "BBC News" {
+		t.Errorf("Title = %q, want %q", content.Title, "BBC News")
+	}
+	if content.Date == "" {
+		t.Error("Extract() returned empty date")
+	}
+
+	if len(content.Articles) != 2 {
+		t.Fatalf("got %d articles, want 2", len(content.Articles))
+	}
+
+	// Validate the first article
+	article := content.Articles[0]
+	if article.Title != "Ceasefire talks resume in Geneva" {
+		t.Errorf("Title = %q", article.Title)
+	}
+	if article.Summary != "Negotiators return to the table after a week-long pause." {
+		t.Errorf("Summary = %q", article.Summary)
+	}
+	if article.URL != "https://www.bbc.co.uk/news/world-00000001" {
+		t.Errorf("URL = %q", article.URL)
+	}
+	if article.PublishedAt != "2025-03-12T18:04:12Z" {
+		t.Errorf("PublishedAt = %q", article.PublishedAt)
+	}
+
+	// The second article has no link, so the GUID is used, and content:encoded fills Content
+	article = content.Articles[1]
+	if article.URL != "https://www.bbc.co.uk/news/business-00000002" {
+		t.Errorf("URL = %q", article.URL)
+	}
+	if article.Summary != "The decision was widely expected by markets & economists." {
+		t.Errorf("Summary = %q", article.Summary)
+	}
+	if article.Content != "The Bank kept rates on hold. Markets had priced in the move." {
+		t.Errorf("Content = %q", article.Content)
+	}
+}
+
+func TestFeedExtractor_Extract_Atom(t *testing.T) {
+	cfg := config.ContentExtractionConfig{
+		Feeds: []string{filepath.Join("testdata", "atom.xml")},
+	}
+	extractor := New(cfg)
+
+	content, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	if content.Title != "Science Desk" || content.Description != "Latest science stories" {
+		t.Errorf("unexpected feed metadata: %q / %q", content.Title, content.Description)
+	}
+	if len(content.Articles) != 2 {
+		t.Fatalf("got %d articles, want 2", len(content.Articles))
+	}
+
+	// The alternate link wins over the self link
+	article := content.Articles[0]
+	if article.URL != "https://example.com/science/probe" {
+		t.Errorf("URL = %q", article.URL)
+	}
+	if article.Summary != "Engineers celebrate a textbook landing." {
+		t.Errorf("Summary = %q", article.Summary)
+	}
+
+	// Entries without links fall back to the ID, and without a summary fall back to content
+	article = content.Articles[1]
+	if article.URL != "https://example.com/health/vaccine" {
+		t.Errorf("URL = %q", article.URL)
+	}
+	if article.Summary != "Volunteers receive the first doses." {
+		t.Errorf("Summary = %q", article.Summary)
+	}
+	if article.PublishedAt != "2025-03-11T16:00:00Z" {
+		t.Errorf("PublishedAt = %q", article.PublishedAt)
+	}
+}
+
+func TestFeedExtractor_Extract_MultipleFeeds(t *testing.T) {
+	// Serve the RSS fixture over HTTP alongside a local Atom file
+	rss, err := os.ReadFile(filepath.Join("testdata", "rss.xml"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write(rss)
+	}))
+	defer server.Close()
+
+	cfg := config.ContentExtractionConfig{
+		Feeds: []string{
+			server.URL + "/news/rss.xml",
+			filepath.Join("testdata", "atom.xml"),
+			filepath.Join("testdata", "missing.xml"),
+		},
+		MaxArticles: 3,
+	}
+	extractor := New(cfg)
+
+	content, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	// A missing feed is skipped, and the result is capped at MaxArticles
+	if len(content.Articles) != 3 {
+		t.Errorf("got %d articles, want 3", len(content.Articles))
+	}
+	if content.Title != "BBC News" {
+		t.Errorf("Title = %q, want the first feed's title", content.Title)
+	}
+}
+
+func TestFeedExtractor_Extract_NoSources(t *testing.T) {
+	extractor := New(config.ContentExtractionConfig{})
+
+	if _, err := extractor.Extract(context.Background()); err == nil {
+		t.Error("Extract() with no sources should return error")
+	}
+}
+
+func TestBackends_New(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.ContentExtractionConfig
+		want    string
+		wantErr string
+	}{
+		{name: "", config: config.ContentExtractionConfig{Source: "feed.xml", FetchArticles: true}, want: "*contentextraction.ArticleExtractor"},
+		{name: "feed", config: config.ContentExtractionConfig{}, wantErr: "no feed sources"},
+		{name: "pages", config: config.ContentExtractionConfig{PageDir: "pages"}, want: "*contentextraction.PageExtractor"},
+		{name: "screenshots", config: config.ContentExtractionConfig{ScreenshotDir: "shots"}, wantErr: "no Claude API key"},
+		{name: "screenshots", config: config.ContentExtractionConfig{ScreenshotDir: "shots", ClaudeAPIKey: "key"}, want: "*contentextraction.ScreenshotExtractor"},
+		{name: "aggregator", config: config.ContentExtractionConfig{Sources: []config.SourceConfig{{Location: "feed.xml"}}}, want: "*contentextraction.Aggregator"},
+		{name: "aggregator", config: config.ContentExtractionConfig{Source: "feed.xml"}, wantErr: "no content sources"},
+	}
+
+	for _, tt := range tests {
+		extractor, err := Backends.New(tt.name, tt.config, false)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Backends.New(%q) error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Backends.New(%q) error = %v", tt.name, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", extractor); got != tt.want {
+			t.Errorf("Backends.New(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRealBBCExtraction(t *testing.T) {
+	if os.Getenv("REAL_TEST") != "true" {
+		t.Skip("Set REAL_TEST=true to run against the real BBC feed")
+	}
+
+	cfg, err := config.LoadForTest()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	extractor, err := Backends.New(cfg.ContentExtraction.Backend, cfg.ContentExtraction, false)
+	if err != nil {
+		t.Fatalf("Backends.New() error = %v", err)
+	}
+
+	content, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	t.Logf("Extracted %d articles from %s", len(content.Articles), content.Title)
+	for _, article := range content.Articles {
+		if article.Title == "" || article.URL == "" {
+			t.Errorf("Article missing title or URL: %+v", article)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package contentextraction
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+)
+
+// feed is the format-independent result of parsing an RSS or Atom document
+type feed struct {
+	Title       string
+	Description string
+	Articles    []common.Article
+}
+
+// rssDocument matches the parts of an RSS 2.0 document we use
+type rssDocument struct {
+	Channel struct {
+		Title       string    `xml:"title"`
+		Description string    `xml:"description"`
+		Items       []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// rssItem is a single RSS 2.0 item
+type rssItem struct {
+	Title       string `xml:"title"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+// atomDocument matches the parts of an Atom document we use
+type atomDocument struct {
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+// atomEntry is a single Atom entry
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+	Links     []atomLink `xml:"link"`
+	ID        string     `xml:"id"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+// atomLink is an Atom link element
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// parseFeed parses an RSS 2.0 or Atom document into a feed
+func parseFeed(r io.Reader) (feed, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return feed{}, fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	// Peek at the root element to decide which format we have
+	root, err := rootElement(data)
+	if err != nil {
+		return feed{}, err
+	}
+
+	switch root {
+	case "rss":
+		return parseRSS(data)
+	case "feed":
+		return parseAtom(data)
+	default:
+		return feed{}, fmt.Errorf("unsupported feed format: <%s>", root)
+	}
+}
+
+// rootElement returns the local name of the document's root element
+func rootElement(data []byte) (string, error) {
+	decoder := newDecoder(data)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to parse feed: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// parseRSS parses an RSS 2.0 document
+func parseRSS(data []byte) (feed, error) {
+	var doc rssDocument
+	if err := newDecoder(data).Decode(&doc); err != nil {
+		return feed{}, fmt.Errorf("failed to parse RSS feed: %w", err)
+	}
+
+	result := feed{
+		Title:       cleanText(doc.Channel.Title),
+		Description: cleanText(doc.Channel.Description),
+	}
+
+	for _, item := range doc.Channel.Items {
+		link := strings.TrimSpace(item.Link)
+		if link == "" {
+			link = strings.TrimSpace(item.GUID)
+		}
+
+		published := item.PubDate
+		if published == "" {
+			published = item.Date
+		}
+
+		result.Articles = append(result.Articles, common.Article{
+			Title:       cleanText(item.Title),
+			Summary:     cleanText(item.Description),
+			Content:     cleanText(item.Encoded),
+			URL:         link,
+			PublishedAt: normalizeDate(published),
+		})
+	}
+
+	return result, nil
+}
+
+// parseAtom parses an Atom document
+func parseAtom(data []byte) (feed, error) {
+	var doc atomDocument
+	if err := newDecoder(data).Decode(&doc); err != nil {
+		return feed{}, fmt.Errorf("failed to parse Atom feed: %w", err)
+	}
+
+	result := feed{
+		Title:       cleanText(doc.Title),
+		Description: cleanText(doc.Subtitle),
+	}
+
+	for _, entry := range doc.Entries {
+		summary := entry.Summary
+		if summary == "" {
+			summary = entry.Content
+		}
+
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+
+		result.Articles = append(result.Articles, common.Article{
+			Title:       cleanText(entry.Title),
+			Summary:     cleanText(summary),
+			Content:     cleanText(entry.Content),
+			URL:         entryLink(entry),
+			PublishedAt: normalizeDate(published),
+		})
+	}
+
+	return result, nil
+}
+
+// entryLink picks the alternate link of an Atom entry, falling back to its ID
+func entryLink(entry atomEntry) string {
+	for _, link := range entry.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	if len(entry.Links) > 0 {
+		return strings.TrimSpace(entry.Links[0].Href)
+	}
+	return strings.TrimSpace(entry.ID)
+}
+
+// newDecoder creates a lenient XML decoder suitable for real-world feeds
+func newDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	// Feeds in the wild declare all sorts of charsets; pass them through as-is
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+// tagPattern matches HTML tags embedded in feed text
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// cleanText strips embedded HTML, unescapes entities and collapses whitespace
+func cleanText(s string) string {
+	s = tagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// feedDateLayouts lists the date formats commonly found in RSS and Atom feeds
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02",
+}
+
+// normalizeDate converts a feed date to RFC 3339, returning the input unchanged if it can't be parsed
+func normalizeDate(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return s
+}
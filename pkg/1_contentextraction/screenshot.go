@@ -0,0 +1,220 @@
+package contentextraction
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/llm"
+	"github.com/iantozer/stitch-up/pkg/prompts"
+)
+
+// ScreenshotExtractor implements the ContentExtractor interface by reading news screenshots with Claude
+type ScreenshotExtractor struct {
+	config  config.ContentExtractionConfig
+	client  *llm.Client
+	prompts *prompts.Set
+}
+
+// screenshotStory is a single story as transcribed by Claude
+type screenshotStory struct {
+	Headline   string `json:"headline"`
+	Standfirst string `json:"standfirst"`
+	Section    string `json:"section"`
+}
+
+// storiesToolName is the tool Claude is forced to call with the stories in a screenshot
+const storiesToolName = "record_stories"
+
+// maxStoryAttempts is how many times Claude is asked for a screenshot's stories before giving up
+const maxStoryAttempts = 3
+
+// storiesTool describes the stories in a screenshot to Claude as a JSON schema
+var storiesTool = llm.Tool{
+	Name:        storiesToolName,
+	Description: "Record every distinct news story visible in a screenshot of a news website.",
+	InputSchema: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "stories": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "headline": {"type": "string", "description": "The headline exactly as written"},
+          "standfirst": {"type": "string", "description": "The summary or standfirst shown under the headline, or an empty string"},
+          "section": {"type": "string", "description": "The section or category label shown with the story, e.g. World or Business, or an empty string"}
+        },
+        "required": ["headline"]
+      }
+    }
+  },
+  "required": ["stories"]
+}`),
+}
+
+// screenshotPromptData is passed to the screenshot prompt template
+type screenshotPromptData struct {
+	Tool string // the tool Claude must record the stories with
+}
+
+// NewScreenshotExtractor creates a content extractor for the screenshots in config.ScreenshotDir
+func NewScreenshotExtractor(config config.ContentExtractionConfig) *ScreenshotExtractor {
+	return &ScreenshotExtractor{
+		config: config,
+		client: llm.New(llm.Options{
+			APIKey:  config.ClaudeAPIKey,
+			BaseURL: config.ClaudeBaseURL,
+			Model:   config.ClaudeModel,
+		}),
+		prompts: prompts.New(config.PromptDir),
+	}
+}
+
+// Extract sends each screenshot to Claude and collects the stories it finds
+func (e *ScreenshotExtractor) Extract(ctx context.Context) (common.Content, error) {
+	if e.config.ClaudeAPIKey == "" {
+		return common.Content{}, fmt.Errorf("no Claude API key provided for screenshot extraction")
+	}
+
+	imageFiles, err := listImages(e.config.ScreenshotDir)
+	if err != nil {
+		return common.Content{}, err
+	}
+
+	log.Printf("Extracting content from %d screenshot(s)", len(imageFiles))
+
+	content := common.Content{
+		Title: filepath.Base(e.config.ScreenshotDir),
+		Date:  time.Now().Format("January 2, 2006"),
+	}
+
+	seen := map[string]bool{}
+	for _, imagePath := range imageFiles {
+		log.Printf("Processing screenshot: %s", imagePath)
+
+		articles, err := e.extractScreenshot(ctx, imagePath)
+		if err != nil {
+			if ctx.Err() != nil {
+				return content, ctx.Err()
+			}
+			log.Printf("Warning: Failed to extract stories from %s: %v", imagePath, err)
+			continue
+		}
+
+		// Overlapping screenshots show the same story more than once
+		for _, article := range articles {
+			key := strings.ToLower(article.Title)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			content.Articles = append(content.Articles, article)
+		}
+	}
+
+	if len(content.Articles) == 0 {
+		return content, fmt.Errorf("no stories extracted from %d screenshot(s)", len(imageFiles))
+	}
+
+	// Limit to max articles
+	if e.config.MaxArticles > 0 && len(content.Articles) > e.config.MaxArticles {
+		content.Articles = content.Articles[:e.config.MaxArticles]
+	}
+
+	log.Printf("Extracted %d articles using %s", len(content.Articles), e.client.Usage())
+	return content, nil
+}
+
+// extractScreenshot asks Claude for the stories in a single screenshot
+func (e *ScreenshotExtractor) extractScreenshot(ctx context.Context, imagePath string) ([]common.Article, error) {
+	imageData, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	prompt, err := e.prompts.Render(prompts.Screenshot, screenshotPromptData{Tool: storiesToolName})
+	if err != nil {
+		return nil, err
+	}
+
+	request := llm.Request{
+		Messages: []llm.Message{
+			{Role: "user", Content: []llm.ContentBlock{
+				llm.TextBlock(prompt),
+				llm.ImageBlock(llm.ImageMediaType(imageData), base64.StdEncoding.EncodeToString(imageData)),
+			}},
+		},
+	}
+
+	var articles []common.Article
+	err = e.client.CallTool(ctx, request, storiesTool, maxStoryAttempts, func(input json.RawMessage) error {
+		articles, err = decodeStories(input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return articles, nil
+}
+
+// decodeStories converts the tool input into articles, skipping stories without a headline
+func decodeStories(input json.RawMessage) ([]common.Article, error) {
+	var recorded struct {
+		Stories []screenshotStory `json:"stories"`
+	}
+	if err := json.Unmarshal(input, &recorded); err != nil {
+		return nil, fmt.Errorf("input does not match the schema: %v", err)
+	}
+
+	var articles []common.Article
+	for _, story := range recorded.Stories {
+		headline := strings.TrimSpace(story.Headline)
+		if headline == "" {
+			continue
+		}
+		articles = append(articles, common.Article{
+			Title:   headline,
+			Summary: strings.TrimSpace(story.Standfirst),
+			Section: strings.TrimSpace(story.Section),
+		})
+	}
+
+	return articles, nil
+}
+
+// listImages returns the image files in a directory in name order
+func listImages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read screenshot directory: %w", err)
+	}
+
+	var imageFiles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+			imageFiles = append(imageFiles, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(imageFiles)
+
+	if len(imageFiles) == 0 {
+		return nil, fmt.Errorf("no image files found in directory: %s", dir)
+	}
+
+	return imageFiles, nil
+}
@@ -0,0 +1,116 @@
+package contentextraction
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+func TestScreenshotExtractor_Extract(t *testing.T) {
+	// Create two small screenshots
+	dir := t.TempDir()
+	for _, name := range []string{"a.png", "b.png"} {
+		file, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to create screenshot: %v", err)
+		}
+		png.Encode(file, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+		file.Close()
+	}
+
+	// Stand in for the Messages API, recording each screenshot's stories in turn
+	replies := []string{
+		`{"stories": [{"headline": "Ceasefire talks resume", "standfirst": "Negotiators return.", "section": "World"},
+{"headline": "Bank holds rates", "standfirst": "", "section": "Business"}]}`,
+		`{"stories": [{"headline": "Bank holds rates", "standfirst": "", "section": "Business"},
+{"headline": "Probe lands on moon", "standfirst": "A textbook landing [with video].", "section": "Science"}]}`,
+	}
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("missing API key header")
+		}
+
+		// Every request carries the prompt and the screenshot
+		var request struct {
+			Messages []struct {
+				Content []struct {
+					Type   string `json:"type"`
+					Source struct {
+						MediaType string `json:"media_type"`
+					} `json:"source"`
+				} `json:"content"`
+			} `json:"messages"`
+			ToolChoice struct {
+				Name string `json:"name"`
+			} `json:"tool_choice"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Messages) == 0 {
+			t.Errorf("Failed to decode request: %v", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		blocks := request.Messages[0].Content
+		if len(blocks) != 2 || blocks[1].Type != "image" || blocks[1].Source.MediaType != "image/png" {
+			t.Errorf("unexpected content blocks: %+v", blocks)
+		}
+
+		if request.ToolChoice.Name != storiesToolName {
+			t.Errorf("tool choice = %q, want %s", request.ToolChoice.Name, storiesToolName)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"content": []map[string]interface{}{
+				{"type": "tool_use", "id": "toolu_1", "name": storiesToolName, "input": json.RawMessage(replies[calls])},
+			},
+		})
+		calls++
+	}))
+	defer server.Close()
+
+	cfg := config.ContentExtractionConfig{
+		ScreenshotDir: dir,
+		ClaudeAPIKey:  "test-key",
+		ClaudeBaseURL: server.URL,
+	}
+	extractor := NewScreenshotExtractor(cfg)
+
+	content, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	// The story repeated across screenshots appears once
+	if len(content.Articles) != 3 {
+		t.Fatalf("got %d articles, want 3: %+v", len(content.Articles), content.Articles)
+	}
+
+	article := content.Articles[0]
+	if article.Title != "Ceasefire talks resume" || article.Summary != "Negotiators return." || article.Section != "World" {
+		t.Errorf("unexpected article: %+v", article)
+	}
+	if content.Articles[2].Section != "Science" || content.Articles[2].Summary != "A textbook landing [with video]." {
+		t.Errorf("unexpected article: %+v", content.Articles[2])
+	}
+}
+
+func TestScreenshotExtractor_Extract_NoAPIKey(t *testing.T) {
+	cfg := config.ContentExtractionConfig{
+		ScreenshotDir: t.TempDir(),
+	}
+
+	if _, err := NewScreenshotExtractor(cfg).Extract(context.Background()); err == nil {
+		t.Error("Extract() without API key should return error")
+	}
+}
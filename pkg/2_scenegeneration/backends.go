@@ -0,0 +1,32 @@
+package scenegeneration
+
+import (
+	"fmt"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/registry"
+)
+
+// Backends are the scene generators that can be selected by name in the configuration
+var Backends = registry.New("scene generation", "claude",
+	registry.Backend[config.SceneGenerationConfig, common.SceneGenerator]{
+		Name:        "claude",
+		Kind:        registry.Provider,
+		Description: "Claude describes a scene for each article or headline screenshot",
+		New: func(config config.SceneGenerationConfig) (common.SceneGenerator, error) {
+			if config.ClaudeKey == "" {
+				return nil, fmt.Errorf("no Claude API key provided")
+			}
+			return New(config), nil
+		},
+	},
+	registry.Backend[config.SceneGenerationConfig, common.SceneGenerator]{
+		Name:        "mock",
+		Kind:        registry.Mock,
+		Description: "fixed scenes for offline runs",
+		New: func(config config.SceneGenerationConfig) (common.SceneGenerator, error) {
+			return NewMock(config), nil
+		},
+	},
+)
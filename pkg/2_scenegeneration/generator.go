@@ -1,48 +1,123 @@
 /*
 Package scenegeneration implements the second stage of the Stitch-Up pipeline.
 
-This module is responsible for generating scene descriptions from BBC news content.
-It takes a screenshot of the BBC website and uses Claude to analyze it and generate
-visual scene descriptions that can be used for image creation.
+This module is responsible for generating scene descriptions from the day's news
+content. In text mode it builds one scene per extracted article from its title,
+summary and body. In screenshot mode it reads news headline screenshots from the
+configured input directory and uses Claude to analyze them. Either way the result
+is a set of visual scene descriptions that can be used for image creation.
 */
 package scenegeneration
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
+	"github.com/iantozer/stitch-up/internal/workpool"
 	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/llm"
+	"github.com/iantozer/stitch-up/pkg/prompts"
 )
 
 // Generator implements the SceneGenerator interface
 type Generator struct {
-	config config.SceneGenerationConfig
+	config  config.SceneGenerationConfig
+	client  *llm.Client
+	prompts *prompts.Set
+	mock    bool // return fixed scenes instead of asking Claude
+}
+
+// promptData is passed to the scene prompt templates
+type promptData struct {
+	common.Article
+	Tool string // the tool Claude must record the scene with
 }
 
 // New creates a new scene generator
 func New(config config.SceneGenerationConfig) common.SceneGenerator {
 	return &Generator{
 		config: config,
+		client: llm.New(llm.Options{
+			APIKey:  config.ClaudeKey,
+			BaseURL: config.ClaudeBaseURL,
+			Model:   config.ClaudeModel,
+		}),
+		prompts: prompts.New(config.PromptDir),
 	}
 }
 
-// Generate generates scene descriptions from BBC headline images
+// NewMock creates a scene generator that returns fixed scenes without calling Claude
+func NewMock(config config.SceneGenerationConfig) common.SceneGenerator {
+	g := New(config).(*Generator)
+	g.mock = true
+	return g
+}
+
+// Generate generates scene descriptions from the content's articles, or from headline screenshots
 func (g *Generator) Generate(ctx context.Context, content common.Content) ([]common.Scene, error) {
-	log.Println("Generating scene descriptions from BBC headline images")
+	switch g.config.Mode {
+	case "text":
+		return g.generateFromArticles(ctx, content)
+	case "screenshots":
+		return g.generateFromScreenshots(ctx)
+	case "":
+		// Prefer the extracted content, falling back to screenshots when there is none
+		if len(content.Articles) > 0 {
+			return g.generateFromArticles(ctx, content)
+		}
+		return g.generateFromScreenshots(ctx)
+	default:
+		return nil, fmt.Errorf("unknown scene generation mode: %s", g.config.Mode)
+	}
+}
+
+// generateFromArticles generates one scene description per article
+func (g *Generator) generateFromArticles(ctx context.Context, content common.Content) ([]common.Scene, error) {
+	log.Println("Generating scene descriptions from news articles")
+
+	articles := content.Articles
+	if len(articles) == 0 {
+		return nil, fmt.Errorf("no articles to generate scenes from")
+	}
+
+	// Limit to max scenes
+	if g.config.MaxScenes > 0 && len(articles) > g.config.MaxScenes {
+		articles = articles[:g.config.MaxScenes]
+	}
 
-	// Path to the BBC headline images directory
-	imagesDir := "input/12_march_2025_bbc"
+	allScenes, err := g.generateConcurrently(ctx, len(articles), func(ctx context.Context, i int) ([]common.Scene, error) {
+		log.Printf("Processing article: %s", articles[i].Title)
+
+		scenes, err := g.generateSceneForArticle(ctx, i, articles[i])
+		if err != nil {
+			return nil, fmt.Errorf("article %q: %w", articles[i].Title, err)
+		}
+		return scenes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Generated %d scene descriptions using %s", len(allScenes), g.client.Usage())
+	return allScenes, nil
+}
+
+// generateFromScreenshots generates scene descriptions from the headline images in the input directory
+func (g *Generator) generateFromScreenshots(ctx context.Context) ([]common.Scene, error) {
+	log.Println("Generating scene descriptions from headline images")
+
+	imagesDir := g.config.InputDir
+	if imagesDir == "" {
+		return nil, fmt.Errorf("no input directory configured for screenshot mode")
+	}
 
 	// Check if the directory exists
 	if _, err := os.Stat(imagesDir); os.IsNotExist(err) {
@@ -67,41 +142,62 @@ func (g *Generator) Generate(ctx context.Context, content common.Content) ([]com
 		return nil, fmt.Errorf("no image files found in directory: %s", imagesDir)
 	}
 
+	// Limit to max scenes
+	if g.config.MaxScenes > 0 && len(imageFiles) > g.config.MaxScenes {
+		imageFiles = imageFiles[:g.config.MaxScenes]
+	}
+
 	log.Printf("Found %d image files", len(imageFiles))
 
 	// Process each image and generate a scene description
-	var allScenes []common.Scene
-	for _, imagePath := range imageFiles {
+	allScenes, err := g.generateConcurrently(ctx, len(imageFiles), func(ctx context.Context, i int) ([]common.Scene, error) {
+		imagePath := imageFiles[i]
 		log.Printf("Processing image: %s", imagePath)
 
 		// Read the image
 		imageData, err := os.ReadFile(imagePath)
 		if err != nil {
-			log.Printf("Warning: Failed to read image %s: %v", imagePath, err)
-			continue
+			return nil, fmt.Errorf("image %s: %w", imagePath, err)
 		}
 
-		// Encode the image as base64
-		base64Image := base64.StdEncoding.EncodeToString(imageData)
-
 		// Generate scene description using Claude
-		scenes, err := g.generateSceneForImage(ctx, base64Image, filepath.Base(imagePath))
+		scenes, err := g.generateSceneForImage(ctx, i, imageData, filepath.Base(imagePath))
 		if err != nil {
-			log.Printf("Warning: Failed to generate scene for image %s: %v", imagePath, err)
-			continue
+			return nil, fmt.Errorf("image %s: %w", imagePath, err)
 		}
+		return scenes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Add the scene to the collection
-		allScenes = append(allScenes, scenes...)
+	log.Printf("Generated %d scene descriptions using %s", len(allScenes), g.client.Usage())
+	return allScenes, nil
+}
+
+// generateConcurrently runs generate for inputs 0..n-1 on a bounded pool of workers.
+// Scenes are returned in input order. If the context is cancelled its error is
+// returned; otherwise every failed input is reported in a single joined error.
+func (g *Generator) generateConcurrently(ctx context.Context, n int, generate func(ctx context.Context, i int) ([]common.Scene, error)) ([]common.Scene, error) {
+	// Each input writes only its own slot, so results need no locking
+	results := make([][]common.Scene, n)
+	errs := make([]error, n)
+	workpool.ForEach(ctx, g.config.Concurrency, n, func(ctx context.Context, i int) {
+		results[i], errs[i] = generate(ctx, i)
+	})
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	// If we couldn't generate any scenes, return mock scenes
-	if len(allScenes) == 0 {
-		log.Println("No scenes generated from images, using mock scenes")
-		return g.getMockScenes(), nil
+	var allScenes []common.Scene
+	for _, scenes := range results {
+		allScenes = append(allScenes, scenes...)
 	}
 
-	log.Printf("Generated %d scene descriptions", len(allScenes))
+	if err := errors.Join(errs...); err != nil {
+		return allScenes, fmt.Errorf("failed to generate scenes: %w", err)
+	}
 	return allScenes, nil
 }
 
@@ -111,225 +207,93 @@ func isImageFile(filename string) bool {
 	return ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".gif" || ext == ".webp"
 }
 
-// generateSceneForImage generates a scene description for a single image
-func (g *Generator) generateSceneForImage(ctx context.Context, base64Image, imageName string) ([]common.Scene, error) {
-	// Check if Claude API key is provided
-	if g.config.ClaudeKey == "" {
-		// Return a single mock scene for this image
-		mockScenes := g.getMockScenes()
-		if len(mockScenes) > 0 {
-			return []common.Scene{mockScenes[0]}, nil
-		}
-		return nil, fmt.Errorf("no Claude API key provided and no mock scenes available")
+// generateSceneForImage generates a scene description for the i-th image
+func (g *Generator) generateSceneForImage(ctx context.Context, i int, imageData []byte, imageName string) ([]common.Scene, error) {
+	if g.mock {
+		return g.mockScene(i, imageName, generateSceneID(imageName))
 	}
 
 	// Prepare the prompt for Claude
-	prompt := `You are an expert visual director. I'm showing you a screenshot of a BBC News headline.
-
-Please analyze this news headline image and generate a single detailed scene description that visually represents this story.
-
-Provide:
-1. A title that captures the essence of the news story
-2. A detailed visual description (150-200 words) that a text-to-image AI could use to generate a compelling image
-3. The mood or atmosphere of the scene (e.g., tense, hopeful, somber)
-
-Make the scene visually rich and emotionally impactful. Focus on creating imagery that tells the story without text.
-
-Format your response as a JSON object with "title", "description", and "mood" fields.`
-
-	// Call Claude API
-	response, err := g.callClaudeAPI(ctx, prompt, base64Image)
+	prompt, err := g.prompts.Render(prompts.SceneImage, promptData{Tool: sceneToolName})
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse Claude's response
-	scene, err := g.parseClaudeResponseForSingleScene(response, imageName)
+	// Ask Claude for the scene
+	scene, err := g.requestScene(ctx, prompt, llm.ImageBlock(llm.ImageMediaType(imageData), base64.StdEncoding.EncodeToString(imageData)))
 	if err != nil {
 		return nil, err
 	}
 
-	return []common.Scene{scene}, nil
-}
-
-// parseClaudeResponseForSingleScene parses Claude's response for a single scene
-func (g *Generator) parseClaudeResponseForSingleScene(response, imageName string) (common.Scene, error) {
-	// Extract JSON from response
-	jsonStart := strings.Index(response, "{")
-	jsonEnd := strings.LastIndex(response, "}")
-
-	if jsonStart == -1 || jsonEnd == -1 || jsonEnd <= jsonStart {
-		// If we can't find valid JSON, try to extract scene description manually
-		return g.extractSingleSceneManually(response, imageName)
-	}
-
-	jsonStr := response[jsonStart : jsonEnd+1]
-
-	// Parse JSON
-	var scene common.Scene
-	if err := json.Unmarshal([]byte(jsonStr), &scene); err != nil {
-		return g.extractSingleSceneManually(response, imageName)
-	}
-
 	// Add source information
 	scene.SourceTitle = imageName
 	scene.ID = generateSceneID(imageName)
 
-	return scene, nil
+	return []common.Scene{scene}, nil
 }
 
-// extractSingleSceneManually extracts a single scene description from Claude's response when JSON parsing fails
-func (g *Generator) extractSingleSceneManually(response, imageName string) (common.Scene, error) {
-	// Default values
-	title := "News Scene: " + imageName
-	description := "A visual representation of a news story."
-	mood := "neutral"
-
-	// Try to extract title
-	titleStart := strings.Index(response, "Title:")
-	if titleStart != -1 {
-		titleEnd := strings.Index(response[titleStart:], "\n")
-		if titleEnd != -1 {
-			title = strings.TrimSpace(response[titleStart+6 : titleStart+titleEnd])
-		}
+// generateSceneForArticle generates a scene description for the i-th article
+func (g *Generator) generateSceneForArticle(ctx context.Context, i int, article common.Article) ([]common.Scene, error) {
+	if g.mock {
+		return g.mockScene(i, article.Title, articleSceneID(i, article.Title))
 	}
 
-	// Try to extract description
-	descStart := strings.Index(response, "Description:")
-	if descStart != -1 {
-		descEnd := strings.Index(response[descStart:], "Mood:")
-		if descEnd != -1 {
-			description = strings.TrimSpace(response[descStart+12 : descStart+descEnd])
-		}
+	// Prepare the prompt for Claude
+	prompt, err := g.prompts.Render(prompts.SceneArticle, promptData{Article: article, Tool: sceneToolName})
+	if err != nil {
+		return nil, err
 	}
 
-	// Try to extract mood
-	moodStart := strings.Index(response, "Mood:")
-	if moodStart != -1 {
-		moodEnd := strings.Index(response[moodStart:], "\n")
-		if moodEnd != -1 {
-			mood = strings.TrimSpace(response[moodStart+5 : moodStart+moodEnd])
-		} else {
-			mood = strings.TrimSpace(response[moodStart+5:])
-		}
+	// Ask Claude for the scene
+	scene, err := g.requestScene(ctx, prompt)
+	if err != nil {
+		return nil, err
 	}
 
-	// If we couldn't extract anything meaningful, use the whole response as description
-	if title == "News Scene: "+imageName && description == "A visual representation of a news story." {
-		description = strings.TrimSpace(response)
-	}
+	// Add source information
+	scene.SourceTitle = article.Title
+	scene.ID = articleSceneID(i, article.Title)
 
-	return common.Scene{
-		Title:       title,
-		Description: description,
-		Mood:        mood,
-		SourceTitle: imageName,
-		ID:          generateSceneID(imageName),
-	}, nil
+	return []common.Scene{scene}, nil
 }
 
 // generateSceneID generates a unique ID for a scene based on the image name
 func generateSceneID(imageName string) string {
 	// Remove extension
-	name := strings.TrimSuffix(imageName, filepath.Ext(imageName))
+	return sceneIDFromName(strings.TrimSuffix(imageName, filepath.Ext(imageName)))
+}
+
+// articleSceneID generates the ID of the i-th article's scene. The position keeps apart articles
+// with the same title, whose images and videos would otherwise overwrite each other.
+func articleSceneID(i int, title string) string {
+	return sceneIDFromName(fmt.Sprintf("%02d_%s", i+1, title))
+}
 
+// sceneIDFromName generates a scene ID from an image name or article title
+func sceneIDFromName(name string) string {
 	// Replace spaces and special characters
-	name = strings.ReplaceAll(name, " ", "_")
-	name = strings.ReplaceAll(name, "-", "_")
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
 
 	return "scene_" + name
 }
 
-// callClaudeAPI calls Claude's API with the prompt and image
-func (g *Generator) callClaudeAPI(ctx context.Context, prompt, base64Image string) (string, error) {
-	// Claude API endpoint
-	apiURL := "https://api.anthropic.com/v1/messages"
-
-	// Prepare the request body
-	requestBody := map[string]interface{}{
-		"model":      "claude-3-opus-20240229",
-		"max_tokens": 4000,
-		"messages": []map[string]interface{}{
-			{
-				"role": "user",
-				"content": []map[string]interface{}{
-					{
-						"type": "text",
-						"text": prompt,
-					},
-					{
-						"type": "image",
-						"source": map[string]string{
-							"type":       "base64",
-							"media_type": "image/png",
-							"data":       base64Image,
-						},
-					},
-				},
-			},
-		},
-	}
-
-	// Convert request body to JSON
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request body: %w", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", g.config.ClaudeKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-
-	// Send request
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse response
-	var responseData map[string]interface{}
-	if err := json.Unmarshal(body, &responseData); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Extract content
-	content, ok := responseData["content"].([]interface{})
-	if !ok || len(content) == 0 {
-		return "", fmt.Errorf("invalid response format")
-	}
-
-	// Get the text from the first content item
-	contentItem, ok := content[0].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("invalid content format")
+// mockScene returns the mock scene for the i-th input, cycling through the mock scenes, with
+// the source and ID of that input so that every scene can be told apart
+func (g *Generator) mockScene(i int, sourceTitle, id string) ([]common.Scene, error) {
+	mockScenes := g.getMockScenes()
+	if len(mockScenes) == 0 {
+		return nil, fmt.Errorf("no mock scenes available")
 	}
 
-	text, ok := contentItem["text"].(string)
-	if !ok {
-		return "", fmt.Errorf("invalid text format")
-	}
-
-	return text, nil
+	scene := mockScenes[i%len(mockScenes)]
+	scene.SourceTitle = sourceTitle
+	scene.ID = id
+	return []common.Scene{scene}, nil
 }
 
 // getMockScenes returns mock scene descriptions for testing
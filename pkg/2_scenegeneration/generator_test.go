@@ -0,0 +1,244 @@
+package scenegeneration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/llm"
+)
+
+func TestGenerator_GenerateConcurrently_PreservesOrder(t *testing.T) {
+	g := &Generator{config: config.SceneGenerationConfig{Concurrency: 4}}
+
+	var running, peak int32
+	scenes, err := g.generateConcurrently(context.Background(), 10, func(ctx context.Context, i int) ([]common.Scene, error) {
+		// Track how many calls run at once
+		now := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if now <= old || atomic.CompareAndSwapInt32(&peak, old, now) {
+				break
+			}
+		}
+		defer atomic.AddInt32(&running, -1)
+
+		// Later inputs finish first
+		time.Sleep(time.Duration(10-i) * time.Millisecond)
+		if i == 3 {
+			return nil, fmt.Errorf("failed")
+		}
+		return []common.Scene{{ID: fmt.Sprint(i)}}, nil
+	})
+	// The failed input is reported, and the rest keep their order
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("generateConcurrently() error = %v, want the failed input reported", err)
+	}
+	if len(scenes) != 9 {
+		t.Fatalf("got %d scenes, want 9", len(scenes))
+	}
+	want := []string{"0", "1", "2", "4", "5", "6", "7", "8", "9"}
+	for i, scene := range scenes {
+		if scene.ID != want[i] {
+			t.Errorf("scene %d has ID %s, want %s", i, scene.ID, want[i])
+		}
+	}
+
+	if peak > 4 {
+		t.Errorf("ran %d calls at once, want at most 4", peak)
+	}
+}
+
+func TestGenerator_GenerateConcurrently_Cancelled(t *testing.T) {
+	g := &Generator{config: config.SceneGenerationConfig{Concurrency: 2}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var calls int32
+	_, err := g.generateConcurrently(ctx, 100, func(ctx context.Context, i int) ([]common.Scene, error) {
+		// Both workers block until the second call cancels the run
+		if atomic.AddInt32(&calls, 1) == 2 {
+			cancel()
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	if err != context.Canceled {
+		t.Errorf("generateConcurrently() error = %v, want context.Canceled", err)
+	}
+	if calls > 2 {
+		t.Errorf("made %d calls after cancellation", calls)
+	}
+}
+
+func TestGenerator_Generate_TextMode(t *testing.T) {
+	// The mock generator gives every article its own fixed scene
+	cfg := config.SceneGenerationConfig{
+		MaxScenes:   2,
+		Concurrency: 2,
+	}
+	generator := NewMock(cfg)
+
+	content := common.Content{
+		Articles: []common.Article{
+			{Title: "Ceasefire talks resume"},
+			{Title: "Bank holds rates"},
+			{Title: "Probe lands on moon"},
+		},
+	}
+
+	scenes, err := generator.Generate(context.Background(), content)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(scenes) != 2 {
+		t.Fatalf("got %d scenes, want MaxScenes = 2", len(scenes))
+	}
+
+	// Each scene is told apart by the article it came from
+	if scenes[0].ID != "scene_01_Ceasefire_talks_resume" || scenes[1].ID != "scene_02_Bank_holds_rates" {
+		t.Errorf("scene IDs = %q, %q, want one per article", scenes[0].ID, scenes[1].ID)
+	}
+	if scenes[0].Title == scenes[1].Title || scenes[1].SourceTitle != "Bank holds rates" {
+		t.Errorf("scenes = %+v, want a different mock scene per article", scenes)
+	}
+}
+
+func TestGenerator_Generate_SameTitle(t *testing.T) {
+	// Two outlets with the same headline still get a scene each
+	generator := NewMock(config.SceneGenerationConfig{MaxScenes: 2})
+	content := common.Content{Articles: []common.Article{{Title: "Bank holds rates"}, {Title: "Bank holds rates"}}}
+
+	scenes, err := generator.Generate(context.Background(), content)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(scenes) != 2 || scenes[0].ID == scenes[1].ID {
+		t.Errorf("scenes = %+v, want two distinct IDs", scenes)
+	}
+}
+
+// sceneInput returns a tool input for a scene with a description of the given number of words
+func sceneInput(words int) map[string]interface{} {
+	return map[string]interface{}{
+		"title":       "Ceasefire talks resume",
+		"description": strings.TrimSpace(strings.Repeat("word ", words)),
+		"mood":        "Tense but hopeful",
+		"setting":     "A conference hall in Geneva at dusk",
+		"subjects":    []string{"negotiators", "flags"},
+		"camera":      "wide shot",
+	}
+}
+
+// newSceneServer stands in for the Messages API, answering with the given tool inputs in turn
+func newSceneServer(t *testing.T, inputs []map[string]interface{}, requests *[]llm.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request llm.Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, request)
+
+		input, _ := json.Marshal(inputs[len(*requests)-1])
+		json.NewEncoder(w).Encode(llm.Response{
+			StopReason: "tool_use",
+			Content: []llm.ContentBlock{
+				{Type: "tool_use", ID: fmt.Sprintf("toolu_%d", len(*requests)), Name: sceneToolName, Input: input},
+			},
+		})
+	}))
+}
+
+func TestGenerator_RequestScene_RepromptsOnValidationError(t *testing.T) {
+	var requests []llm.Request
+	server := newSceneServer(t, []map[string]interface{}{sceneInput(10), sceneInput(120)}, &requests)
+	defer server.Close()
+
+	g := New(config.SceneGenerationConfig{ClaudeKey: "test-key"}).(*Generator)
+	g.client.BaseURL = server.URL
+
+	scene, err := g.requestScene(context.Background(), "Describe the story")
+	if err != nil {
+		t.Fatalf("requestScene() error = %v", err)
+	}
+	if scene.Title != "Ceasefire talks resume" || scene.Setting == "" || len(scene.Subjects) != 2 || scene.Camera != "wide shot" {
+		t.Errorf("unexpected scene: %+v", scene)
+	}
+
+	// The first request forces the tool call
+	if len(requests) != 2 {
+		t.Fatalf("made %d requests, want 2", len(requests))
+	}
+	if choice := requests[0].ToolChoice; choice == nil || choice.Type != "tool" || choice.Name != sceneToolName {
+		t.Errorf("tool choice = %+v", choice)
+	}
+
+	// The retry carries the rejected call and the validation error
+	messages := requests[1].Messages
+	if len(messages) != 3 || messages[1].Role != "assistant" {
+		t.Fatalf("unexpected retry messages: %+v", messages)
+	}
+	result := messages[2].Content[0]
+	if result.Type != "tool_result" || result.ToolUseID != "toolu_1" || !result.IsError || !strings.Contains(result.Content, "description has 10 words") {
+		t.Errorf("unexpected tool result: %+v", result)
+	}
+}
+
+func TestGenerator_Generate_InvalidSceneIsError(t *testing.T) {
+	var requests []llm.Request
+	invalid := sceneInput(120)
+	delete(invalid, "subjects")
+	server := newSceneServer(t, []map[string]interface{}{invalid, invalid}, &requests)
+	defer server.Close()
+
+	g := New(config.SceneGenerationConfig{ClaudeKey: "test-key", MaxAttempts: 2}).(*Generator)
+	g.client.BaseURL = server.URL
+
+	content := common.Content{Articles: []common.Article{{Title: "Ceasefire talks resume"}}}
+	scenes, err := g.Generate(context.Background(), content)
+	if err == nil {
+		t.Fatalf("Generate() returned %d scenes for an invalid scene, want error", len(scenes))
+	}
+	if !strings.Contains(err.Error(), "at least one subject is required") {
+		t.Errorf("error does not explain the failure: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("made %d requests, want MaxAttempts = 2", len(requests))
+	}
+}
+
+func TestGenerator_Generate_ScreenshotMediaType(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "headline.png"), []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []llm.Request
+	server := newSceneServer(t, []map[string]interface{}{sceneInput(120)}, &requests)
+	defer server.Close()
+
+	g := New(config.SceneGenerationConfig{ClaudeKey: "test-key", Mode: "screenshots", InputDir: dir, MaxScenes: 1}).(*Generator)
+	g.client.BaseURL = server.URL
+
+	if _, err := g.Generate(context.Background(), common.Content{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// The screenshot is labelled by its content, not its name
+	blocks := requests[0].Messages[0].Content
+	if len(blocks) != 2 || blocks[1].Source == nil || blocks[1].Source.MediaType != "image/jpeg" {
+		t.Errorf("image block = %+v, want a JPEG", blocks)
+	}
+}
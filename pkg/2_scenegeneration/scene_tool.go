@@ -0,0 +1,137 @@
+package scenegeneration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/llm"
+)
+
+// sceneToolName is the tool Claude is forced to call with the scene
+const sceneToolName = "record_scene"
+
+// defaultMaxAttempts is used when no attempt limit is configured
+const defaultMaxAttempts = 3
+
+// Limits enforced on the scene Claude records
+const (
+	minDescriptionWords = 60
+	maxDescriptionWords = 300
+	maxTitleLength      = 120
+	maxMoodLength       = 60
+	maxSubjects         = 8
+)
+
+// sceneTool describes the scene fields to Claude as a JSON schema
+var sceneTool = llm.Tool{
+	Name:        sceneToolName,
+	Description: "Record a single visual scene description for a news story.",
+	InputSchema: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "title": {"type": "string", "description": "A short title that captures the essence of the news story"},
+    "description": {"type": "string", "description": "A detailed visual description of 150-200 words that a text-to-image AI can render, with no text or lettering in the image"},
+    "mood": {"type": "string", "description": "The mood or atmosphere of the scene, e.g. tense, hopeful, somber"},
+    "setting": {"type": "string", "description": "Where and when the scene takes place"},
+    "subjects": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 8, "description": "The main people or objects in the scene"},
+    "palette": {"type": "string", "description": "The dominant colours and lighting"},
+    "camera": {"type": "string", "description": "The camera framing and angle, e.g. aerial wide shot, close-up"}
+  },
+  "required": ["title", "description", "mood", "setting", "subjects"]
+}`),
+}
+
+// requestScene asks Claude to record a scene with the scene tool, re-prompting with the
+// validation errors until the scene is valid or the attempts run out
+func (g *Generator) requestScene(ctx context.Context, prompt string, images ...llm.ContentBlock) (common.Scene, error) {
+	maxAttempts := g.config.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	request := llm.Request{
+		Messages: []llm.Message{
+			{Role: "user", Content: append([]llm.ContentBlock{llm.TextBlock(prompt)}, images...)},
+		},
+	}
+
+	var scene common.Scene
+	err := g.client.CallTool(ctx, request, sceneTool, maxAttempts, func(input json.RawMessage) error {
+		var err error
+		if scene, err = decodeScene(input); err != nil {
+			return err
+		}
+		return validateScene(scene)
+	})
+	if err != nil {
+		return common.Scene{}, err
+	}
+
+	return scene, nil
+}
+
+// decodeScene converts the tool input into a scene
+func decodeScene(input json.RawMessage) (common.Scene, error) {
+	var scene common.Scene
+	if err := json.Unmarshal(input, &scene); err != nil {
+		return common.Scene{}, fmt.Errorf("input does not match the schema: %v", err)
+	}
+
+	scene.Title = strings.TrimSpace(scene.Title)
+	scene.Description = strings.TrimSpace(scene.Description)
+	scene.Mood = strings.TrimSpace(scene.Mood)
+	scene.Setting = strings.TrimSpace(scene.Setting)
+	scene.Palette = strings.TrimSpace(scene.Palette)
+	scene.Camera = strings.TrimSpace(scene.Camera)
+
+	return scene, nil
+}
+
+// validateScene checks a scene against the limits in the prompt, listing every problem found
+func validateScene(scene common.Scene) error {
+	var problems []string
+
+	switch {
+	case scene.Title == "":
+		problems = append(problems, "title is required")
+	case len(scene.Title) > maxTitleLength:
+		problems = append(problems, fmt.Sprintf("title must be at most %d characters", maxTitleLength))
+	}
+
+	if words := len(strings.Fields(scene.Description)); words < minDescriptionWords || words > maxDescriptionWords {
+		problems = append(problems, fmt.Sprintf("description has %d words, it must have between %d and %d", words, minDescriptionWords, maxDescriptionWords))
+	}
+
+	switch {
+	case scene.Mood == "":
+		problems = append(problems, "mood is required")
+	case len(scene.Mood) > maxMoodLength:
+		problems = append(problems, fmt.Sprintf("mood must be at most %d characters", maxMoodLength))
+	}
+
+	if scene.Setting == "" {
+		problems = append(problems, "setting is required")
+	}
+
+	switch {
+	case len(scene.Subjects) == 0:
+		problems = append(problems, "at least one subject is required")
+	case len(scene.Subjects) > maxSubjects:
+		problems = append(problems, fmt.Sprintf("at most %d subjects are allowed", maxSubjects))
+	}
+	for _, subject := range scene.Subjects {
+		if strings.TrimSpace(subject) == "" {
+			problems = append(problems, "subjects must not be empty")
+			break
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package imagecreation
+
+import (
+	"fmt"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/registry"
+)
+
+// Backends are the image creators that can be selected by name in the configuration
+var Backends = registry.New("image creation", "huggingface",
+	registry.Backend[config.ImageCreationConfig, common.ImageCreator]{
+		Name:        "huggingface",
+		Kind:        registry.Provider,
+		Description: "text-to-image models on the Hugging Face Inference API",
+		New: func(config config.ImageCreationConfig) (common.ImageCreator, error) {
+			if config.HuggingFaceAPIKey == "" {
+				return nil, fmt.Errorf("no Hugging Face API key provided")
+			}
+			return New(config), nil
+		},
+	},
+	registry.Backend[config.ImageCreationConfig, common.ImageCreator]{
+		Name:        "mock",
+		Kind:        registry.Mock,
+		Description: "gradient placeholder images for offline runs",
+		New: func(config config.ImageCreationConfig) (common.ImageCreator, error) {
+			return NewMock(config), nil
+		},
+	},
+)
@@ -7,6 +7,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"log"
 	"net/http"
@@ -18,34 +21,49 @@ import (
 	"github.com/google/uuid"
 	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/prompts"
+	"github.com/iantozer/stitch-up/pkg/transport"
 )
 
 // Creator implements the ImageCreator interface
 type Creator struct {
-	config config.ImageCreationConfig
-	client *http.Client
+	config  config.ImageCreationConfig
+	client  *http.Client
+	prompts *prompts.Set
+	mock    bool // create placeholder images instead of calling Hugging Face
+}
+
+// promptData is passed to the image prompt template
+type promptData struct {
+	common.Scene
+	Model string // the Hugging Face model the prompt is for
 }
 
 // New creates a new image creator
 func New(config config.ImageCreationConfig) common.ImageCreator {
 	return &Creator{
-		config: config,
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		config:  config,
+		client:  transport.NewClient(transport.HuggingFace, 60*time.Second),
+		prompts: prompts.New(config.PromptDir),
 	}
 }
 
+// NewMock creates an image creator that draws placeholder images without calling Hugging Face
+func NewMock(config config.ImageCreationConfig) common.ImageCreator {
+	c := New(config).(*Creator)
+	c.mock = true
+	return c
+}
+
 // Create generates images from scene descriptions using Hugging Face's API
 func (c *Creator) Create(ctx context.Context, scenes []common.Scene) ([]common.Image, error) {
-	log.Println("Creating images from scene descriptions using Hugging Face's API")
-
-	// Check if Hugging Face API key is provided
-	if c.config.HuggingFaceAPIKey == "" {
-		log.Println("No Hugging Face API key provided, using placeholder images")
+	if c.mock {
+		log.Println("Creating placeholder images from scene descriptions")
 		return c.createPlaceholderImages(scenes)
 	}
 
+	log.Println("Creating images from scene descriptions using Hugging Face's API")
+
 	var images []common.Image
 
 	for _, scene := range scenes {
@@ -82,12 +100,11 @@ func (c *Creator) Create(ctx context.Context, scenes []common.Scene) ([]common.I
 			Path:        imagePath,
 			SceneID:     scene.ID,
 			Description: scene.Description,
+			Mood:        scene.Mood,
+			Camera:      scene.Camera,
 		})
 
 		log.Printf("Created image: %s", imagePath)
-
-		// Add a small delay between API calls to avoid rate limiting
-		time.Sleep(2 * time.Second)
 	}
 
 	if len(images) == 0 {
@@ -101,7 +118,10 @@ func (c *Creator) Create(ctx context.Context, scenes []common.Scene) ([]common.I
 // generateImageWithHuggingFace generates an image using Hugging Face's API
 func (c *Creator) generateImageWithHuggingFace(ctx context.Context, scene common.Scene) ([]byte, error) {
 	// Prepare the prompt
-	prompt := c.preparePrompt(scene)
+	prompt, err := c.preparePrompt(scene)
+	if err != nil {
+		return nil, err
+	}
 
 	// Hugging Face API endpoint for the specified model
 	apiURL := fmt.Sprintf("https://api-inference.huggingface.co/models/%s", c.config.HuggingFaceModel)
@@ -179,25 +199,12 @@ func (c *Creator) generateImageWithHuggingFace(ctx context.Context, scene common
 	return body, nil
 }
 
-// preparePrompt prepares the prompt for Hugging Face's image generation API
-func (c *Creator) preparePrompt(scene common.Scene) string {
-	// Start with the scene description
-	prompt := scene.Description
-
-	// Add the mood if available
-	if scene.Mood != "" {
-		prompt += fmt.Sprintf(" The mood is %s.", scene.Mood)
-	}
-
-	// Add some style guidance based on the model
-	if strings.Contains(c.config.HuggingFaceModel, "stable-diffusion") {
-		prompt += " Photorealistic, high detail, dramatic lighting, 8k, cinematic, professional photography."
-	}
-
-	return prompt
+// preparePrompt prepares the prompt for Hugging Face's image generation API from the image template
+func (c *Creator) preparePrompt(scene common.Scene) (string, error) {
+	return c.prompts.Render(prompts.Image, promptData{Scene: scene, Model: c.config.HuggingFaceModel})
 }
 
-// createPlaceholderImages creates placeholder images for testing
+// createPlaceholderImages creates placeholder images for offline runs
 func (c *Creator) createPlaceholderImages(scenes []common.Scene) ([]common.Image, error) {
 	var images []common.Image
 
@@ -219,6 +226,8 @@ func (c *Creator) createPlaceholderImages(scenes []common.Scene) ([]common.Image
 			Path:        imagePath,
 			SceneID:     scene.ID,
 			Description: scene.Description,
+			Mood:        scene.Mood,
+			Camera:      scene.Camera,
 		})
 
 		log.Printf("Created placeholder image: %s", imagePath)
@@ -230,7 +239,8 @@ func (c *Creator) createPlaceholderImages(scenes []common.Scene) ([]common.Image
 	return images, nil
 }
 
-// createPlaceholderImage creates an empty file as a placeholder
+// createPlaceholderImage creates a plain gradient PNG as a placeholder, so later stages have a
+// real image to work with
 func createPlaceholderImage(path string) error {
 	// Ensure the directory exists
 	dir := filepath.Dir(path)
@@ -238,16 +248,26 @@ func createPlaceholderImage(path string) error {
 		return err
 	}
 
-	// Create an empty file
+	img := image.NewRGBA(image.Rect(0, 0, 640, 360))
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.Set(x, y, color.RGBA{
+				R: uint8(40 + 120*x/bounds.Dx()),
+				G: uint8(60 + 80*y/bounds.Dy()),
+				B: 140,
+				A: 255,
+			})
+		}
+	}
+
 	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	// Write a placeholder message
-	_, err = file.WriteString("This is a placeholder for an image that would be generated by Hugging Face's API")
-	return err
+	return png.Encode(file, img)
 }
 
 // sanitizeFilename removes characters that are not allowed in filenames
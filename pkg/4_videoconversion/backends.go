@@ -0,0 +1,76 @@
+package videoconversion
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/iantozer/stitch-up/internal/ffmpeg"
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/registry"
+)
+
+// Video conversion backends
+const (
+	BackendRunway    = "runway"
+	BackendReplicate = "replicate"
+	BackendLocal     = "local"
+	BackendNode      = "node"
+	BackendMock      = "mock"
+)
+
+// Backends are the video converters that can be selected by name in the configuration
+var Backends = registry.New("video conversion", BackendRunway,
+	registry.Backend[config.VideoConversionConfig, common.VideoConverter]{
+		Name:        BackendRunway,
+		Kind:        registry.Provider,
+		Description: "Runway image-to-video jobs, reattached to when run again",
+		New: func(config config.VideoConversionConfig) (common.VideoConverter, error) {
+			if config.RunwayAPIKey == "" {
+				return nil, fmt.Errorf("no Runway API key provided")
+			}
+			return New(config), nil
+		},
+	},
+	registry.Backend[config.VideoConversionConfig, common.VideoConverter]{
+		Name:        BackendReplicate,
+		Kind:        registry.Provider,
+		Description: "Stable Video Diffusion, or another configured model, on Replicate",
+		New: func(config config.VideoConversionConfig) (common.VideoConverter, error) {
+			if config.ReplicateAPIToken == "" {
+				return nil, fmt.Errorf("no Replicate API token provided")
+			}
+			return NewReplicateConverter(config), nil
+		},
+	},
+	registry.Backend[config.VideoConversionConfig, common.VideoConverter]{
+		Name:        BackendLocal,
+		Kind:        registry.Local,
+		Description: "Ken Burns pans and zooms over the images rendered with ffmpeg",
+		New: func(config config.VideoConversionConfig) (common.VideoConverter, error) {
+			if _, err := ffmpeg.Find(config.FFMPEGPath); err != nil {
+				return nil, err
+			}
+			return NewKenBurns(config), nil
+		},
+	},
+	registry.Backend[config.VideoConversionConfig, common.VideoConverter]{
+		Name:        BackendNode,
+		Kind:        registry.Node,
+		Description: "the Node.js Runway script",
+		New: func(config config.VideoConversionConfig) (common.VideoConverter, error) {
+			if _, err := exec.LookPath("node"); err != nil {
+				return nil, fmt.Errorf("Node.js is not installed: %w", err)
+			}
+			return NewNodeWrapper(config), nil
+		},
+	},
+	registry.Backend[config.VideoConversionConfig, common.VideoConverter]{
+		Name:        BackendMock,
+		Kind:        registry.Mock,
+		Description: "placeholder files in place of videos, for the mock assembler",
+		New: func(config config.VideoConversionConfig) (common.VideoConverter, error) {
+			return NewMock(config), nil
+		},
+	},
+)
@@ -1,383 +1,329 @@
 package videoconversion
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/iantozer/stitch-up/internal/workpool"
 	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
 )
 
+// Defaults for waiting on Runway jobs
+const (
+	defaultConcurrency  = 4
+	defaultPollInterval = 5 * time.Second
+	defaultMaxWait      = 15 * time.Minute
+)
+
+// cancelTimeout is how long cancelling the outstanding jobs may take once the run is cancelled
+const cancelTimeout = 30 * time.Second
+
 // Converter implements the VideoConverter interface
 type Converter struct {
-	config config.VideoConversionConfig
-	client *http.Client
+	config       config.VideoConversionConfig
+	runway       *RunwayClient
+	pollInterval time.Duration
+	maxWait      time.Duration
 }
 
-// New creates a new video converter
+// New creates a video converter that generates the videos with Runway
 func New(config config.VideoConversionConfig) common.VideoConverter {
-	return NewConverter(config)
+	runway := NewRunwayClient(config.RunwayAPIKey)
+	if config.RunwayBaseURL != "" {
+		runway.BaseURL = config.RunwayBaseURL
+	}
+	if config.RunwayModel != "" {
+		runway.Model = config.RunwayModel
+	}
+
+	converter := &Converter{
+		config:       config,
+		runway:       runway,
+		pollInterval: time.Duration(config.PollInterval) * time.Second,
+		maxWait:      time.Duration(config.MaxWait) * time.Second,
+	}
+	if converter.config.Concurrency <= 0 {
+		converter.config.Concurrency = defaultConcurrency
+	}
+	if converter.pollInterval <= 0 {
+		converter.pollInterval = defaultPollInterval
+	}
+	if converter.maxWait <= 0 {
+		converter.maxWait = defaultMaxWait
+	}
+	return converter
 }
 
-// Convert converts images to videos using Runway ML
+// Convert converts images to videos using Runway ML. A job is submitted for every image before
+// any is waited on, and then all of them are checked together. The jobs are recorded in a job
+// file in the output directory, so a run that crashes or times out reattaches to them when run
+// again rather than paying for them twice. Cancelling the context cancels the jobs.
 func (c *Converter) Convert(ctx context.Context, images []common.Image) ([]common.Video, error) {
 	log.Println("Converting images to videos using Runway ML")
 
-	// Check if Runway API key is provided
 	if c.config.RunwayAPIKey == "" {
-		log.Println("No Runway API key provided, using placeholder videos")
-		return c.createPlaceholderVideos(images)
+		return nil, fmt.Errorf("no Runway API key provided")
 	}
 
-	var videos []common.Video
-
-	for _, image := range images {
-		log.Printf("Generating video for image: %s", image.Path)
-
-		// Read the image file
-		imageData, err := os.ReadFile(image.Path)
-		if err != nil {
-			log.Printf("Error reading image %s: %v", image.Path, err)
-			continue
-		}
-
-		// Generate video using Runway ML
-		videoData, err := c.generateVideoWithRunway(ctx, imageData, image.Description)
-		if err != nil {
-			log.Printf("Error generating video for image %s: %v", image.Path, err)
-			continue
-		}
-
-		// Generate a unique filename
-		baseFilename := filepath.Base(image.Path)
-		baseFilename = strings.TrimSuffix(baseFilename, filepath.Ext(baseFilename))
-		filename := fmt.Sprintf("video_%s_%s.mp4", baseFilename, uuid.New().String()[:8])
-
-		videoPath := filepath.Join(c.config.OutputDir, filename)
+	jobs, err := loadJobFile(filepath.Join(c.config.OutputDir, jobFileName))
+	if err != nil {
+		return nil, err
+	}
 
-		// Ensure the directory exists
-		dir := filepath.Dir(videoPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			log.Printf("Error creating directory for video %s: %v", videoPath, err)
-			continue
-		}
+	// Submit a job for every image that has none yet, then wait for them all
+	workpool.ForEach(ctx, c.config.Concurrency, len(images), func(ctx context.Context, i int) {
+		c.submitJob(ctx, jobs, images[i])
+	})
+	if ctx.Err() == nil {
+		c.waitForJobs(ctx, jobs, images)
+	}
+	if err := ctx.Err(); err != nil {
+		c.cancelJobs(ctx, jobs, images)
+		return nil, err
+	}
 
-		// Save the video
-		if err := os.WriteFile(videoPath, videoData, 0644); err != nil {
-			log.Printf("Error saving video %s: %v", videoPath, err)
-			continue
+	var videos []common.Video
+	for _, image := range images {
+		// A video that is gone and could not be generated again is left for a rerun
+		if j, ok := jobs.get(image.Path); ok && j.VideoPath != "" && fileExists(j.VideoPath) {
+			videos = append(videos, common.Video{
+				Path:    j.VideoPath,
+				ImageID: image.SceneID,
+				Length:  j.length(c.config.VideoLength),
+			})
 		}
-
-		videos = append(videos, common.Video{
-			Path:    videoPath,
-			ImageID: image.SceneID,
-			Length:  c.config.VideoLength,
-		})
-
-		log.Printf("Created video: %s", videoPath)
-
-		// Add a small delay between API calls to avoid rate limiting
-		time.Sleep(2 * time.Second)
 	}
 
 	if len(videos) == 0 {
 		return videos, fmt.Errorf("no videos created")
 	}
 
+	// Keep the job file while any image is still missing its video, so a rerun can retry it
+	if len(videos) == len(images) {
+		if err := jobs.remove(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
 	log.Printf("Created %d videos", len(videos))
 	return videos, nil
 }
 
-// generateVideoWithRunway generates a video from an image using Runway ML's API
-func (c *Converter) generateVideoWithRunway(ctx context.Context, imageData []byte, description string) ([]byte, error) {
-	// Runway ML API endpoint for image-to-video
-	apiURL := "https://api.dev.runwayml.com/v1/image_to_video"
-
-	// Encode the image as base64
-	base64Image := c.encodeImageToBase64(imageData)
-
-	// Prepare the request body with the new format
-	requestBody := map[string]interface{}{
-		"promptImage": base64Image,
-		"promptText":  description,
-		"model":       "gen3a_turbo",
-	}
-
-	// Convert request body to JSON
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// submitJob starts a Runway job for an image, unless an earlier run already started one
+func (c *Converter) submitJob(ctx context.Context, jobs *jobFile, image common.Image) {
+	if j, ok := jobs.get(image.Path); ok {
+		switch {
+		case j.VideoPath != "" && fileExists(j.VideoPath):
+			log.Printf("Reusing video %s for image: %s", j.VideoPath, image.Path)
+			return
+		case j.VideoPath == "" && j.Error == "":
+			log.Printf("Reattaching to job %s for image: %s", j.TaskID, image.Path)
+			return
+		}
+		// The job failed or its video is gone, so start again
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.config.RunwayAPIKey)
-	req.Header.Set("X-Runway-Version", "2024-11-06")
+	log.Printf("Generating video for image: %s", image.Path)
 
-	// Send request
-	resp, err := c.client.Do(req)
+	// Read the image file
+	imageData, err := os.ReadFile(image.Path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		log.Printf("Error reading image %s: %v", image.Path, err)
+		return
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	// Start the job and record it before anything else can go wrong
+	duration := runwayDuration(c.config.VideoLength)
+	taskID, err := c.runway.ImageToVideo(ctx, ImageToVideoRequest{
+		PromptImage: encodeImageToBase64(imageData),
+		PromptText:  image.Description,
+		Duration:    duration,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		log.Printf("Error generating video for image %s: %v", image.Path, err)
+		return
 	}
+	log.Printf("Started job %s for image: %s", taskID, image.Path)
 
-	// Check response status
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	// Log the full response for debugging
-	log.Printf("API Response: %s", string(body))
-
-	// Parse response
-	var responseData map[string]interface{}
-	if err := json.Unmarshal(body, &responseData); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := jobs.set(image.Path, job{TaskID: taskID, SceneID: image.SceneID, Duration: duration}); err != nil {
+		log.Printf("Warning: job %s for image %s was not recorded: %v", taskID, image.Path, err)
 	}
+}
 
-	// Check for errors in the response
-	if errMsg, ok := responseData["error"].(string); ok {
-		return nil, fmt.Errorf("API error: %s", errMsg)
-	}
+// waitForJobs checks every unfinished job each poll interval, downloading the videos of the
+// jobs that succeed, until all are finished, the maximum wait has passed or the context is
+// cancelled
+func (c *Converter) waitForJobs(ctx context.Context, jobs *jobFile, images []common.Image) {
+	deadline := time.Now().Add(c.maxWait)
 
-	// Get the job ID from the response
-	jobID, ok := responseData["id"].(string)
-	if !ok {
-		// Try alternative field names
-		if jobID, ok = responseData["jobId"].(string); !ok {
-			return nil, fmt.Errorf("no job ID in response: %v", responseData)
+	for {
+		pending := unfinished(jobs, images)
+		if len(pending) == 0 {
+			return
 		}
-	}
-
-	log.Printf("Job ID: %s", jobID)
 
-	// Poll for the result
-	return c.pollForVideo(ctx, jobID)
-}
-
-// encodeImageToBase64 encodes an image as a base64 data URI
-func (c *Converter) encodeImageToBase64(imageData []byte) string {
-	// Determine the MIME type based on the image data
-	mimeType := "image/jpeg" // Default to JPEG
-	if len(imageData) > 2 {
-		// Check for PNG signature
-		if imageData[0] == 0x89 && imageData[1] == 0x50 && imageData[2] == 0x4E {
-			mimeType = "image/png"
+		if time.Now().After(deadline) {
+			log.Printf("Timed out after %s waiting for %d videos; run again to reattach to their jobs", c.maxWait, len(pending))
+			return
 		}
-	}
 
-	// Encode the image data as base64
-	base64Encoded := base64.StdEncoding.EncodeToString(imageData)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.pollInterval):
+		}
 
-	// Return as a data URI
-	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64Encoded)
+		tasks := make([]Task, len(pending))
+		workpool.ForEach(ctx, c.config.Concurrency, len(pending), func(ctx context.Context, i int) {
+			tasks[i] = c.checkJob(ctx, jobs, pending[i])
+		})
+		logProgress(tasks)
+	}
 }
 
-// pollForVideo polls the Runway ML API for the generated video
-func (c *Converter) pollForVideo(ctx context.Context, jobID string) ([]byte, error) {
-	// Runway ML API endpoint for checking job status
-	apiURL := fmt.Sprintf("https://api.dev.runwayml.com/v1/image_to_video/%s", jobID)
+// checkJob checks an image's job once, downloading its video if it has succeeded, and returns
+// what it found
+func (c *Converter) checkJob(ctx context.Context, jobs *jobFile, image common.Image) Task {
+	j, _ := jobs.get(image.Path)
 
-	log.Printf("Polling URL: %s", apiURL)
-
-	// Maximum number of attempts
-	maxAttempts := 60 // Videos can take longer to generate
+	task, err := c.runway.Task(ctx, j.TaskID)
+	if err != nil {
+		// Tried again at the next check
+		log.Printf("Error checking job %s for image %s: %v", j.TaskID, image.Path, err)
+		return Task{}
+	}
 
-	// Poll interval
-	pollInterval := 5 * time.Second
+	switch task.Status {
+	case TaskSucceeded:
+		// Generate a unique filename
+		baseFilename := filepath.Base(image.Path)
+		baseFilename = strings.TrimSuffix(baseFilename, filepath.Ext(baseFilename))
+		filename := fmt.Sprintf("video_%s_%s.mp4", baseFilename, uuid.New().String()[:8])
+		videoPath := filepath.Join(c.config.OutputDir, filename)
 
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		// Create HTTP request
-		req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
+		if err := c.runway.Download(ctx, task.Output[0], videoPath); err != nil {
+			log.Printf("Error downloading video for image %s: %v", image.Path, err)
+			return Task{}
 		}
+		j.VideoPath = videoPath
+		log.Printf("Created video: %s", videoPath)
 
-		// Set headers
-		req.Header.Set("Authorization", "Bearer "+c.config.RunwayAPIKey)
-		req.Header.Set("X-Runway-Version", "2024-11-06")
-
-		// Log headers for debugging
-		log.Printf("Request headers: %v", req.Header)
-
-		// Send request
-		resp, err := c.client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to send request: %w", err)
+	case TaskFailed, TaskCancelled:
+		j.Error = strings.ToLower(string(task.Status))
+		if task.Failure != "" {
+			j.Error = task.Failure
 		}
+		log.Printf("Error generating video for image %s: job %s %s: %s", image.Path, j.TaskID, strings.ToLower(string(task.Status)), j.Error)
 
-		// Read response
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
-		}
+	default:
+		return task
+	}
 
-		// Check response status
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("Polling attempt %d: status code %d, body: %s", attempt, resp.StatusCode, string(body))
+	if err := jobs.set(image.Path, j); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return task
+}
 
-			// If we get a 404, the job ID might be in a different format or the endpoint is wrong
-			if resp.StatusCode == http.StatusNotFound && attempt == 1 {
-				// Try alternative polling URL format
-				alternativeURL := fmt.Sprintf("https://api.dev.runwayml.com/v1/jobs/%s", jobID)
-				log.Printf("Trying alternative polling URL: %s", alternativeURL)
-				apiURL = alternativeURL
-			}
+// cancelJobs cancels the unfinished jobs once the run is cancelled, recording them so a rerun
+// starts them again
+func (c *Converter) cancelJobs(ctx context.Context, jobs *jobFile, images []common.Image) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+	defer cancel()
 
-			time.Sleep(pollInterval)
+	for _, image := range unfinished(jobs, images) {
+		j, _ := jobs.get(image.Path)
+		if err := c.runway.Cancel(ctx, j.TaskID); err != nil {
+			log.Printf("Error cancelling job %s: %v", j.TaskID, err)
 			continue
 		}
+		log.Printf("Cancelled job %s for image: %s", j.TaskID, image.Path)
 
-		// Log the full response for debugging
-		log.Printf("Polling response: %s", string(body))
-
-		// Parse response
-		var responseData map[string]interface{}
-		if err := json.Unmarshal(body, &responseData); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %w", err)
+		j.Error = strings.ToLower(string(TaskCancelled))
+		if err := jobs.set(image.Path, j); err != nil {
+			log.Printf("Warning: %v", err)
 		}
+	}
+}
 
-		// Check the status of the generation
-		status, ok := responseData["status"].(string)
-		if !ok {
-			log.Printf("Polling attempt %d: no status in response", attempt)
-			time.Sleep(pollInterval)
-			continue
+// unfinished returns the images whose jobs are still running
+func unfinished(jobs *jobFile, images []common.Image) []common.Image {
+	var pending []common.Image
+	for _, image := range images {
+		if j, ok := jobs.get(image.Path); ok && !j.done() {
+			pending = append(pending, image)
 		}
+	}
+	return pending
+}
 
-		if status == "completed" {
-			// Get the video URL
-			videoURL, ok := responseData["videoUrl"].(string)
-			if !ok {
-				// Try alternative field names
-				if output, ok := responseData["output"].(map[string]interface{}); ok {
-					if videoURL, ok = output["video"].(string); !ok {
-						return nil, fmt.Errorf("no video URL in response: %v", responseData)
-					}
-				} else {
-					return nil, fmt.Errorf("no video URL in response: %v", responseData)
-				}
-			}
-
-			log.Printf("Video URL: %s", videoURL)
-
-			// Download the video
-			return c.downloadVideo(ctx, videoURL)
+// logProgress summarizes a round of checks, e.g. "Video jobs: 2 RUNNING (45%), 1 SUCCEEDED"
+func logProgress(tasks []Task) {
+	counts := make(map[TaskStatus]int)
+	progress := 0.0
+	for _, task := range tasks {
+		if task.Status == "" {
+			continue
 		}
-
-		if status == "failed" {
-			errorMessage := "unknown error"
-			if errMsg, ok := responseData["error"].(string); ok {
-				errorMessage = errMsg
-			}
-			return nil, fmt.Errorf("video generation failed: %s", errorMessage)
+		counts[task.Status]++
+		if task.Status == TaskRunning {
+			progress += task.Progress
 		}
-
-		// Still processing, wait and try again
-		log.Printf("Polling attempt %d: status %s", attempt, status)
-		time.Sleep(pollInterval)
 	}
-
-	return nil, fmt.Errorf("timed out waiting for video generation")
-}
-
-// downloadVideo downloads a video from a URL
-func (c *Converter) downloadVideo(ctx context.Context, url string) ([]byte, error) {
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	if len(counts) == 0 {
+		return
 	}
 
-	// Send request
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	var parts []string
+	for status, n := range counts {
+		part := fmt.Sprintf("%d %s", n, status)
+		if status == TaskRunning {
+			part += fmt.Sprintf(" (%.0f%%)", 100*progress/float64(n))
+		}
+		parts = append(parts, part)
 	}
-	defer resp.Body.Close()
+	sort.Strings(parts)
+	log.Printf("Video jobs: %s", strings.Join(parts, ", "))
+}
 
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// runwayDuration picks the length Runway can generate that is closest to the clip's length;
+// assembly trims or holds the clip to the exact length
+func runwayDuration(length int) int {
+	if length > 5 {
+		return 10
 	}
-
-	// Read response
-	return io.ReadAll(resp.Body)
+	return 5
 }
 
-// createPlaceholderVideos creates placeholder videos for testing
-func (c *Converter) createPlaceholderVideos(images []common.Image) ([]common.Video, error) {
-	var videos []common.Video
-
-	for _, image := range images {
-		// Generate a unique filename
-		baseFilename := filepath.Base(image.Path)
-		baseFilename = strings.TrimSuffix(baseFilename, filepath.Ext(baseFilename))
-		filename := fmt.Sprintf("placeholder_%s_%s.mp4", baseFilename, uuid.New().String()[:8])
-
-		videoPath := filepath.Join(c.config.OutputDir, filename)
-
-		// Create a placeholder video
-		if err := createPlaceholderVideo(videoPath); err != nil {
-			log.Printf("Error creating placeholder video %s: %v", videoPath, err)
-			continue
+// encodeImageToBase64 encodes an image as a base64 data URI
+func encodeImageToBase64(imageData []byte) string {
+	// Determine the MIME type based on the image data
+	mimeType := "image/jpeg" // Default to JPEG
+	if len(imageData) > 2 {
+		// Check for PNG signature
+		if imageData[0] == 0x89 && imageData[1] == 0x50 && imageData[2] == 0x4E {
+			mimeType = "image/png"
 		}
-
-		videos = append(videos, common.Video{
-			Path:    videoPath,
-			ImageID: image.SceneID,
-			Length:  c.config.VideoLength,
-		})
-
-		log.Printf("Created placeholder video: %s", videoPath)
-
-		// Add a small delay to simulate API calls
-		time.Sleep(100 * time.Millisecond)
 	}
 
-	return videos, nil
-}
-
-// createPlaceholderVideo creates an empty file as a placeholder
-func createPlaceholderVideo(path string) error {
-	// Ensure the directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
+	// Encode the image data as base64
+	base64Encoded := base64.StdEncoding.EncodeToString(imageData)
 
-	// Create an empty file
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	// Return as a data URI
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64Encoded)
+}
 
-	// Write a placeholder message
-	_, err = file.WriteString("This is a placeholder for a video that would be generated by Runway ML")
-	return err
+// fileExists reports whether a file exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
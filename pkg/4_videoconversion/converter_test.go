@@ -0,0 +1,335 @@
+package videoconversion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+// fakeRunway is a stand-in for the Runway API whose jobs complete after a number of polls
+type fakeRunway struct {
+	server *httptest.Server
+	polls  int // polls before a job completes
+
+	mu        sync.Mutex
+	created   int
+	polled    map[string]int
+	inFlight  int
+	maxSeen   int
+	failed    map[string]bool
+	cancelled map[string]bool
+	bodies    []map[string]interface{}
+	headers   []http.Header
+}
+
+func newFakeRunway(t *testing.T, polls int) *fakeRunway {
+	f := &fakeRunway{polls: polls, polled: make(map[string]int), failed: make(map[string]bool), cancelled: make(map[string]bool)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/image_to_video", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		f.mu.Lock()
+		f.bodies = append(f.bodies, body)
+		f.headers = append(f.headers, r.Header)
+		f.created++
+		id := fmt.Sprintf("task-%d", f.created)
+		if body["promptText"] == "fail" {
+			f.failed[id] = true
+		}
+		f.inFlight++
+		f.maxSeen = max(f.maxSeen, f.inFlight)
+		f.mu.Unlock()
+
+		// Hold the request a moment so concurrent submissions overlap
+		time.Sleep(20 * time.Millisecond)
+
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	})
+	mux.HandleFunc("GET /v1/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		f.mu.Lock()
+		f.polled[id]++
+		polls, failed := f.polled[id], f.failed[id]
+		f.mu.Unlock()
+
+		switch {
+		case failed:
+			json.NewEncoder(w).Encode(Task{ID: id, Status: TaskFailed, Failure: "content rejected", FailureCode: "SAFETY.INPUT.TEXT"})
+		case polls == 1 && f.polls > 1:
+			json.NewEncoder(w).Encode(Task{ID: id, Status: TaskThrottled})
+		case polls < f.polls:
+			json.NewEncoder(w).Encode(Task{ID: id, Status: TaskRunning, Progress: float64(polls) / float64(f.polls)})
+		default:
+			json.NewEncoder(w).Encode(Task{ID: id, Status: TaskSucceeded, Output: []string{f.server.URL + "/videos/" + id + ".mp4"}})
+		}
+	})
+	mux.HandleFunc("DELETE /v1/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.cancelled[r.PathValue("id")] = true
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /videos/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video " + r.PathValue("name")))
+	})
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// testConverter creates a converter that talks to the fake Runway without retries
+func testConverter(f *fakeRunway, outputDir string, concurrency int) *Converter {
+	return &Converter{
+		config: config.VideoConversionConfig{
+			RunwayAPIKey: "test-key",
+			OutputDir:    outputDir,
+			VideoLength:  5,
+			Concurrency:  concurrency,
+		},
+		runway:       &RunwayClient{APIKey: "test-key", BaseURL: f.server.URL, Model: DefaultRunwayModel, HTTPClient: http.DefaultClient},
+		pollInterval: 10 * time.Millisecond,
+		maxWait:      5 * time.Second,
+	}
+}
+
+// testImages writes n small image files
+func testImages(t *testing.T, n int) []common.Image {
+	t.Helper()
+	dir := t.TempDir()
+	var images []common.Image
+	for i := 1; i <= n; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("scene_%d.png", i))
+		if err := os.WriteFile(path, []byte("\x89PNG"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, common.Image{Path: path, SceneID: fmt.Sprint(i), Description: fmt.Sprintf("scene %d", i)})
+	}
+	return images
+}
+
+func TestConverter_Convert_Concurrent(t *testing.T) {
+	f := newFakeRunway(t, 3)
+	outputDir := t.TempDir()
+	c := testConverter(f, outputDir, 2)
+
+	images := testImages(t, 5)
+	videos, err := c.Convert(context.Background(), images)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	if len(videos) != 5 {
+		t.Fatalf("Convert() returned %d videos, want 5", len(videos))
+	}
+	for i, video := range videos {
+		if video.ImageID != images[i].SceneID || video.Length != 5 {
+			t.Errorf("video %d = %+v, want scene %s in order", i, video, images[i].SceneID)
+		}
+		if data, err := os.ReadFile(video.Path); err != nil || !strings.HasPrefix(string(data), "video task-") {
+			t.Errorf("video %d = %q, %v, want the downloaded video", i, data, err)
+		}
+	}
+
+	if f.created != 5 {
+		t.Errorf("%d jobs submitted, want 5", f.created)
+	}
+	if f.maxSeen > 2 {
+		t.Errorf("%d submissions at once, want at most 2", f.maxSeen)
+	}
+
+	// A finished run leaves no job file behind
+	if _, err := os.Stat(filepath.Join(outputDir, jobFileName)); !os.IsNotExist(err) {
+		t.Errorf("job file left behind: %v", err)
+	}
+}
+
+func TestConverter_Convert_Reattach(t *testing.T) {
+	f := newFakeRunway(t, 1)
+	outputDir := t.TempDir()
+	images := testImages(t, 3)
+
+	// An earlier run submitted the first image's job and finished the second before it stopped
+	finished := filepath.Join(outputDir, "video_scene_2.mp4")
+	if err := os.WriteFile(finished, []byte("earlier video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jobs, _ := loadJobFile(filepath.Join(outputDir, jobFileName))
+	jobs.set(images[0].Path, job{TaskID: "earlier-task", SceneID: "1"})
+	jobs.set(images[1].Path, job{TaskID: "finished-task", SceneID: "2", VideoPath: finished})
+
+	c := testConverter(f, outputDir, 4)
+	videos, err := c.Convert(context.Background(), images)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if len(videos) != 3 {
+		t.Fatalf("Convert() returned %d videos, want 3", len(videos))
+	}
+
+	// Only the third image needed a new job
+	if f.created != 1 {
+		t.Errorf("%d jobs submitted, want 1", f.created)
+	}
+	if f.polled["earlier-task"] == 0 {
+		t.Error("the earlier job was not checked")
+	}
+	if f.polled["finished-task"] != 0 {
+		t.Error("the finished job was checked again")
+	}
+	if videos[1].Path != finished {
+		t.Errorf("video 2 = %s, want the earlier %s", videos[1].Path, finished)
+	}
+	if data, _ := os.ReadFile(videos[0].Path); string(data) != "video earlier-task.mp4" {
+		t.Errorf("video 1 = %q, want the earlier job's video", data)
+	}
+}
+
+func TestConverter_Convert_FailedJob(t *testing.T) {
+	f := newFakeRunway(t, 1)
+	outputDir := t.TempDir()
+	images := testImages(t, 2)
+	images[1].Description = "fail"
+
+	c := testConverter(f, outputDir, 2)
+	videos, err := c.Convert(context.Background(), images)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if len(videos) != 1 || videos[0].ImageID != "1" {
+		t.Fatalf("Convert() = %+v, want only the first video", videos)
+	}
+
+	// The failure is recorded, and the job file kept so a rerun tries the image again
+	jobs, err := loadJobFile(filepath.Join(outputDir, jobFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j, ok := jobs.get(images[1].Path); !ok || j.Error != "content rejected" {
+		t.Errorf("failed job = %+v, want its error recorded", j)
+	}
+
+	images[1].Description = "scene 2"
+	if videos, err := c.Convert(context.Background(), images); err != nil || len(videos) != 2 {
+		t.Errorf("rerun Convert() = %d videos, %v, want 2", len(videos), err)
+	}
+	if f.created != 3 {
+		t.Errorf("%d jobs submitted in all, want 3", f.created)
+	}
+}
+
+func TestConverter_Convert_MissingVideo(t *testing.T) {
+	f := newFakeRunway(t, 1)
+	outputDir := t.TempDir()
+	images := testImages(t, 2)
+
+	// An earlier run downloaded the second video, which has since been deleted along with its image
+	jobs, _ := loadJobFile(filepath.Join(outputDir, jobFileName))
+	jobs.set(images[1].Path, job{TaskID: "finished-task", SceneID: "2", VideoPath: filepath.Join(outputDir, "gone.mp4")})
+	os.Remove(images[1].Path)
+
+	c := testConverter(f, outputDir, 2)
+	c.config.VideoLength = 7
+	videos, err := c.Convert(context.Background(), images)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	// Only the video that exists is returned, as long as Runway made it
+	if len(videos) != 1 || videos[0].ImageID != "1" {
+		t.Fatalf("Convert() = %+v, want only the first video", videos)
+	}
+	if videos[0].Length != 10 {
+		t.Errorf("video length = %d, want the 10 seconds requested from Runway", videos[0].Length)
+	}
+}
+
+func TestConverter_Convert_Cancelled(t *testing.T) {
+	f := newFakeRunway(t, 1000)
+	outputDir := t.TempDir()
+	c := testConverter(f, outputDir, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Convert(ctx, testImages(t, 2)); err == nil {
+		t.Fatal("Convert() error = nil, want the context's error")
+	}
+
+	// The jobs are cancelled, and recorded so the next run starts them again
+	if len(f.cancelled) != 2 {
+		t.Errorf("%d jobs cancelled, want 2", len(f.cancelled))
+	}
+	jobs, err := loadJobFile(filepath.Join(outputDir, jobFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs.jobs) != 2 {
+		t.Errorf("job file has %d jobs, want 2", len(jobs.jobs))
+	}
+	for path, j := range jobs.jobs {
+		if j.Error != "cancelled" {
+			t.Errorf("job for %s = %+v, want it recorded as cancelled", path, j)
+		}
+	}
+}
+
+func TestConverter_Convert_Request(t *testing.T) {
+	f := newFakeRunway(t, 1)
+	c := testConverter(f, t.TempDir(), 1)
+	c.runway.Model = "gen4_turbo"
+
+	if _, err := c.Convert(context.Background(), testImages(t, 1)); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	header, body := f.headers[0], f.bodies[0]
+	if got := header.Get("Authorization"); got != "Bearer test-key" {
+		t.Errorf("Authorization = %q, want the key", got)
+	}
+	if got := header.Get("X-Runway-Version"); got != runwayVersion {
+		t.Errorf("X-Runway-Version = %q, want %q", got, runwayVersion)
+	}
+	if body["model"] != "gen4_turbo" || body["duration"] != 5.0 || body["promptText"] != "scene 1" {
+		t.Errorf("request body = %v, want the configured model, a 5 second duration and the description", body)
+	}
+	if image, _ := body["promptImage"].(string); !strings.HasPrefix(image, "data:image/png;base64,") {
+		t.Errorf("promptImage = %.40q, want a PNG data URI", image)
+	}
+}
+
+func TestConverter_Convert_NoKey(t *testing.T) {
+	// Without a key the Runway converter fails rather than making something else in its place
+	c := New(config.VideoConversionConfig{OutputDir: t.TempDir()})
+	if _, err := c.Convert(context.Background(), testImages(t, 1)); err == nil || !strings.Contains(err.Error(), "no Runway API key") {
+		t.Errorf("Convert() error = %v, want the missing key", err)
+	}
+
+	// The mock backend stands in only when chosen
+	m, err := Backends.New(BackendMock, config.VideoConversionConfig{OutputDir: t.TempDir()}, false)
+	if err != nil {
+		t.Fatalf("Backends.New() error = %v", err)
+	}
+	videos, err := m.Convert(context.Background(), testImages(t, 2))
+	if err != nil || len(videos) != 2 || videos[1].ImageID != "2" || videos[1].Length != defaultVideoLength {
+		t.Errorf("mock Convert() = %+v, %v, want a placeholder for each image", videos, err)
+	}
+}
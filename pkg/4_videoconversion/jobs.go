@@ -0,0 +1,110 @@
+package videoconversion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// jobFileName is the file in the output directory that records the Runway jobs of a run
+const jobFileName = "runway_jobs.json"
+
+// job is the Runway job generating the video for one image
+type job struct {
+	TaskID    string `json:"task_id"`
+	SceneID   string `json:"scene_id"`
+	Duration  int    `json:"duration,omitempty"`   // seconds of video requested from Runway
+	VideoPath string `json:"video_path,omitempty"` // set once the video is downloaded
+	Error     string `json:"error,omitempty"`      // set if the job failed
+}
+
+// length returns the length of the job's video, or the length Runway makes for the configured
+// clip length when the job was recorded without one
+func (j job) length(videoLength int) int {
+	if j.Duration > 0 {
+		return j.Duration
+	}
+	return runwayDuration(videoLength)
+}
+
+// done reports whether the job has finished, one way or the other
+func (j job) done() bool {
+	return j.VideoPath != "" || j.Error != ""
+}
+
+// jobFile records the jobs of a run by image path, saving every change so a run that is
+// interrupted can reattach to the jobs it already paid for
+type jobFile struct {
+	path string
+
+	mu   sync.Mutex
+	jobs map[string]job
+}
+
+// loadJobFile reads the job file at path, or starts an empty one if there is none
+func loadJobFile(path string) (*jobFile, error) {
+	f := &jobFile{path: path, jobs: make(map[string]job)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &f.jobs); err != nil {
+		return nil, fmt.Errorf("failed to parse job file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// get returns the job for an image
+func (f *jobFile) get(imagePath string) (job, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j, ok := f.jobs[imagePath]
+	return j, ok
+}
+
+// set records the job for an image and saves the file
+func (f *jobFile) set(imagePath string, j job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs[imagePath] = j
+	return f.save()
+}
+
+// save writes the file, replacing the old one only once the new one is complete. The caller
+// must hold the lock.
+func (f *jobFile) save() error {
+	data, err := json.MarshalIndent(f.jobs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode job file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create job file directory: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write job file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to write job file: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the file once the run no longer needs it
+func (f *jobFile) remove() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove job file: %w", err)
+	}
+	return nil
+}
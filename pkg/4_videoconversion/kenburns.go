@@ -0,0 +1,229 @@
+package videoconversion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/iantozer/stitch-up/internal/ffmpeg"
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+// Camera motions for clips rendered from still images
+const (
+	MotionZoomIn   = "zoom-in"
+	MotionZoomOut  = "zoom-out"
+	MotionPanLeft  = "pan-left"
+	MotionPanRight = "pan-right"
+	MotionPanUp    = "pan-up"
+	MotionPanDown  = "pan-down"
+)
+
+// defaultVideoLength is the length of a clip in seconds when the config leaves it empty
+const defaultVideoLength = 10
+
+const (
+	// zoomRange is how far a clip zooms in, and how far in a pan is framed so it has room to move
+	zoomRange = 0.2
+
+	// supersample renders the motion on an image this many times larger than the clip, which
+	// smooths out zoompan's whole-pixel steps
+	supersample = 4
+)
+
+// motionRotation is cycled through for scenes whose camera and mood suggest no motion, so
+// consecutive scenes move differently
+var motionRotation = []string{MotionZoomIn, MotionPanRight, MotionZoomOut, MotionPanLeft, MotionPanUp, MotionPanDown}
+
+// cameraMotions maps words in a scene's camera direction to the motion they describe
+var cameraMotions = []struct {
+	words  []string
+	motion string
+}{
+	{[]string{"push in", "push-in", "zoom in", "close-up", "close up"}, MotionZoomIn},
+	{[]string{"pull back", "pull-back", "zoom out", "aerial"}, MotionZoomOut},
+	{[]string{"pan left"}, MotionPanLeft},
+	{[]string{"pan right", "tracking"}, MotionPanRight},
+	{[]string{"tilt up"}, MotionPanUp},
+	{[]string{"tilt down"}, MotionPanDown},
+}
+
+// moodMotions maps moods to a motion: tense scenes close in, hopeful ones open out
+var moodMotions = []struct {
+	words  []string
+	motion string
+}{
+	{[]string{"tense", "urgent", "dramatic", "ominous", "anxious", "grim", "somber", "sombre", "intense", "shock", "fear", "angry"}, MotionZoomIn},
+	{[]string{"hope", "uplifting", "calm", "peaceful", "serene", "joy", "triumph", "reflective", "wistful", "relief"}, MotionZoomOut},
+}
+
+// KenBurns implements the VideoConverter interface by panning and zooming over each image
+// with ffmpeg, so clips can be made without a network
+type KenBurns struct {
+	config config.VideoConversionConfig
+}
+
+// NewKenBurns creates a video converter that renders clips locally with ffmpeg
+func NewKenBurns(config config.VideoConversionConfig) common.VideoConverter {
+	if config.FFMPEGPath == "" {
+		config.FFMPEGPath = ffmpeg.DefaultPath
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		config.Width, config.Height = ffmpeg.DefaultWidth, ffmpeg.DefaultHeight
+	}
+	if config.FPS <= 0 {
+		config.FPS = ffmpeg.DefaultFPS
+	}
+	if config.VideoLength <= 0 {
+		config.VideoLength = defaultVideoLength
+	}
+
+	return &KenBurns{
+		config: config,
+	}
+}
+
+// Convert renders a clip for each image, moving over it the way its scene suggests
+func (k *KenBurns) Convert(ctx context.Context, images []common.Image) ([]common.Video, error) {
+	log.Println("Converting images to videos locally with ffmpeg")
+
+	ffmpegPath, err := ffmpeg.Find(k.config.FFMPEGPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(k.config.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	var videos []common.Video
+	for i, image := range images {
+		motion, err := k.motion(image, i)
+		if err != nil {
+			return nil, err
+		}
+
+		baseFilename := filepath.Base(image.Path)
+		baseFilename = strings.TrimSuffix(baseFilename, filepath.Ext(baseFilename))
+		filename := fmt.Sprintf("video_%s_%s.mp4", baseFilename, uuid.New().String()[:8])
+		videoPath := filepath.Join(k.config.OutputDir, filename)
+
+		log.Printf("Rendering %s video for image: %s", motion, image.Path)
+		if err := ffmpeg.Run(ctx, ffmpegPath, k.kenBurnsArgs(image.Path, videoPath, motion)...); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("Error rendering video for image %s: %v", image.Path, err)
+			continue
+		}
+
+		videos = append(videos, common.Video{
+			Path:    videoPath,
+			ImageID: image.SceneID,
+			Length:  k.config.VideoLength,
+		})
+
+		log.Printf("Created video: %s", videoPath)
+	}
+
+	if len(videos) == 0 {
+		return videos, fmt.Errorf("no videos created")
+	}
+
+	log.Printf("Created %d videos", len(videos))
+	return videos, nil
+}
+
+// motion picks the motion for the i-th image: the configured one, else one suggested by the
+// scene's camera direction or mood, else the next in the rotation
+func (k *KenBurns) motion(image common.Image, i int) (string, error) {
+	if k.config.Motion != "" {
+		if _, ok := motionExpressions(k.config.Motion, 1); !ok {
+			return "", fmt.Errorf("unknown motion %q", k.config.Motion)
+		}
+		return k.config.Motion, nil
+	}
+
+	camera := strings.ToLower(image.Camera)
+	for _, m := range cameraMotions {
+		for _, word := range m.words {
+			if strings.Contains(camera, word) {
+				return m.motion, nil
+			}
+		}
+	}
+
+	mood := strings.ToLower(image.Mood)
+	for _, m := range moodMotions {
+		for _, word := range m.words {
+			if strings.Contains(mood, word) {
+				return m.motion, nil
+			}
+		}
+	}
+
+	return motionRotation[i%len(motionRotation)], nil
+}
+
+// motionExpressions returns zoompan's zoom, x and y expressions for a motion over a clip of the
+// given number of frames, in terms of the output frame number "on"
+func motionExpressions(motion string, frames int) ([3]string, bool) {
+	zoom := number(1 + zoomRange)
+	centreX, centreY := "iw/2-(iw/zoom/2)", "ih/2-(ih/zoom/2)"
+	progress := fmt.Sprintf("on/%d", max(frames-1, 1))
+
+	switch motion {
+	case MotionZoomIn:
+		return [3]string{"1+" + number(zoomRange) + "*" + progress, centreX, centreY}, true
+	case MotionZoomOut:
+		return [3]string{zoom + "-" + number(zoomRange) + "*" + progress, centreX, centreY}, true
+	case MotionPanLeft:
+		return [3]string{zoom, "(iw-iw/zoom)*(1-" + progress + ")", centreY}, true
+	case MotionPanRight:
+		return [3]string{zoom, "(iw-iw/zoom)*" + progress, centreY}, true
+	case MotionPanUp:
+		return [3]string{zoom, centreX, "(ih-ih/zoom)*(1-" + progress + ")"}, true
+	case MotionPanDown:
+		return [3]string{zoom, centreX, "(ih-ih/zoom)*" + progress}, true
+	}
+	return [3]string{}, false
+}
+
+// kenBurnsFilter builds the filter that crops the image to the clip's shape, enlarged so the
+// motion is smooth, and moves over it for the length of the clip
+func (k *KenBurns) kenBurnsFilter(motion string) string {
+	frames := k.config.VideoLength * k.config.FPS
+	expressions, _ := motionExpressions(motion, frames)
+	width, height := k.config.Width*supersample, k.config.Height*supersample
+
+	return fmt.Sprintf(
+		"scale=%[1]d:%[2]d:force_original_aspect_ratio=increase,crop=%[1]d:%[2]d,setsar=1,"+
+			"zoompan=z=%[3]s:x=%[4]s:y=%[5]s:d=%[6]d:s=%[7]dx%[8]d:fps=%[9]d,format=yuv420p",
+		width, height, expressions[0], expressions[1], expressions[2], frames, k.config.Width, k.config.Height, k.config.FPS,
+	)
+}
+
+// kenBurnsArgs builds the ffmpeg arguments that render a clip from an image
+func (k *KenBurns) kenBurnsArgs(image, output, motion string) []string {
+	return []string{
+		"-y", "-i", image,
+		"-vf", k.kenBurnsFilter(motion),
+		"-frames:v", strconv.Itoa(k.config.VideoLength * k.config.FPS),
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		"-r", strconv.Itoa(k.config.FPS),
+		"-movflags", "+faststart",
+		output,
+	}
+}
+
+// number formats a number for an ffmpeg expression
+func number(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
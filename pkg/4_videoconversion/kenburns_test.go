@@ -0,0 +1,149 @@
+package videoconversion
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+func TestKenBurns_Motion(t *testing.T) {
+	k := NewKenBurns(config.VideoConversionConfig{}).(*KenBurns)
+
+	tests := []struct {
+		name  string
+		image common.Image
+		index int
+		want  string
+	}{
+		{"camera", common.Image{Camera: "Slow push in on the podium", Mood: "hopeful"}, 0, MotionZoomIn},
+		{"aerial", common.Image{Camera: "aerial wide shot"}, 0, MotionZoomOut},
+		{"tense mood", common.Image{Mood: "Tense and uncertain"}, 1, MotionZoomIn},
+		{"hopeful mood", common.Image{Mood: "hopeful"}, 0, MotionZoomOut},
+		{"first in rotation", common.Image{}, 0, motionRotation[0]},
+		{"next in rotation", common.Image{}, 1, motionRotation[1]},
+		{"rotation wraps", common.Image{Mood: "neutral"}, len(motionRotation), motionRotation[0]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.motion(tt.image, tt.index)
+			if err != nil {
+				t.Fatalf("motion() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("motion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKenBurns_Motion_Configured(t *testing.T) {
+	k := NewKenBurns(config.VideoConversionConfig{Motion: MotionPanDown}).(*KenBurns)
+	if got, _ := k.motion(common.Image{Mood: "tense"}, 0); got != MotionPanDown {
+		t.Errorf("motion() = %s, want the configured %s", got, MotionPanDown)
+	}
+
+	k = NewKenBurns(config.VideoConversionConfig{Motion: "spin"}).(*KenBurns)
+	if _, err := k.motion(common.Image{}, 0); err == nil {
+		t.Error("motion() error = nil, want an error for an unknown motion")
+	}
+}
+
+func TestKenBurnsArgs(t *testing.T) {
+	k := NewKenBurns(config.VideoConversionConfig{VideoLength: 5, Width: 640, Height: 360, FPS: 25}).(*KenBurns)
+	args := strings.Join(k.kenBurnsArgs("scene.png", "out.mp4", MotionZoomIn), " ")
+
+	for _, want := range []string{
+		"-i scene.png",
+		"scale=2560:1440:force_original_aspect_ratio=increase,crop=2560:1440",
+		"zoompan=z=1+0.2*on/124:x=iw/2-(iw/zoom/2):y=ih/2-(ih/zoom/2):d=125:s=640x360:fps=25",
+		"-frames:v 125",
+		"-c:v libx264",
+		"-pix_fmt yuv420p",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("kenBurnsArgs() = %s, missing %q", args, want)
+		}
+	}
+	if !strings.HasSuffix(args, " out.mp4") {
+		t.Errorf("kenBurnsArgs() = %s, want the output last", args)
+	}
+}
+
+func TestMotionExpressions(t *testing.T) {
+	tests := []struct {
+		motion string
+		want   [3]string
+	}{
+		{MotionZoomOut, [3]string{"1.2-0.2*on/9", "iw/2-(iw/zoom/2)", "ih/2-(ih/zoom/2)"}},
+		{MotionPanLeft, [3]string{"1.2", "(iw-iw/zoom)*(1-on/9)", "ih/2-(ih/zoom/2)"}},
+		{MotionPanRight, [3]string{"1.2", "(iw-iw/zoom)*on/9", "ih/2-(ih/zoom/2)"}},
+		{MotionPanUp, [3]string{"1.2", "iw/2-(iw/zoom/2)", "(ih-ih/zoom)*(1-on/9)"}},
+		{MotionPanDown, [3]string{"1.2", "iw/2-(iw/zoom/2)", "(ih-ih/zoom)*on/9"}},
+	}
+
+	for _, tt := range tests {
+		got, ok := motionExpressions(tt.motion, 10)
+		if !ok || got != tt.want {
+			t.Errorf("motionExpressions(%s) = %v, %v, want %v", tt.motion, got, ok, tt.want)
+		}
+	}
+}
+
+func TestKenBurns_Convert_FFmpeg(t *testing.T) {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg not found in PATH")
+	}
+
+	// A small gradient to move over
+	inputDir := t.TempDir()
+	imagePath := filepath.Join(inputDir, "scene_1.png")
+	img := image.NewRGBA(image.Rect(0, 0, 200, 150))
+	for y := 0; y < 150; y++ {
+		for x := 0; x < 200; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	file, err := os.Create(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	k := NewKenBurns(config.VideoConversionConfig{
+		OutputDir:   t.TempDir(),
+		FFMPEGPath:  ffmpegPath,
+		VideoLength: 1,
+		Width:       160,
+		Height:      90,
+		FPS:         10,
+	})
+	videos, err := k.Convert(context.Background(), []common.Image{{Path: imagePath, SceneID: "1", Mood: "tense"}})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if len(videos) != 1 || videos[0].ImageID != "1" || videos[0].Length != 1 {
+		t.Fatalf("Convert() = %+v, want one 1 second video for scene 1", videos)
+	}
+
+	data, err := os.ReadFile(videos[0].Path)
+	if err != nil {
+		t.Fatalf("failed to read video: %v", err)
+	}
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		t.Errorf("video is not an MP4 file")
+	}
+}
@@ -0,0 +1,60 @@
+package videoconversion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+// Mock implements the VideoConverter interface with placeholder files, for offline runs
+// without ffmpeg. Only the mock assembler can use its output.
+type Mock struct {
+	config config.VideoConversionConfig
+}
+
+// NewMock creates a video converter that writes a placeholder for each image
+func NewMock(config config.VideoConversionConfig) common.VideoConverter {
+	if config.VideoLength <= 0 {
+		config.VideoLength = defaultVideoLength
+	}
+	return &Mock{
+		config: config,
+	}
+}
+
+// Convert writes a placeholder naming each image in place of its video
+func (m *Mock) Convert(ctx context.Context, images []common.Image) ([]common.Video, error) {
+	log.Println("Creating placeholder videos")
+
+	if err := os.MkdirAll(m.config.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var videos []common.Video
+	for _, image := range images {
+		baseFilename := filepath.Base(image.Path)
+		baseFilename = strings.TrimSuffix(baseFilename, filepath.Ext(baseFilename))
+		videoPath := filepath.Join(m.config.OutputDir, fmt.Sprintf("placeholder_%s_%s.mp4", baseFilename, uuid.New().String()[:8]))
+
+		text := fmt.Sprintf("This is a placeholder for a video of %s\n", image.Path)
+		if err := os.WriteFile(videoPath, []byte(text), 0644); err != nil {
+			return nil, fmt.Errorf("error creating placeholder video: %w", err)
+		}
+
+		videos = append(videos, common.Video{
+			Path:    videoPath,
+			ImageID: image.SceneID,
+			Length:  m.config.VideoLength,
+		})
+		log.Printf("Created placeholder video: %s", videoPath)
+	}
+
+	return videos, nil
+}
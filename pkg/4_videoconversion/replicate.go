@@ -0,0 +1,345 @@
+package videoconversion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iantozer/stitch-up/internal/workpool"
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/transport"
+)
+
+const (
+	// DefaultReplicateBaseURL is the Replicate API endpoint
+	DefaultReplicateBaseURL = "https://api.replicate.com"
+
+	// DefaultReplicateModel is Stable Video Diffusion, as owner/name:version
+	DefaultReplicateModel = "stability-ai/stable-video-diffusion:3f0457e4619daac51203dedb472816fd4af51f3149fa7a9e0b5ffcf1b8172438"
+)
+
+// Stable Video Diffusion always generates svdFrames frames, played back at a rate it accepts
+// between svdMinFPS and svdMaxFPS
+const (
+	svdFrames = 25
+	svdMinFPS = 5
+	svdMaxFPS = 30
+)
+
+// Replicate prediction statuses
+const (
+	PredictionStarting   = "starting"
+	PredictionProcessing = "processing"
+	PredictionSucceeded  = "succeeded"
+	PredictionFailed     = "failed"
+	PredictionCanceled   = "canceled"
+)
+
+// ReplicateClient runs predictions on the Replicate API and downloads their output
+type ReplicateClient struct {
+	APIToken   string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewReplicateClient creates a Replicate client with default settings
+func NewReplicateClient(apiToken string) *ReplicateClient {
+	return &ReplicateClient{
+		APIToken:   apiToken,
+		BaseURL:    DefaultReplicateBaseURL,
+		HTTPClient: transport.NewClient(transport.Replicate, 120*time.Second),
+	}
+}
+
+// PredictionRequest is a prediction to run
+type PredictionRequest struct {
+	Version string                 `json:"version"` // the model version ID
+	Input   map[string]interface{} `json:"input"`
+}
+
+// Prediction is the state of a Replicate prediction
+type Prediction struct {
+	ID     string          `json:"id"`
+	Status string          `json:"status"`
+	Output json.RawMessage `json:"output"` // a URL or a list of URLs, depending on the model
+	Error  interface{}     `json:"error"`
+}
+
+// Finished reports whether the prediction will not change again
+func (p Prediction) Finished() bool {
+	return p.Status == PredictionSucceeded || p.Status == PredictionFailed || p.Status == PredictionCanceled
+}
+
+// OutputURL returns the URL of the prediction's output, or the first of them
+func (p Prediction) OutputURL() (string, error) {
+	var single string
+	if err := json.Unmarshal(p.Output, &single); err == nil && single != "" {
+		return single, nil
+	}
+	var list []string
+	if err := json.Unmarshal(p.Output, &list); err == nil && len(list) > 0 && list[0] != "" {
+		return list[0], nil
+	}
+	return "", fmt.Errorf("no output URL in prediction %s", p.ID)
+}
+
+// replicateVersion returns the version ID of a model given as owner/name:version or as a bare version
+func replicateVersion(model string) string {
+	if i := strings.LastIndex(model, ":"); i >= 0 {
+		return model[i+1:]
+	}
+	return model
+}
+
+// CreatePrediction starts a prediction
+func (c *ReplicateClient) CreatePrediction(ctx context.Context, request PredictionRequest) (Prediction, error) {
+	var prediction Prediction
+	if err := c.do(ctx, http.MethodPost, "/v1/predictions", request, &prediction); err != nil {
+		return Prediction{}, err
+	}
+	if prediction.ID == "" {
+		return Prediction{}, fmt.Errorf("no prediction ID in response")
+	}
+	return prediction, nil
+}
+
+// Prediction returns the current state of a prediction
+func (c *ReplicateClient) Prediction(ctx context.Context, id string) (Prediction, error) {
+	var prediction Prediction
+	if err := c.do(ctx, http.MethodGet, "/v1/predictions/"+url.PathEscape(id), nil, &prediction); err != nil {
+		return Prediction{}, err
+	}
+	return prediction, nil
+}
+
+// CancelPrediction stops a prediction that has not finished
+func (c *ReplicateClient) CancelPrediction(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/v1/predictions/"+url.PathEscape(id)+"/cancel", nil, nil)
+}
+
+// Download saves a prediction's output to path
+func (c *ReplicateClient) Download(ctx context.Context, outputURL, path string) error {
+	return transport.Download(ctx, c.HTTPClient, outputURL, path)
+}
+
+// do sends a request to the Replicate API and decodes its response into out
+func (c *ReplicateClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	header := http.Header{"Authorization": {"Bearer " + c.APIToken}}
+	err := transport.DoJSON(ctx, c.HTTPClient, method, strings.TrimSuffix(c.BaseURL, "/")+path, header, body, out)
+
+	var statusErr *transport.StatusError
+	if errors.As(err, &statusErr) {
+		// Errors are problem details: {"title": ..., "detail": ..., "status": ...}
+		var problem struct {
+			Detail string `json:"detail"`
+		}
+		message := strings.TrimSpace(string(statusErr.Body))
+		if json.Unmarshal(statusErr.Body, &problem) == nil && problem.Detail != "" {
+			message = problem.Detail
+		}
+		return fmt.Errorf("replicate API error %d: %s", statusErr.StatusCode, message)
+	}
+	return err
+}
+
+// ReplicateConverter implements the VideoConverter interface with a video model on Replicate,
+// Stable Video Diffusion by default
+type ReplicateConverter struct {
+	config       config.VideoConversionConfig
+	client       *ReplicateClient
+	pollInterval time.Duration
+	maxWait      time.Duration
+}
+
+// NewReplicateConverter creates a video converter that runs predictions on Replicate
+func NewReplicateConverter(config config.VideoConversionConfig) common.VideoConverter {
+	client := NewReplicateClient(config.ReplicateAPIToken)
+	if config.ReplicateBaseURL != "" {
+		client.BaseURL = config.ReplicateBaseURL
+	}
+	if config.ReplicateModel == "" {
+		config.ReplicateModel = DefaultReplicateModel
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+
+	converter := &ReplicateConverter{
+		config:       config,
+		client:       client,
+		pollInterval: time.Duration(config.PollInterval) * time.Second,
+		maxWait:      time.Duration(config.MaxWait) * time.Second,
+	}
+	if converter.pollInterval <= 0 {
+		converter.pollInterval = defaultPollInterval
+	}
+	if converter.maxWait <= 0 {
+		converter.maxWait = defaultMaxWait
+	}
+	return converter
+}
+
+// Convert generates a video for each image, running up to the configured number of
+// predictions at once. Predictions still running when the context is cancelled are cancelled.
+func (r *ReplicateConverter) Convert(ctx context.Context, images []common.Image) ([]common.Video, error) {
+	log.Printf("Converting images to videos using Replicate model %s", r.config.ReplicateModel)
+
+	if r.config.ReplicateAPIToken == "" {
+		return nil, fmt.Errorf("no Replicate API token provided")
+	}
+
+	// Each image writes only its own slot, so results need no locking
+	results := make([]*common.Video, len(images))
+	workpool.ForEach(ctx, r.config.Concurrency, len(images), func(ctx context.Context, i int) {
+		video, err := r.convertImage(ctx, images[i])
+		if err != nil {
+			log.Printf("Error generating video for image %s: %v", images[i].Path, err)
+			return
+		}
+		results[i] = &video
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var videos []common.Video
+	for _, video := range results {
+		if video != nil {
+			videos = append(videos, *video)
+		}
+	}
+
+	if len(videos) == 0 {
+		return videos, fmt.Errorf("no videos created")
+	}
+
+	log.Printf("Created %d videos", len(videos))
+	return videos, nil
+}
+
+// convertImage runs a prediction for one image and downloads its video
+func (r *ReplicateConverter) convertImage(ctx context.Context, image common.Image) (common.Video, error) {
+	imageData, err := os.ReadFile(image.Path)
+	if err != nil {
+		return common.Video{}, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	fps := svdFPS(r.config.VideoLength)
+	prediction, err := r.client.CreatePrediction(ctx, PredictionRequest{
+		Version: replicateVersion(r.config.ReplicateModel),
+		Input: map[string]interface{}{
+			"input_image":       encodeImageToBase64(imageData),
+			"video_length":      "25_frames_with_svd_xt",
+			"frames_per_second": fps,
+			"motion_bucket_id":  127,
+			"cond_aug":          0.02,
+			"sizing_strategy":   "maintain_aspect_ratio",
+			"decoding_t":        14,
+		},
+	})
+	if err != nil {
+		return common.Video{}, err
+	}
+	log.Printf("Started prediction %s for image: %s", prediction.ID, image.Path)
+
+	prediction, err = r.wait(ctx, prediction)
+	if err != nil {
+		return common.Video{}, err
+	}
+
+	outputURL, err := prediction.OutputURL()
+	if err != nil {
+		return common.Video{}, err
+	}
+
+	baseFilename := filepath.Base(image.Path)
+	baseFilename = strings.TrimSuffix(baseFilename, filepath.Ext(baseFilename))
+	videoPath := filepath.Join(r.config.OutputDir, fmt.Sprintf("video_%s_%s.mp4", baseFilename, uuid.New().String()[:8]))
+	if err := r.client.Download(ctx, outputURL, videoPath); err != nil {
+		return common.Video{}, err
+	}
+
+	log.Printf("Created video: %s", videoPath)
+	return common.Video{
+		Path:    videoPath,
+		ImageID: image.SceneID,
+		Length:  svdLength(fps),
+	}, nil
+}
+
+// svdFPS picks the frame rate that stretches the frames closest to the clip's length
+func svdFPS(length int) int {
+	if length <= 0 {
+		return 6
+	}
+	return min(max((svdFrames+length/2)/length, svdMinFPS), svdMaxFPS)
+}
+
+// svdLength is the whole seconds of video the frames fill at a frame rate, so assembly trims the
+// clip a little rather than holding its last frame
+func svdLength(fps int) int {
+	return max(svdFrames/fps, 1)
+}
+
+// wait polls a prediction until it succeeds, fails or the maximum wait passes, cancelling it
+// if the context is cancelled or the wait runs out
+func (r *ReplicateConverter) wait(ctx context.Context, prediction Prediction) (Prediction, error) {
+	deadline := time.Now().Add(r.maxWait)
+
+	for !prediction.Finished() {
+		if time.Now().After(deadline) {
+			r.cancel(ctx, prediction.ID)
+			return Prediction{}, fmt.Errorf("timed out after %s waiting for prediction %s", r.maxWait, prediction.ID)
+		}
+
+		select {
+		case <-ctx.Done():
+			r.cancel(ctx, prediction.ID)
+			return Prediction{}, ctx.Err()
+		case <-time.After(r.pollInterval):
+		}
+
+		current, err := r.client.Prediction(ctx, prediction.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				r.cancel(ctx, prediction.ID)
+				return Prediction{}, ctx.Err()
+			}
+			// Tried again at the next check
+			log.Printf("Error checking prediction %s: %v", prediction.ID, err)
+			continue
+		}
+		prediction = current
+		log.Printf("Prediction %s is %s", prediction.ID, prediction.Status)
+	}
+
+	switch prediction.Status {
+	case PredictionFailed:
+		return Prediction{}, fmt.Errorf("prediction %s failed: %v", prediction.ID, prediction.Error)
+	case PredictionCanceled:
+		return Prediction{}, fmt.Errorf("prediction %s was canceled", prediction.ID)
+	}
+	return prediction, nil
+}
+
+// cancel cancels a prediction, even once the context it ran under is done
+func (r *ReplicateConverter) cancel(ctx context.Context, id string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+	defer cancel()
+
+	if err := r.client.CancelPrediction(ctx, id); err != nil {
+		log.Printf("Error cancelling prediction %s: %v", id, err)
+		return
+	}
+	log.Printf("Cancelled prediction %s", id)
+}
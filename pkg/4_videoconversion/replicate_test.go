@@ -0,0 +1,275 @@
+package videoconversion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+// fakeReplicate is a stand-in for the Replicate predictions API. Predictions succeed after a
+// number of polls, unless their image is marked to fail.
+type fakeReplicate struct {
+	server *httptest.Server
+	polls  int
+
+	mu        sync.Mutex
+	created   []PredictionRequest
+	auth      []string
+	polled    map[string]int
+	failing   map[string]bool
+	cancelled map[string]bool
+}
+
+func newFakeReplicate(t *testing.T, polls int) *fakeReplicate {
+	f := &fakeReplicate{polls: polls, polled: make(map[string]int), failing: make(map[string]bool), cancelled: make(map[string]bool)}
+
+	prediction := func(id, status string) map[string]interface{} {
+		p := map[string]interface{}{"id": id, "status": status}
+		switch status {
+		case PredictionSucceeded:
+			p["output"] = f.server.URL + "/files/" + id + ".mp4"
+		case PredictionFailed:
+			p["error"] = "CUDA out of memory"
+		}
+		return p
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/predictions", func(w http.ResponseWriter, r *http.Request) {
+		var request PredictionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]string{"title": "Invalid input", "detail": err.Error()})
+			return
+		}
+
+		f.mu.Lock()
+		f.created = append(f.created, request)
+		f.auth = append(f.auth, r.Header.Get("Authorization"))
+		id := fmt.Sprintf("pred-%d", len(f.created))
+		if image, _ := request.Input["input_image"].(string); strings.HasSuffix(image, "ZmFpbA==") { // "fail"
+			f.failing[id] = true
+		}
+		f.mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(prediction(id, PredictionStarting))
+	})
+	mux.HandleFunc("GET /v1/predictions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		f.mu.Lock()
+		f.polled[id]++
+		polls, failing, cancelled := f.polled[id], f.failing[id], f.cancelled[id]
+		f.mu.Unlock()
+
+		switch {
+		case cancelled:
+			json.NewEncoder(w).Encode(prediction(id, PredictionCanceled))
+		case polls < f.polls:
+			json.NewEncoder(w).Encode(prediction(id, PredictionProcessing))
+		case failing:
+			json.NewEncoder(w).Encode(prediction(id, PredictionFailed))
+		default:
+			json.NewEncoder(w).Encode(prediction(id, PredictionSucceeded))
+		}
+	})
+	mux.HandleFunc("POST /v1/predictions/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.cancelled[r.PathValue("id")] = true
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(prediction(r.PathValue("id"), PredictionCanceled))
+	})
+	mux.HandleFunc("GET /files/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video " + r.PathValue("name")))
+	})
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// testReplicateConverter creates a Replicate converter that talks to the fake without retries
+func testReplicateConverter(f *fakeReplicate, outputDir string) *ReplicateConverter {
+	r := NewReplicateConverter(config.VideoConversionConfig{
+		Backend:           BackendReplicate,
+		ReplicateAPIToken: "r8_test",
+		ReplicateBaseURL:  f.server.URL,
+		OutputDir:         outputDir,
+		VideoLength:       5,
+		Concurrency:       2,
+	}).(*ReplicateConverter)
+	r.client.HTTPClient = http.DefaultClient
+	r.pollInterval = 10 * time.Millisecond
+	r.maxWait = 5 * time.Second
+	return r
+}
+
+func TestBackends_Replicate(t *testing.T) {
+	c, err := Backends.New(BackendReplicate, config.VideoConversionConfig{ReplicateAPIToken: "r8_test"}, false)
+	if err != nil {
+		t.Fatalf("Backends.New() error = %v", err)
+	}
+	r, ok := c.(*ReplicateConverter)
+	if !ok {
+		t.Fatalf("Backends.New() = %T, want *ReplicateConverter", c)
+	}
+	if r.config.ReplicateModel != DefaultReplicateModel || r.client.BaseURL != DefaultReplicateBaseURL {
+		t.Errorf("Backends.New() = %+v, want the default model and endpoint", r.config)
+	}
+
+	// Without a token the backend is unavailable rather than quietly replaced
+	if _, err := Backends.New(BackendReplicate, config.VideoConversionConfig{}, false); err == nil || !strings.Contains(err.Error(), "no Replicate API token") {
+		t.Errorf("Backends.New() without a token error = %v, want the missing token", err)
+	}
+}
+
+func TestReplicateConverter_Convert(t *testing.T) {
+	f := newFakeReplicate(t, 2)
+	r := testReplicateConverter(f, t.TempDir())
+
+	images := testImages(t, 3)
+	videos, err := r.Convert(context.Background(), images)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	if len(videos) != 3 {
+		t.Fatalf("Convert() returned %d videos, want 3", len(videos))
+	}
+	for i, video := range videos {
+		if video.ImageID != images[i].SceneID || video.Length != 5 {
+			t.Errorf("video %d = %+v, want scene %s in order", i, video, images[i].SceneID)
+		}
+		if data, err := os.ReadFile(video.Path); err != nil || !strings.HasPrefix(string(data), "video pred-") {
+			t.Errorf("video %d = %q, %v, want the downloaded video", i, data, err)
+		}
+	}
+
+	request := f.created[0]
+	if request.Version != replicateVersion(DefaultReplicateModel) {
+		t.Errorf("version = %s, want the default model's version", request.Version)
+	}
+	if image, _ := request.Input["input_image"].(string); !strings.HasPrefix(image, "data:image/png;base64,") {
+		t.Errorf("input_image = %.40q, want a PNG data URI", image)
+	}
+	if fps := request.Input["frames_per_second"]; fps != 5.0 {
+		t.Errorf("frames_per_second = %v, want 5 so the frames fill 5 seconds", fps)
+	}
+	if f.auth[0] != "Bearer r8_test" {
+		t.Errorf("Authorization = %q, want the token", f.auth[0])
+	}
+}
+
+func TestReplicateConverter_Convert_Failed(t *testing.T) {
+	f := newFakeReplicate(t, 1)
+	r := testReplicateConverter(f, t.TempDir())
+
+	images := testImages(t, 2)
+	if err := os.WriteFile(images[1].Path, []byte("fail"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	videos, err := r.Convert(context.Background(), images)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if len(videos) != 1 || videos[0].ImageID != "1" {
+		t.Errorf("Convert() = %+v, want only the first video", videos)
+	}
+
+	// With every prediction failing, there is nothing to return
+	if _, err := r.Convert(context.Background(), images[1:]); err == nil {
+		t.Error("Convert() error = nil, want an error when no video is created")
+	}
+}
+
+func TestReplicateConverter_Convert_Cancelled(t *testing.T) {
+	f := newFakeReplicate(t, 1000)
+	r := testReplicateConverter(f, t.TempDir())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.Convert(ctx, testImages(t, 2)); err == nil {
+		t.Fatal("Convert() error = nil, want the context's error")
+	}
+	if len(f.cancelled) != 2 {
+		t.Errorf("%d predictions cancelled, want 2", len(f.cancelled))
+	}
+}
+
+func TestReplicateClient_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"title":"Invalid version or not permitted","detail":"The specified version does not exist","status":422}`))
+	}))
+	defer server.Close()
+
+	client := NewReplicateClient("r8_test")
+	client.BaseURL = server.URL
+	client.HTTPClient = server.Client()
+
+	_, err := client.CreatePrediction(context.Background(), PredictionRequest{Version: "missing"})
+	if err == nil || !strings.Contains(err.Error(), "replicate API error 422: The specified version does not exist") {
+		t.Errorf("CreatePrediction() error = %v, want the problem's detail", err)
+	}
+}
+
+func TestPrediction_OutputURL(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{`"https://example.com/a.mp4"`, "https://example.com/a.mp4"},
+		{`["https://example.com/b.mp4", "https://example.com/c.mp4"]`, "https://example.com/b.mp4"},
+		{`null`, ""},
+	}
+
+	for _, tt := range tests {
+		got, err := Prediction{ID: "p", Output: json.RawMessage(tt.output)}.OutputURL()
+		if got != tt.want || (err != nil) != (tt.want == "") {
+			t.Errorf("OutputURL(%s) = %q, %v, want %q", tt.output, got, err, tt.want)
+		}
+	}
+}
+
+func TestSVDFPS(t *testing.T) {
+	// The clip is as long as the frames last, never padded with a still
+	tests := []struct {
+		length, fps, seconds int
+	}{
+		{5, 5, 5},
+		{10, 5, 5}, // SVD plays no slower than 5 fps
+		{4, 6, 4},
+		{2, 13, 1},
+		{0, 6, 4},
+	}
+
+	for _, tt := range tests {
+		fps := svdFPS(tt.length)
+		if fps != tt.fps || svdLength(fps) != tt.seconds {
+			t.Errorf("svdFPS(%d) = %d fps for %d seconds, want %d fps for %d seconds", tt.length, fps, svdLength(fps), tt.fps, tt.seconds)
+		}
+	}
+}
+
+func TestReplicateVersion(t *testing.T) {
+	for model, want := range map[string]string{
+		"stability-ai/stable-video-diffusion:abc123": "abc123",
+		"abc123": "abc123",
+	} {
+		if got := replicateVersion(model); got != want {
+			t.Errorf("replicateVersion(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package videoconversion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/transport"
+)
+
+const (
+	// DefaultRunwayBaseURL is the Runway API endpoint
+	DefaultRunwayBaseURL = "https://api.dev.runwayml.com"
+
+	// DefaultRunwayModel is the Runway model used when none is configured
+	DefaultRunwayModel = "gen3a_turbo"
+
+	// runwayVersion is the version of the Runway API the client speaks
+	runwayVersion = "2024-11-06"
+)
+
+// TaskStatus is the state of a Runway task
+type TaskStatus string
+
+// Runway task statuses
+const (
+	TaskPending   TaskStatus = "PENDING"
+	TaskThrottled TaskStatus = "THROTTLED" // queued until the account has capacity
+	TaskRunning   TaskStatus = "RUNNING"
+	TaskSucceeded TaskStatus = "SUCCEEDED"
+	TaskFailed    TaskStatus = "FAILED"
+	TaskCancelled TaskStatus = "CANCELLED"
+)
+
+// Finished reports whether a task in this status will not change again
+func (s TaskStatus) Finished() bool {
+	return s == TaskSucceeded || s == TaskFailed || s == TaskCancelled
+}
+
+// RunwayClient starts image-to-video tasks on the Runway API and follows them to the finished video
+type RunwayClient struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	HTTPClient *http.Client
+}
+
+// NewRunwayClient creates a Runway client with default settings
+func NewRunwayClient(apiKey string) *RunwayClient {
+	return &RunwayClient{
+		APIKey:     apiKey,
+		BaseURL:    DefaultRunwayBaseURL,
+		Model:      DefaultRunwayModel,
+		HTTPClient: transport.NewClient(transport.Runway, 120*time.Second), // Longer timeout for video generation
+	}
+}
+
+// ImageToVideoRequest is a video to generate from an image
+type ImageToVideoRequest struct {
+	PromptImage string `json:"promptImage"` // an HTTPS URL or a data URI
+	PromptText  string `json:"promptText,omitempty"`
+	Model       string `json:"model"`
+	Duration    int    `json:"duration,omitempty"` // in seconds, 5 or 10
+}
+
+// Task is the state of a Runway task
+type Task struct {
+	ID          string     `json:"id"`
+	Status      TaskStatus `json:"status"`
+	Progress    float64    `json:"progress"`    // from 0 to 1 while running
+	Output      []string   `json:"output"`      // URLs of the results once succeeded
+	Failure     string     `json:"failure"`     // why the task failed
+	FailureCode string     `json:"failureCode"` // e.g. SAFETY.INPUT.TEXT
+}
+
+// runwayError is the body of an error response
+type runwayError struct {
+	Error string `json:"error"`
+}
+
+// ImageToVideo starts generating a video and returns the task ID
+func (c *RunwayClient) ImageToVideo(ctx context.Context, request ImageToVideoRequest) (string, error) {
+	if request.Model == "" {
+		request.Model = c.Model
+	}
+
+	var task struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/image_to_video", request, &task); err != nil {
+		return "", err
+	}
+	if task.ID == "" {
+		return "", fmt.Errorf("no task ID in response")
+	}
+
+	return task.ID, nil
+}
+
+// Task returns the current state of a task
+func (c *RunwayClient) Task(ctx context.Context, taskID string) (Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodGet, "/v1/tasks/"+url.PathEscape(taskID), nil, &task); err != nil {
+		return Task{}, err
+	}
+
+	switch task.Status {
+	case TaskPending, TaskThrottled, TaskRunning, TaskSucceeded, TaskFailed, TaskCancelled:
+	default:
+		return Task{}, fmt.Errorf("unknown status %q for task %s", task.Status, taskID)
+	}
+	if task.Status == TaskSucceeded && len(task.Output) == 0 {
+		return Task{}, fmt.Errorf("task %s succeeded without output", taskID)
+	}
+
+	return task, nil
+}
+
+// Cancel cancels a running task, or deletes a finished one
+func (c *RunwayClient) Cancel(ctx context.Context, taskID string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/tasks/"+url.PathEscape(taskID), nil, nil)
+}
+
+// Download saves a task's output to path
+func (c *RunwayClient) Download(ctx context.Context, outputURL, path string) error {
+	return transport.Download(ctx, c.HTTPClient, outputURL, path)
+}
+
+// do sends a request to the Runway API and decodes its response into out. Only the method and
+// path are logged: request bodies carry whole images and the key never leaves the header.
+func (c *RunwayClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	header := http.Header{
+		"Authorization":    {"Bearer " + c.APIKey},
+		"X-Runway-Version": {runwayVersion},
+	}
+
+	log.Printf("Runway %s %s", method, path)
+	err := transport.DoJSON(ctx, c.HTTPClient, method, strings.TrimSuffix(c.BaseURL, "/")+path, header, body, out)
+
+	var statusErr *transport.StatusError
+	if errors.As(err, &statusErr) {
+		message := strings.TrimSpace(string(statusErr.Body))
+		var apiErr runwayError
+		if json.Unmarshal(statusErr.Body, &apiErr) == nil && apiErr.Error != "" {
+			message = apiErr.Error
+		}
+		return fmt.Errorf("runway API error %d: %s", statusErr.StatusCode, c.redact(message))
+	}
+	return err
+}
+
+// redact hides the API key wherever it appears in text
+func (c *RunwayClient) redact(text string) string {
+	if c.APIKey == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, c.APIKey, "[REDACTED]")
+}
@@ -0,0 +1,95 @@
+package videoconversion
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testRunwayClient creates a Runway client for a stand-in server, without retries
+func testRunwayClient(server *httptest.Server) *RunwayClient {
+	client := NewRunwayClient("secret-key")
+	client.BaseURL = server.URL
+	client.HTTPClient = server.Client()
+	return client
+}
+
+func TestRunwayClient_Task(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/tasks/task-1" {
+			t.Errorf("path = %s, want /v1/tasks/task-1", r.URL.Path)
+		}
+		w.Write([]byte(`{"id":"task-1","status":"RUNNING","progress":0.4,"createdAt":"2024-11-06T12:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	task, err := testRunwayClient(server).Task(context.Background(), "task-1")
+	if err != nil {
+		t.Fatalf("Task() error = %v", err)
+	}
+	if task.Status != TaskRunning || task.Progress != 0.4 || task.Status.Finished() {
+		t.Errorf("Task() = %+v, want an unfinished running task 40%% done", task)
+	}
+}
+
+func TestRunwayClient_Task_Invalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown status":    `{"id":"task-1","status":"completed"}`,
+		"succeeded, no URL": `{"id":"task-1","status":"SUCCEEDED","output":[]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			if _, err := testRunwayClient(server).Task(context.Background(), "task-1"); err == nil {
+				t.Error("Task() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestRunwayClient_Cancel(t *testing.T) {
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	if err := testRunwayClient(server).Cancel(context.Background(), "task-1"); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if method != http.MethodDelete || path != "/v1/tasks/task-1" {
+		t.Errorf("request = %s %s, want DELETE /v1/tasks/task-1", method, path)
+	}
+}
+
+func TestRunwayClient_Redacted(t *testing.T) {
+	// An error that echoes the request back must not reveal the key
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid key: ` + strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") + `"}`))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	_, err := testRunwayClient(server).ImageToVideo(context.Background(), ImageToVideoRequest{PromptImage: "data:image/png;base64,AAAA"})
+	if err == nil || !strings.Contains(err.Error(), "runway API error 401") {
+		t.Fatalf("ImageToVideo() error = %v, want the API error", err)
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("error %q reveals the key", err)
+	}
+	if strings.Contains(logs.String(), "secret-key") || strings.Contains(logs.String(), "base64") {
+		t.Errorf("logs %q reveal the key or the image", logs.String())
+	}
+}
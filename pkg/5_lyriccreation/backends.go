@@ -0,0 +1,33 @@
+package lyriccreation
+
+import (
+	"fmt"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/registry"
+)
+
+// Backends are the lyric creators that can be selected by name in the configuration
+var Backends = registry.New("lyric creation", "claude",
+	registry.Backend[config.LyricCreationConfig, common.LyricCreator]{
+		Name:        "claude",
+		Kind:        registry.Provider,
+		Description: "Claude writes a song about the stories, checked for meter, rhyme and originality",
+		New: func(config config.LyricCreationConfig) (common.LyricCreator, error) {
+			if config.ClaudeKey == "" {
+				return nil, fmt.Errorf("no Claude API key provided")
+			}
+			return New(config), nil
+		},
+	},
+	registry.Backend[config.LyricCreationConfig, common.LyricCreator]{
+		Name:        "mock",
+		Kind:        registry.Mock,
+		Description: "placeholder lyrics built from the headlines",
+		New: func(config config.LyricCreationConfig) (common.LyricCreator, error) {
+			config.Offline = true
+			return New(config), nil
+		},
+	},
+)
@@ -38,56 +38,172 @@ The module transforms news content into creative, original song lyrics that
 capture the essence of the day's stories while maintaining artistic integrity
 and avoiding any copyright issues. These lyrics will serve as the foundation
 for the music generation in the next stage.
+
+The module asks Claude to record each song through a tool call, so that its
+themes and sections can be checked before they are accepted. Each section is
+scored for meter and rhyme, and lyrics below the configured minimum are sent
+back to Claude with the lines that break the pattern. Every song is also
+compared with a corpus of known lyrics and the archive of past runs, and songs
+that share too much with either are sent back with the matching passages. In
+offline mode the module skips Claude and returns fixed placeholder lyrics.
 */
 
 package lyriccreation
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/llm"
+	"github.com/iantozer/stitch-up/pkg/prompts"
 )
 
+// maxPromptArticles limits how many of the day's articles are sent to Claude
+const maxPromptArticles = 10
+
 // Creator implements the LyricCreator interface
 type Creator struct {
-	config config.LyricCreationConfig
+	config  config.LyricCreationConfig
+	client  *llm.Client
+	prompts *prompts.Set
+}
+
+// promptData is passed to the lyrics prompt template
+type promptData struct {
+	common.Content
+	Tool string // the tool Claude must record the song with
 }
 
 // New creates a new lyric creator
 func New(config config.LyricCreationConfig) common.LyricCreator {
 	return &Creator{
 		config: config,
+		client: llm.New(llm.Options{
+			APIKey:  config.ClaudeKey,
+			BaseURL: config.ClaudeBaseURL,
+			Model:   config.ClaudeModel,
+		}),
+		prompts: prompts.New(config.PromptDir),
 	}
 }
 
-// Create generates lyrics based on content using Claude
+// Create generates lyrics based on content using Claude, or placeholder lyrics in offline mode
 func (c *Creator) Create(ctx context.Context, content common.Content) (common.Lyrics, error) {
+	if c.config.Offline {
+		log.Println("Offline mode, using placeholder lyrics")
+		text := generatePlaceholderLyrics(content)
+		return common.Lyrics{
+			Title:    fmt.Sprintf("News of the Day: %s", content.Date),
+			Content:  text,
+			Sections: common.ParseLyrics(text),
+			Genre:    "pop",
+			Tempo:    100,
+			Mood:     "reflective",
+		}, nil
+	}
+
 	log.Println("Creating lyrics based on news content using Claude")
 
-	// In a real implementation, this would:
-	// 1. Format the content for Claude
-	// 2. Send a request to Claude API asking for original lyrics
-	// 3. Parse the response to extract the lyrics
+	if c.config.ClaudeKey == "" {
+		return common.Lyrics{}, fmt.Errorf("no Claude API key provided; use the mock backend for placeholder lyrics")
+	}
+	if len(content.Articles) == 0 {
+		return common.Lyrics{}, fmt.Errorf("no articles to write lyrics about")
+	}
+
+	// Limit the stories sent to Claude
+	if len(content.Articles) > maxPromptArticles {
+		content.Articles = content.Articles[:maxPromptArticles]
+	}
+
+	prompt, err := c.prompts.Render(prompts.Lyrics, promptData{Content: content, Tool: lyricsToolName})
+	if err != nil {
+		return common.Lyrics{}, err
+	}
+
+	maxAttempts := c.config.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = defaultMaxAttempts
+	}
 
-	// For now, we'll return a placeholder
-	title := fmt.Sprintf("News of the Day: %s", content.Date)
-	lyricsContent := generatePlaceholderLyrics(content)
+	// Known lyrics and our own past songs that new lyrics must not repeat
+	checker, err := NewChecker(c.config.CorpusDir, c.config.ArchiveDir, c.config.ShingleSize, c.config.MaxSimilarity)
+	if err != nil {
+		return common.Lyrics{}, err
+	}
+
+	// Ask Claude for the song, re-prompting until it has the structure we need, is original and scans well enough
+	var (
+		lyrics common.Lyrics
+		best   *common.Lyrics
+		score  float64
+	)
+	request := llm.Request{
+		Messages: []llm.Message{{Role: "user", Content: []llm.ContentBlock{llm.TextBlock(prompt)}}},
+	}
+	err = c.client.CallTool(ctx, request, lyricsTool, maxAttempts, func(input json.RawMessage) error {
+		s, err := decodeSong(input)
+		if err != nil {
+			return err
+		}
+		if err := validateSong(s); err != nil {
+			return err
+		}
+
+		lyrics = s.lyrics()
+
+		report := checker.Check(lyrics)
+		if !checker.Original(report) {
+			return fmt.Errorf("the lyrics are too close to existing lyrics: %s\nRewrite these passages in your own words", report)
+		}
+		if report.Similarity > 0 {
+			log.Printf("Lyrics share passages with known lyrics: %s", report)
+		}
+
+		if c.config.MinScore <= 0 {
+			return nil
+		}
+
+		analysis := Analyze(lyrics)
+		if best == nil || analysis.Score > score {
+			scored := lyrics
+			best, score = &scored, analysis.Score
+		}
+		if analysis.Score < c.config.MinScore {
+			return fmt.Errorf("%s\nRewrite the lines listed so that every section keeps a steady meter and rhyme scheme", analysis.Feedback())
+		}
+		return nil
+	})
+	if err != nil {
+		if best == nil || ctx.Err() != nil {
+			return common.Lyrics{}, fmt.Errorf("failed to create lyrics: %w", err)
+		}
+
+		// Well-formed lyrics that scan badly are still better than none
+		log.Printf("Warning: Using lyrics scoring %.2f, below the minimum of %.2f: %v", score, c.config.MinScore, err)
+		lyrics = *best
+	}
 
-	lyrics := common.Lyrics{
-		Title:   title,
-		Content: lyricsContent,
+	// Archive the song so that later runs don't repeat it
+	if c.config.ArchiveDir != "" {
+		path, err := Archive(c.config.ArchiveDir, lyrics, time.Now())
+		if err != nil {
+			return common.Lyrics{}, err
+		}
+		log.Printf("Archived lyrics to %s", path)
 	}
 
-	log.Printf("Created lyrics with title: %s", lyrics.Title)
+	log.Printf("Created lyrics with title: %s (themes: %s) using %s", lyrics.Title, strings.Join(lyrics.Themes, ", "), c.client.Usage())
 	return lyrics, nil
 }
 
-// generatePlaceholderLyrics creates placeholder lyrics based on content
-// In a real implementation, this would be replaced by Claude's response
+// generatePlaceholderLyrics creates placeholder lyrics based on content for offline runs
 func generatePlaceholderLyrics(content common.Content) string {
 	var sb strings.Builder
 
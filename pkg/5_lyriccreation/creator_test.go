@@ -2,17 +2,23 @@ package lyriccreation
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/llm"
 )
 
 func TestCreator_Create(t *testing.T) {
 	// Create test configuration
-	cfg := config.LyricCreationConfig{}
+	cfg := config.LyricCreationConfig{Offline: true}
 
 	// Create creator instance
 	creator := New(cfg)
@@ -69,6 +75,11 @@ func TestCreator_Create(t *testing.T) {
 		}
 	}
 
+	// Offline lyrics are structured too
+	if len(lyrics.Sections) != 5 || lyrics.Sections[0].String() != "verse 1" || lyrics.Tempo == 0 {
+		t.Errorf("Create() returned sections %+v, tempo %d", lyrics.Sections, lyrics.Tempo)
+	}
+
 	// Check for reasonable line count
 	lines := strings.Split(lyrics.Content, "\n")
 	if len(lines) < 10 {
@@ -78,7 +89,7 @@ func TestCreator_Create(t *testing.T) {
 
 func TestCreator_Create_EmptyContent(t *testing.T) {
 	// Test with empty content
-	cfg := config.LyricCreationConfig{}
+	cfg := config.LyricCreationConfig{Offline: true}
 	creator := New(cfg)
 	ctx := context.Background()
 	content := common.Content{
@@ -89,8 +100,7 @@ func TestCreator_Create_EmptyContent(t *testing.T) {
 	}
 
 	lyrics, err := creator.Create(ctx, content)
-	// The current implementation still generates placeholder lyrics
-	// In a real implementation, this might return an error
+	// Offline mode still generates placeholder lyrics
 	if err != nil {
 		t.Errorf("Create() with empty content error = %v", err)
 	}
@@ -101,7 +111,7 @@ func TestCreator_Create_EmptyContent(t *testing.T) {
 
 func TestCreator_Create_ContentValidation(t *testing.T) {
 	// Test with invalid content
-	cfg := config.LyricCreationConfig{}
+	cfg := config.LyricCreationConfig{Offline: true}
 	creator := New(cfg)
 	ctx := context.Background()
 	content := common.Content{
@@ -112,8 +122,7 @@ func TestCreator_Create_ContentValidation(t *testing.T) {
 	}
 
 	lyrics, err := creator.Create(ctx, content)
-	// The current implementation still generates placeholder lyrics
-	// In a real implementation, this might validate the content more strictly
+	// Offline mode still generates placeholder lyrics
 	if err != nil {
 		t.Errorf("Create() with invalid content error = %v", err)
 	}
@@ -121,3 +130,148 @@ func TestCreator_Create_ContentValidation(t *testing.T) {
 		t.Error("Create() with invalid content returned empty lyrics")
 	}
 }
+
+func TestCreator_Create_NoKey(t *testing.T) {
+	// Without a key or offline mode there are no lyrics to make
+	creator := New(config.LyricCreationConfig{})
+
+	content := common.Content{Articles: []common.Article{{Title: "Market Update"}}}
+	if _, err := creator.Create(context.Background(), content); err == nil {
+		t.Error("Create() without a Claude key should return error")
+	}
+}
+
+func TestCreator_Create_Claude(t *testing.T) {
+	verse := []string{"Markets rise and markets fall", "Tickers running down the wall"}
+	chorus := []string{"This is the news tonight", "Holding on to the light"}
+	songs := []map[string]interface{}{
+		// The first song has no chorus and is sent back
+		{
+			"title": "Ticker Tape", "themes": []string{"money"}, "mood": "restless",
+			"sections": []map[string]interface{}{{"type": "verse", "lines": verse}, {"type": "verse", "lines": verse}, {"type": "bridge", "lines": verse}},
+		},
+		{
+			"title": "Ticker Tape", "themes": []string{"money", "machines"}, "mood": "restless", "genre": "synth pop", "tempo": 118,
+			"sections": []map[string]interface{}{
+				{"type": "verse", "lines": verse},
+				{"type": "Chorus", "lines": chorus},
+				{"type": "verse", "lines": verse},
+				{"type": "bridge", "lines": verse},
+				{"type": "chorus", "lines": chorus},
+			},
+		},
+	}
+
+	var requests []llm.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request llm.Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, request)
+
+		input, _ := json.Marshal(songs[len(requests)-1])
+		json.NewEncoder(w).Encode(llm.Response{
+			Content: []llm.ContentBlock{{Type: "tool_use", ID: fmt.Sprintf("toolu_%d", len(requests)), Name: lyricsToolName, Input: input}},
+		})
+	}))
+	defer server.Close()
+
+	archive := t.TempDir()
+	creator := New(config.LyricCreationConfig{ClaudeKey: "test-key", ClaudeBaseURL: server.URL, ArchiveDir: archive})
+
+	content := common.Content{
+		Date: "March 12, 2025",
+		Articles: []common.Article{
+			{Title: "Market Update", Summary: "Shares rallied on Wall Street."},
+			{Title: "Technology News", Content: "A new chip was unveiled."},
+		},
+	}
+	lyrics, err := creator.Create(context.Background(), content)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// The prompt carries the day's stories
+	prompt := requests[0].Messages[0].Content[0].Text
+	for _, want := range []string{"(March 12, 2025)", "1. Market Update\n   Shares rallied on Wall Street.", "2. Technology News\n   A new chip was unveiled.", lyricsToolName} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+
+	// The rejected song was sent back with its problems
+	if len(requests) != 2 {
+		t.Fatalf("made %d requests, want 2", len(requests))
+	}
+	if result := requests[1].Messages[2].Content[0]; !result.IsError || !strings.Contains(result.Content, "chorus") {
+		t.Errorf("unexpected tool result: %+v", result)
+	}
+
+	if lyrics.Title != "Ticker Tape" || len(lyrics.Themes) != 2 || lyrics.Genre != "synth pop" || lyrics.Tempo != 118 || lyrics.Mood != "restless" {
+		t.Errorf("lyrics = %+v", lyrics)
+	}
+	if len(lyrics.Sections) != 5 || lyrics.Sections[2].String() != "verse 2" || lyrics.Sections[1].Lines[0].Text != chorus[0] {
+		t.Errorf("Sections = %+v", lyrics.Sections)
+	}
+
+	// The song is archived for later originality checks
+	if files, _ := filepath.Glob(filepath.Join(archive, "*_ticker-tape.txt")); len(files) != 1 {
+		t.Errorf("archived %v", files)
+	}
+	want := "VERSE 1:\n" + strings.Join(verse, "\n") + "\n\nCHORUS:\n" + strings.Join(chorus, "\n") + "\n\nVERSE 2:\n"
+	if !strings.HasPrefix(lyrics.Content, want) || !strings.Contains(lyrics.Content, "BRIDGE:") {
+		t.Errorf("Content = %q", lyrics.Content)
+	}
+}
+
+func TestCreator_Create_SendsBackScansionProblems(t *testing.T) {
+	good := []string{"Headlines flash across the screen", "Stories of a world unseen"}
+	bad := []string{"Headlines flash across the screen", "And nobody could tell what any of it was supposed to mean tonight"}
+	songWith := func(verse []string) map[string]interface{} {
+		chorus := []string{"This is the news of today", "Moments that will fade away"}
+		return map[string]interface{}{
+			"title": "Headlines", "themes": []string{"news"}, "mood": "wistful", "genre": "folk", "tempo": 90,
+			"sections": []map[string]interface{}{
+				{"type": "verse", "lines": verse}, {"type": "chorus", "lines": chorus}, {"type": "chorus", "lines": chorus},
+			},
+		}
+	}
+	songs := []map[string]interface{}{songWith(bad), songWith(good)}
+
+	var requests []llm.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request llm.Request
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+
+		input, _ := json.Marshal(songs[len(requests)-1])
+		json.NewEncoder(w).Encode(llm.Response{
+			Content: []llm.ContentBlock{{Type: "tool_use", ID: fmt.Sprintf("toolu_%d", len(requests)), Name: lyricsToolName, Input: input}},
+		})
+	}))
+	defer server.Close()
+
+	creator := New(config.LyricCreationConfig{ClaudeKey: "test-key", ClaudeBaseURL: server.URL, MinScore: 0.9})
+
+	content := common.Content{Articles: []common.Article{{Title: "Market Update"}}}
+	lyrics, err := creator.Create(context.Background(), content)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// The badly scanning verse went back with the line to fix
+	if len(requests) != 2 {
+		t.Fatalf("made %d requests, want 2", len(requests))
+	}
+	result := requests[1].Messages[2].Content[0]
+	if !result.IsError || !strings.Contains(result.Content, `verse 1, line 2 ("And nobody could tell`) || !strings.Contains(result.Content, "syllables") {
+		t.Errorf("unexpected tool result: %s", result.Content)
+	}
+
+	if lyrics.Sections[0].Lines[1].Text != good[1] {
+		t.Errorf("Create() kept the rejected verse: %+v", lyrics.Sections[0])
+	}
+}
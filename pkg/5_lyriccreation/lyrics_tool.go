@@ -0,0 +1,194 @@
+package lyriccreation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/llm"
+)
+
+// lyricsToolName is the tool Claude is forced to call with the song
+const lyricsToolName = "record_song"
+
+// defaultMaxAttempts is used when no attempt limit is configured
+const defaultMaxAttempts = 3
+
+// Limits enforced on the song Claude records
+const (
+	maxTitleLength  = 80
+	minSections     = 3
+	maxSections     = 12
+	minSectionLines = 2
+	maxSectionLines = 12
+	maxLineLength   = 100
+	minTempo        = 50
+	maxTempo        = 200
+)
+
+// lyricsTool describes the song fields to Claude as a JSON schema
+var lyricsTool = llm.Tool{
+	Name:        lyricsToolName,
+	Description: "Record an original song about the day's news.",
+	InputSchema: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "title": {"type": "string", "description": "The song title"},
+    "themes": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 6, "description": "The key themes drawn from the news, one short phrase each"},
+    "mood": {"type": "string", "description": "The emotional tone of the song, e.g. defiant, wistful, hopeful"},
+    "genre": {"type": "string", "description": "The musical genre the song is written for, e.g. indie folk, synth pop"},
+    "tempo": {"type": "integer", "minimum": 50, "maximum": 200, "description": "The tempo in beats per minute"},
+    "sections": {
+      "type": "array",
+      "description": "The sections of the song in the order they are sung; repeat the chorus section wherever it is sung",
+      "items": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string", "enum": ["intro", "verse", "pre-chorus", "chorus", "bridge", "outro"]},
+          "lines": {"type": "array", "items": {"type": "string"}, "description": "The lines of the section, one sung line each"}
+        },
+        "required": ["type", "lines"]
+      }
+    }
+  },
+  "required": ["title", "themes", "mood", "genre", "tempo", "sections"]
+}`),
+}
+
+// song is the input Claude records with the lyrics tool
+type song struct {
+	Title    string        `json:"title"`
+	Themes   []string      `json:"themes"`
+	Mood     string        `json:"mood"`
+	Genre    string        `json:"genre"`
+	Tempo    int           `json:"tempo"`
+	Sections []songSection `json:"sections"`
+}
+
+// songSection is a single section of a song
+type songSection struct {
+	Type  string   `json:"type"`
+	Lines []string `json:"lines"`
+}
+
+// decodeSong converts the tool input into a song, tidying whitespace and section types
+func decodeSong(input json.RawMessage) (song, error) {
+	var s song
+	if err := json.Unmarshal(input, &s); err != nil {
+		return song{}, fmt.Errorf("input does not match the schema: %v", err)
+	}
+
+	s.Title = strings.TrimSpace(s.Title)
+	s.Mood = strings.TrimSpace(s.Mood)
+	s.Genre = strings.TrimSpace(s.Genre)
+	for i := range s.Sections {
+		s.Sections[i].Type = strings.ToLower(strings.TrimSpace(s.Sections[i].Type))
+		for j := range s.Sections[i].Lines {
+			s.Sections[i].Lines[j] = strings.TrimSpace(s.Sections[i].Lines[j])
+		}
+	}
+
+	return s, nil
+}
+
+// validateSong checks a song against the structure asked for in the prompt, listing every problem found
+func validateSong(s song) error {
+	var problems []string
+
+	switch {
+	case s.Title == "":
+		problems = append(problems, "title is required")
+	case len(s.Title) > maxTitleLength:
+		problems = append(problems, fmt.Sprintf("title must be at most %d characters", maxTitleLength))
+	}
+
+	if len(s.Themes) == 0 {
+		problems = append(problems, "at least one theme is required")
+	}
+	if s.Mood == "" {
+		problems = append(problems, "mood is required")
+	}
+	if s.Genre == "" {
+		problems = append(problems, "genre is required")
+	}
+	if s.Tempo < minTempo || s.Tempo > maxTempo {
+		problems = append(problems, fmt.Sprintf("tempo must be between %d and %d beats per minute", minTempo, maxTempo))
+	}
+
+	if len(s.Sections) < minSections || len(s.Sections) > maxSections {
+		problems = append(problems, fmt.Sprintf("song has %d sections, it must have between %d and %d", len(s.Sections), minSections, maxSections))
+	}
+
+	counts := map[string]int{}
+	for i, section := range s.Sections {
+		counts[section.Type]++
+
+		if !containsString(common.SectionTypes, section.Type) {
+			problems = append(problems, fmt.Sprintf("section %d has unknown type %q", i+1, section.Type))
+		}
+		if len(section.Lines) < minSectionLines || len(section.Lines) > maxSectionLines {
+			problems = append(problems, fmt.Sprintf("section %d has %d lines, it must have between %d and %d", i+1, len(section.Lines), minSectionLines, maxSectionLines))
+		}
+		for _, line := range section.Lines {
+			if line == "" {
+				problems = append(problems, fmt.Sprintf("section %d has an empty line", i+1))
+				break
+			}
+			if len(line) > maxLineLength {
+				problems = append(problems, fmt.Sprintf("section %d has a line longer than %d characters", i+1, maxLineLength))
+				break
+			}
+		}
+	}
+
+	if counts[common.SectionVerse] == 0 {
+		problems = append(problems, "at least one verse is required")
+	}
+	if counts[common.SectionChorus] < 2 {
+		problems = append(problems, "the chorus must be sung at least twice")
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// lyrics converts the song into structured lyrics
+func (s song) lyrics() common.Lyrics {
+	lyrics := common.Lyrics{
+		Title:  s.Title,
+		Themes: s.Themes,
+		Genre:  s.Genre,
+		Tempo:  s.Tempo,
+		Mood:   s.Mood,
+	}
+
+	verses := 0
+	for _, section := range s.Sections {
+		converted := common.Section{Type: section.Type}
+		if section.Type == common.SectionVerse {
+			verses++
+			converted.Number = verses
+		}
+		for _, line := range section.Lines {
+			converted.Lines = append(converted.Lines, common.Line{Text: line})
+		}
+		lyrics.Sections = append(lyrics.Sections, converted)
+	}
+	lyrics.Content = common.RenderLyrics(lyrics.Sections)
+
+	return lyrics
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
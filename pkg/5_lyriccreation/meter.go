@@ -0,0 +1,326 @@
+package lyriccreation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+)
+
+// Analysis scores how well lyrics scan and rhyme
+type Analysis struct {
+	Score    float64 // mean of the section scores, from 0 to 1
+	Sections []SectionAnalysis
+}
+
+// SectionAnalysis scores the meter and rhyme of a single section
+type SectionAnalysis struct {
+	Section    string // e.g. "verse 1"
+	Syllables  []int  // syllables in each line
+	Scheme     string // the rhyme scheme the section follows best, e.g. "AABB"
+	MeterScore float64
+	RhymeScore float64
+	Score      float64
+	Problems   []LineProblem
+}
+
+// LineProblem is a line that breaks its section's meter or rhyme scheme
+type LineProblem struct {
+	Line   int // 1-based line number within the section
+	Text   string
+	Reason string
+}
+
+// rhymeSchemes are the schemes a section is checked against, as pairs of line offsets within a
+// repeating group that should rhyme
+var rhymeSchemes = []struct {
+	name  string
+	size  int
+	pairs [][2]int
+}{
+	{"AABB", 4, [][2]int{{0, 1}, {2, 3}}},
+	{"ABAB", 4, [][2]int{{0, 2}, {1, 3}}},
+	{"ABCB", 4, [][2]int{{1, 3}}},
+	{"AA", 2, [][2]int{{0, 1}}},
+}
+
+// Analyze scores the meter and rhyme of every section of the lyrics
+func Analyze(lyrics common.Lyrics) Analysis {
+	var analysis Analysis
+
+	for _, section := range lyrics.Sections {
+		if len(section.Lines) == 0 {
+			continue
+		}
+		analysis.Sections = append(analysis.Sections, analyzeSection(section))
+	}
+
+	if len(analysis.Sections) == 0 {
+		return analysis
+	}
+
+	for _, section := range analysis.Sections {
+		analysis.Score += section.Score
+	}
+	analysis.Score /= float64(len(analysis.Sections))
+
+	return analysis
+}
+
+// Feedback describes the problems found, for a songwriter to correct
+func (a Analysis) Feedback() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The lyrics scored %.2f for meter and rhyme.", a.Score)
+
+	for _, section := range a.Sections {
+		for _, problem := range section.Problems {
+			fmt.Fprintf(&sb, "\n- %s, line %d (%q): %s", section.Section, problem.Line, problem.Text, problem.Reason)
+		}
+	}
+
+	return sb.String()
+}
+
+// analyzeSection checks a section's lines against its typical line length and best rhyme scheme
+func analyzeSection(section common.Section) SectionAnalysis {
+	result := SectionAnalysis{Section: section.String()}
+
+	var endings []string
+	for _, line := range section.Lines {
+		words := common.LineWords(line.Text)
+		syllables := 0
+		for _, word := range words {
+			syllables += countSyllables(word)
+		}
+		result.Syllables = append(result.Syllables, syllables)
+
+		ending := ""
+		if len(words) > 0 {
+			ending = rhymeEnding(words[len(words)-1])
+		}
+		endings = append(endings, ending)
+	}
+
+	// Meter: lines should stay close to the section's typical length
+	typical := median(result.Syllables)
+	tolerance := max(2, typical/4)
+	inMeter := 0
+	for i, syllables := range result.Syllables {
+		if abs(syllables-typical) <= tolerance {
+			inMeter++
+			continue
+		}
+		result.Problems = append(result.Problems, LineProblem{
+			Line:   i + 1,
+			Text:   section.Lines[i].Text,
+			Reason: fmt.Sprintf("has %d syllables where the other lines have about %d", syllables, typical),
+		})
+	}
+	result.MeterScore = float64(inMeter) / float64(len(result.Syllables))
+
+	// Rhyme: find the scheme the most line pairs follow
+	result.RhymeScore = 1
+	var misses [][2]int
+	if len(endings) >= 2 {
+		result.RhymeScore = -1
+		for _, scheme := range rhymeSchemes {
+			pairs := schemePairs(scheme.size, scheme.pairs, len(endings))
+			if len(pairs) == 0 {
+				continue
+			}
+
+			var schemeMisses [][2]int
+			for _, pair := range pairs {
+				if !rhymes(endings[pair[0]], endings[pair[1]]) {
+					schemeMisses = append(schemeMisses, pair)
+				}
+			}
+
+			score := float64(len(pairs)-len(schemeMisses)) / float64(len(pairs))
+			if score > result.RhymeScore {
+				result.RhymeScore, result.Scheme, misses = score, scheme.name, schemeMisses
+			}
+		}
+	}
+
+	// Only report rhymes for sections that mostly follow a scheme, otherwise every line is a problem
+	if result.RhymeScore >= 0.5 {
+		for _, miss := range misses {
+			result.Problems = append(result.Problems, LineProblem{
+				Line:   miss[1] + 1,
+				Text:   section.Lines[miss[1]].Text,
+				Reason: fmt.Sprintf("does not rhyme with line %d in the %s scheme", miss[0]+1, result.Scheme),
+			})
+		}
+	} else {
+		result.Problems = append(result.Problems, LineProblem{
+			Line:   1,
+			Text:   section.Lines[0].Text,
+			Reason: "the section does not follow a rhyme scheme",
+		})
+	}
+
+	sort.SliceStable(result.Problems, func(i, j int) bool {
+		return result.Problems[i].Line < result.Problems[j].Line
+	})
+
+	result.Score = (result.MeterScore + result.RhymeScore) / 2
+	return result
+}
+
+// schemePairs repeats a scheme's pairs across n lines, dropping pairs past the last line
+func schemePairs(size int, pattern [][2]int, n int) [][2]int {
+	var pairs [][2]int
+	for start := 0; start < n; start += size {
+		for _, pair := range pattern {
+			if start+pair[1] < n {
+				pairs = append(pairs, [2]int{start + pair[0], start + pair[1]})
+			}
+		}
+	}
+	return pairs
+}
+
+// syllableExceptions are common words the vowel-group rules count wrongly
+var syllableExceptions = map[string]int{
+	"the": 1, "every": 3, "everything": 4, "evening": 2, "fire": 1, "hour": 1, "our": 1,
+	"people": 2, "being": 2, "seeing": 2, "idea": 3, "area": 3, "real": 1, "poem": 2,
+	"quiet": 2, "science": 2, "lion": 2, "world": 1, "business": 2, "different": 3,
+	"some": 1, "one": 1, "once": 1, "done": 1, "gone": 1, "come": 1, "where": 1, "there": 1,
+	"here": 1, "were": 1, "more": 1, "whole": 1, "whose": 1, "eyes": 1, "sometimes": 2,
+	"rhythm": 2,
+}
+
+// countSyllables estimates the syllables in an English word from its vowel groups
+func countSyllables(word string) int {
+	word = strings.Trim(strings.ToLower(word), "'")
+	word = strings.TrimSuffix(word, "'s")
+	if word == "" {
+		return 0
+	}
+	if n, ok := syllableExceptions[word]; ok {
+		return n
+	}
+
+	count := 0
+	prevVowel := false
+	for i, r := range word {
+		vowel := strings.ContainsRune("aeiou", r) || (r == 'y' && i > 0)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+
+	// A final silent "e" does not add a syllable, but "-le" after a consonant does ("table")
+	if strings.HasSuffix(word, "e") && count > 1 {
+		if !(strings.HasSuffix(word, "le") && len(word) > 2 && !isVowel(word[len(word)-3])) &&
+			!strings.HasSuffix(word, "ee") && !strings.HasSuffix(word, "ie") {
+			count--
+		}
+	}
+
+	// "-ed" and "-es" are silent unless they follow a sound that needs them ("wanted", "wishes")
+	if count > 1 && len(word) > 3 {
+		before := word[len(word)-3]
+		if strings.HasSuffix(word, "ed") && before != 't' && before != 'd' && !isVowel(before) {
+			count--
+		} else if strings.HasSuffix(word, "es") && !strings.ContainsRune("sxzhgc", rune(before)) && !isVowel(before) && before != 'l' {
+			count--
+		}
+	}
+
+	return max(count, 1)
+}
+
+// rhymeSpellings maps word endings that sound alike to a shared key, longest endings first
+var rhymeSpellings = []struct{ suffix, sound string }{
+	{"eighed", "ade"}, {"ayed", "ade"}, {"ight", "ite"}, {"eigh", "ay"},
+	{"aid", "ade"}, {"ade", "ade"}, {"ign", "ine"}, {"ine", "ine"}, {"ite", "ite"}, {"yte", "ite"},
+	{"een", "een"}, {"ean", "een"}, {"ene", "een"}, {"ain", "ane"}, {"ane", "ane"}, {"aim", "ame"}, {"ame", "ame"},
+	{"ire", "ire"}, {"yre", "ire"}, {"ore", "ore"}, {"oar", "ore"}, {"oor", "ore"}, {"ear", "eer"}, {"eer", "eer"}, {"ere", "eer"},
+	{"ough", "oo"}, {"ew", "oo"}, {"ue", "oo"}, {"oo", "oo"}, {"ay", "ay"}, {"ee", "ee"}, {"ea", "ee"},
+}
+
+// rhymeExceptions are common words whose ending is not spelled the way it sounds
+var rhymeExceptions = map[string]string{
+	"they": "ay", "obey": "ay", "grey": "ay", "prey": "ay", "there": "air", "where": "air", "were": "ur",
+	"here": "eer", "been": "in", "said": "ed", "says": "ez", "one": "un", "done": "un", "gone": "on",
+	"come": "um", "some": "um", "love": "uv", "above": "uv", "move": "oov", "prove": "oov",
+}
+
+// rhymeEnding returns a key for the sound a word ends on; words that rhyme share a key
+func rhymeEnding(word string) string {
+	word = strings.Trim(strings.ToLower(word), "'")
+	word = strings.TrimSuffix(word, "'s")
+	if word == "" {
+		return ""
+	}
+	if sound, ok := rhymeExceptions[word]; ok {
+		return sound
+	}
+
+	for _, spelling := range rhymeSpellings {
+		if strings.HasSuffix(word, spelling.suffix) {
+			return spelling.sound
+		}
+	}
+
+	// Words whose only vowel is a final "e" end on an "ee" sound: "me" and "free"
+	if strings.IndexAny(word, "aeiou") == len(word)-1 && word[len(word)-1] == 'e' {
+		return "ee"
+	}
+
+	// Final "y" sounds like "lie" in single-syllable words and like "free" in longer ones
+	if strings.HasSuffix(word, "y") {
+		if countSyllables(word) == 1 {
+			return "ie"
+		}
+		return "ee"
+	}
+
+	// A vowel, consonant and silent "e" make the vowel long: "rise" sounds like "skies"
+	if n := len(word); n >= 3 && word[n-1] == 'e' && !isVowel(word[n-2]) && isVowel(word[n-3]) && (n == 3 || !isVowel(word[n-4])) {
+		return word[n-3:n-2] + "e" + word[n-2:n-1]
+	}
+
+	// Otherwise use the last vowel group and the consonants after it
+	start := len(word) - 1
+	for start >= 0 && !isVowel(word[start]) {
+		start--
+	}
+	for start > 0 && isVowel(word[start-1]) {
+		start--
+	}
+	if start < 0 {
+		return word
+	}
+
+	return word[start:]
+}
+
+// rhymes reports whether two endings rhyme
+func rhymes(a, b string) bool {
+	return a != "" && a == b
+}
+
+// isVowel reports whether b is one of a, e, i, o or u
+func isVowel(b byte) bool {
+	return strings.IndexByte("aeiou", b) >= 0
+}
+
+// median returns the middle value of values, the lower of the two for an even count
+func median(values []int) int {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[(len(sorted)-1)/2]
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,87 @@
+package lyriccreation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+)
+
+func TestCountSyllables(t *testing.T) {
+	tests := map[string]int{
+		"news": 1, "today": 2, "screen": 1, "headlines": 2, "table": 2, "wanted": 2, "weighed": 1,
+		"information": 4, "paradigm": 3, "wishes": 2, "city": 2, "the": 1, "fire": 1, "rhythm": 2,
+	}
+
+	for word, want := range tests {
+		if got := countSyllables(word); got != want {
+			t.Errorf("countSyllables(%q) = %d, want %d", word, got, want)
+		}
+	}
+}
+
+func TestRhymeEnding(t *testing.T) {
+	rhyming := [][2]string{
+		{"today", "away"}, {"screen", "unseen"}, {"fade", "weighed"}, {"night", "write"},
+		{"free", "me"}, {"told", "unfold"}, {"sky", "lie"}, {"rise", "skies"}, {"nation", "information"},
+	}
+	for _, pair := range rhyming {
+		if !rhymes(rhymeEnding(pair[0]), rhymeEnding(pair[1])) {
+			t.Errorf("%q and %q should rhyme (%s, %s)", pair[0], pair[1], rhymeEnding(pair[0]), rhymeEnding(pair[1]))
+		}
+	}
+
+	different := [][2]string{{"today", "screen"}, {"night", "fade"}, {"fall", "told"}}
+	for _, pair := range different {
+		if rhymes(rhymeEnding(pair[0]), rhymeEnding(pair[1])) {
+			t.Errorf("%q and %q should not rhyme", pair[0], pair[1])
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	lyrics := common.Lyrics{Sections: common.ParseLyrics(`VERSE 1:
+Headlines flash across the screen
+Stories of a world unseen
+Markets fall and markets rise
+Underneath the city skies
+
+CHORUS:
+This is the news of today
+Moments that will fade away
+But in these words we find our way, we find our way through all the noise and all the rain
+Through the stories of the night
+`)}
+
+	analysis := Analyze(lyrics)
+	if len(analysis.Sections) != 2 {
+		t.Fatalf("got %d sections, want 2", len(analysis.Sections))
+	}
+
+	// The verse scans and rhymes in couplets
+	verse := analysis.Sections[0]
+	if verse.Score != 1 || verse.Scheme != "AABB" || len(verse.Problems) != 0 {
+		t.Errorf("verse = %+v", verse)
+	}
+
+	// The chorus has one overlong line and one broken rhyme
+	chorus := analysis.Sections[1]
+	if chorus.Scheme != "AABB" || chorus.MeterScore != 0.75 || chorus.RhymeScore != 0.5 {
+		t.Errorf("chorus = %+v", chorus)
+	}
+	if len(chorus.Problems) != 2 || chorus.Problems[0].Line != 3 || chorus.Problems[1].Line != 4 {
+		t.Fatalf("chorus problems = %+v", chorus.Problems)
+	}
+	if !strings.Contains(chorus.Problems[0].Reason, "syllables") || !strings.Contains(chorus.Problems[1].Reason, "does not rhyme with line 3") {
+		t.Errorf("chorus problems = %+v", chorus.Problems)
+	}
+
+	if analysis.Score >= 1 || analysis.Score <= 0.5 {
+		t.Errorf("Score = %.2f", analysis.Score)
+	}
+
+	feedback := analysis.Feedback()
+	if !strings.Contains(feedback, `chorus, line 4 ("Through the stories of the night")`) {
+		t.Errorf("Feedback() = %s", feedback)
+	}
+}
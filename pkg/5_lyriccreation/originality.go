@@ -0,0 +1,283 @@
+package lyriccreation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+)
+
+const (
+	// defaultShingleSize is the number of consecutive words compared when none is configured
+	defaultShingleSize = 5
+
+	// defaultMaxSimilarity is used when no similarity threshold is configured
+	defaultMaxSimilarity = 0.3
+)
+
+// Checker compares lyrics against known lyrics using overlapping word n-grams ("shingles")
+type Checker struct {
+	shingleSize   int
+	maxSimilarity float64
+	documents     []document
+}
+
+// document is a known song, indexed by its shingles
+type document struct {
+	name     string
+	lines    []string
+	shingles map[string]int // shingle to the line it starts on
+}
+
+// OriginalityReport describes how closely lyrics match the most similar known song
+type OriginalityReport struct {
+	Similarity float64 // share of a section's shingles found in the source, for the worst section
+	Source     string  // the most similar known song
+	Section    string  // the section that matched it most closely
+	Matches    []Match
+}
+
+// Match is a passage of the lyrics that also appears in a known song
+type Match struct {
+	Source  string
+	Passage string // the matching lines of the lyrics
+	Found   string // the matching lines of the known song
+}
+
+// NewChecker loads every .txt file in the corpus and archive directories. The corpus directory
+// must exist; the archive directory may not exist yet.
+func NewChecker(corpusDir, archiveDir string, shingleSize int, maxSimilarity float64) (*Checker, error) {
+	if shingleSize <= 0 {
+		shingleSize = defaultShingleSize
+	}
+	if maxSimilarity <= 0 {
+		maxSimilarity = defaultMaxSimilarity
+	}
+
+	checker := &Checker{
+		shingleSize:   shingleSize,
+		maxSimilarity: maxSimilarity,
+	}
+
+	if corpusDir != "" {
+		if _, err := os.Stat(corpusDir); err != nil {
+			return nil, fmt.Errorf("failed to read lyrics corpus: %w", err)
+		}
+		if err := checker.loadDir(corpusDir); err != nil {
+			return nil, err
+		}
+	}
+
+	if archiveDir != "" {
+		if err := checker.loadDir(archiveDir); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return checker, nil
+}
+
+// loadDir indexes every .txt file in dir
+func (c *Checker) loadDir(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return fmt.Errorf("failed to list lyrics in %s: %w", dir, err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read lyrics: %w", err)
+		}
+		c.Add(filepath.Base(file), string(text))
+	}
+
+	return nil
+}
+
+// Add indexes a known song
+func (c *Checker) Add(name, text string) {
+	doc := document{name: name, shingles: map[string]int{}}
+
+	var words []string
+	var wordLines []int
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		doc.lines = append(doc.lines, line)
+		for _, word := range common.LineWords(line) {
+			words = append(words, word)
+			wordLines = append(wordLines, len(doc.lines)-1)
+		}
+	}
+
+	for i := 0; i+c.shingleSize <= len(words); i++ {
+		key := strings.Join(words[i:i+c.shingleSize], " ")
+		if _, ok := doc.shingles[key]; !ok {
+			doc.shingles[key] = wordLines[i]
+		}
+	}
+
+	c.documents = append(c.documents, doc)
+}
+
+// Check compares each section of the lyrics with every known song, reporting the closest match
+func (c *Checker) Check(lyrics common.Lyrics) OriginalityReport {
+	var report OriginalityReport
+
+	for _, section := range lyrics.Sections {
+		words, wordLines := sectionWords(section)
+		if len(words) < c.shingleSize {
+			continue
+		}
+
+		for _, doc := range c.documents {
+			var (
+				total   int
+				matched []int
+				seen    = map[string]bool{}
+			)
+			for i := 0; i+c.shingleSize <= len(words); i++ {
+				key := strings.Join(words[i:i+c.shingleSize], " ")
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				total++
+				if _, ok := doc.shingles[key]; ok {
+					matched = append(matched, i)
+				}
+			}
+
+			similarity := float64(len(matched)) / float64(total)
+			if similarity <= report.Similarity || len(matched) == 0 {
+				continue
+			}
+
+			report.Similarity = similarity
+			report.Source = doc.name
+			report.Section = section.String()
+			report.Matches = passages(doc, section, words, wordLines, matched, c.shingleSize)
+		}
+	}
+
+	return report
+}
+
+// Original reports whether the lyrics are below the similarity threshold
+func (c *Checker) Original(report OriginalityReport) bool {
+	return report.Similarity <= c.maxSimilarity
+}
+
+// String describes the report and its matching passages
+func (r OriginalityReport) String() string {
+	if r.Similarity == 0 {
+		return "no passages match known lyrics"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%.0f%% of the %s matches %s", r.Similarity*100, r.Section, r.Source)
+	for _, match := range r.Matches {
+		fmt.Fprintf(&sb, "\n- %q matches %q", match.Passage, match.Found)
+	}
+	return sb.String()
+}
+
+// Archive saves the lyrics so later runs are checked against them, returning the file written
+func Archive(dir string, lyrics common.Lyrics, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create lyrics archive: %w", err)
+	}
+
+	name := now.Format("2006-01-02_150405") + "_" + slug(lyrics.Title) + ".txt"
+	path := filepath.Join(dir, name)
+
+	text := common.RenderLyrics(lyrics.Sections)
+	if len(lyrics.Sections) == 0 {
+		text = lyrics.Content
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return "", fmt.Errorf("failed to archive lyrics: %w", err)
+	}
+
+	return path, nil
+}
+
+// sectionWords returns the words of a section and the line each word is on
+func sectionWords(section common.Section) ([]string, []int) {
+	var words []string
+	var wordLines []int
+	for i, line := range section.Lines {
+		for _, word := range common.LineWords(line.Text) {
+			words = append(words, word)
+			wordLines = append(wordLines, i)
+		}
+	}
+	return words, wordLines
+}
+
+// passages groups matched shingles into runs of consecutive lines of the section and of the source
+func passages(doc document, section common.Section, words []string, wordLines []int, matched []int, size int) []Match {
+	var matches []Match
+
+	first, last := -1, -1
+	sourceFirst, sourceLast := -1, -1
+	flush := func() {
+		if first < 0 {
+			return
+		}
+		var passage, found []string
+		for i := first; i <= last; i++ {
+			passage = append(passage, section.Lines[i].Text)
+		}
+		for i := sourceFirst; i <= sourceLast; i++ {
+			found = append(found, doc.lines[i])
+		}
+		matches = append(matches, Match{
+			Source:  doc.name,
+			Passage: strings.Join(passage, " / "),
+			Found:   strings.Join(found, " / "),
+		})
+	}
+
+	for _, i := range matched {
+		start, end := wordLines[i], wordLines[i+size-1]
+		sourceLine := doc.shingles[strings.Join(words[i:i+size], " ")]
+		sourceEnd := min(sourceLine+end-start, len(doc.lines)-1)
+
+		// Extend the current passage while the matches stay on neighbouring lines
+		if first >= 0 && start <= last+1 {
+			last = max(last, end)
+			sourceFirst = min(sourceFirst, sourceLine)
+			sourceLast = max(sourceLast, sourceEnd)
+			continue
+		}
+
+		flush()
+		first, last = start, end
+		sourceFirst, sourceLast = sourceLine, sourceEnd
+	}
+	flush()
+
+	return matches
+}
+
+// slug makes a title safe to use in a file name
+func slug(title string) string {
+	words := common.LineWords(title)
+	if len(words) == 0 {
+		return "lyrics"
+	}
+	return strings.ReplaceAll(strings.Join(words, "-"), "'", "")
+}
@@ -0,0 +1,109 @@
+package lyriccreation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+)
+
+func TestChecker_Check(t *testing.T) {
+	corpus := t.TempDir()
+	known := "Is this the real life\nIs this just fantasy\nCaught in a landslide\nNo escape from reality\n"
+	if err := os.WriteFile(filepath.Join(corpus, "known.txt"), []byte(known), 0644); err != nil {
+		t.Fatalf("Failed to write corpus: %v", err)
+	}
+
+	checker, err := NewChecker(corpus, filepath.Join(t.TempDir(), "missing"), 4, 0.3)
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+
+	lyrics := common.Lyrics{Sections: common.ParseLyrics(`VERSE 1:
+Headlines flash across the screen
+Stories of a world unseen
+
+CHORUS:
+Markets tumble, markets climb
+Caught in a landslide, no escape from reality
+Counting down the borrowed time
+`)}
+
+	report := checker.Check(lyrics)
+	if report.Source != "known.txt" || report.Section != "chorus" {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Similarity <= 0.3 || checker.Original(report) {
+		t.Errorf("Similarity = %.2f, want the chorus rejected", report.Similarity)
+	}
+
+	if len(report.Matches) != 1 {
+		t.Fatalf("Matches = %+v", report.Matches)
+	}
+	match := report.Matches[0]
+	if match.Passage != "Caught in a landslide, no escape from reality" || match.Found != "Caught in a landslide / No escape from reality" {
+		t.Errorf("match = %+v", match)
+	}
+	if !strings.Contains(report.String(), `"Caught in a landslide, no escape from reality" matches`) {
+		t.Errorf("String() = %s", report)
+	}
+
+	// The verse shares nothing with the corpus
+	verse := common.Lyrics{Sections: lyrics.Sections[:1]}
+	if report := checker.Check(verse); report.Similarity != 0 || !checker.Original(report) {
+		t.Errorf("verse report = %+v", report)
+	}
+}
+
+func TestChecker_Archive(t *testing.T) {
+	archive := t.TempDir()
+
+	// Yesterday's song is archived
+	yesterday := common.Lyrics{
+		Title: "Ticker Tape",
+		Sections: common.ParseLyrics(`VERSE 1:
+Markets rise and markets fall
+Tickers running down the wall
+
+CHORUS:
+This is the news tonight
+Holding on to the light
+`),
+	}
+	path, err := Archive(archive, yesterday, time.Date(2025, 3, 11, 18, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+	if filepath.Base(path) != "2025-03-11_180000_ticker-tape.txt" {
+		t.Errorf("Archive() wrote %s", path)
+	}
+
+	checker, err := NewChecker("", archive, 0, 0)
+	if err != nil {
+		t.Fatalf("NewChecker() error = %v", err)
+	}
+
+	// Today's song reuses the chorus
+	today := common.Lyrics{Sections: common.ParseLyrics(`VERSE 1:
+Snow is falling on the square
+Quiet voices everywhere
+
+CHORUS:
+This is the news tonight
+Holding on to the light
+`)}
+
+	report := checker.Check(today)
+	if checker.Original(report) || report.Section != "chorus" || report.Similarity != 1 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestNewChecker_MissingCorpus(t *testing.T) {
+	if _, err := NewChecker(filepath.Join(t.TempDir(), "missing"), "", 0, 0); err == nil {
+		t.Error("NewChecker() with a missing corpus should return error")
+	}
+}
@@ -0,0 +1,32 @@
+package musicgeneration
+
+import (
+	"fmt"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/registry"
+)
+
+// Backends are the music generators that can be selected by name in the configuration
+var Backends = registry.New("music generation", "suno",
+	registry.Backend[config.MusicGenerationConfig, common.MusicGenerator]{
+		Name:        "suno",
+		Kind:        registry.Provider,
+		Description: "Suno sings the lyrics in a style that follows their mood",
+		New: func(config config.MusicGenerationConfig) (common.MusicGenerator, error) {
+			if config.SunoAPIKey == "" {
+				return nil, fmt.Errorf("no Suno API key provided")
+			}
+			return New(config), nil
+		},
+	},
+	registry.Backend[config.MusicGenerationConfig, common.MusicGenerator]{
+		Name:        "synth",
+		Kind:        registry.Local,
+		Description: "an instrumental WAV synthesized offline in a key and tempo for the mood",
+		New: func(config config.MusicGenerationConfig) (common.MusicGenerator, error) {
+			return NewSynthesizer(config), nil
+		},
+	},
+)
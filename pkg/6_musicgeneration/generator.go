@@ -37,7 +37,8 @@ lyrics using Suno AI. The key responsibilities include:
 The module transforms the generated lyrics into complete musical compositions,
 creating emotionally resonant tracks that complement the visual content while
 maintaining high production quality. The generated music will be combined with
-the video content in the final assembly stage.
+the video content in the final assembly stage. The synth backend instead
+synthesizes a track offline as a WAV file whose key and tempo follow the lyrics' mood.
 */
 
 package musicgeneration
@@ -46,9 +47,13 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"math"
+	"net/url"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"github.com/iantozer/stitch-up/pkg/common"
@@ -58,65 +63,145 @@ import (
 // Generator implements the MusicGenerator interface
 type Generator struct {
 	config config.MusicGenerationConfig
+	suno   *SunoClient
 }
 
 // New creates a new music generator
 func New(config config.MusicGenerationConfig) common.MusicGenerator {
-	return &Generator{
+	g := &Generator{
 		config: config,
 	}
+
+	if config.SunoAPIKey != "" {
+		g.suno = NewSunoClient(config.SunoAPIKey)
+		if config.SunoBaseURL != "" {
+			g.suno.BaseURL = config.SunoBaseURL
+		}
+		if config.SunoModel != "" {
+			g.suno.Model = config.SunoModel
+		}
+		if config.PollInterval > 0 {
+			g.suno.PollInterval = time.Duration(config.PollInterval) * time.Second
+		}
+		if config.MaxWait > 0 {
+			g.suno.MaxWait = time.Duration(config.MaxWait) * time.Second
+		}
+	}
+
+	return g
 }
 
 // Generate generates music from lyrics using Suno AI
 func (g *Generator) Generate(ctx context.Context, lyrics common.Lyrics) (common.Music, error) {
+	if g.suno == nil {
+		return common.Music{}, fmt.Errorf("no Suno API key provided")
+	}
+	return g.generateWithSuno(ctx, lyrics)
+}
+
+// generateWithSuno submits the lyrics to Suno, waits for the track and downloads it
+func (g *Generator) generateWithSuno(ctx context.Context, lyrics common.Lyrics) (common.Music, error) {
 	log.Println("Generating music from lyrics using Suno AI")
 
-	// In a real implementation, this would:
-	// 1. Format the lyrics for Suno AI
-	// 2. Send a request to Suno AI API
-	// 3. Download and save the generated music
+	prompt := lyrics.Content
+	if len(lyrics.Sections) > 0 {
+		prompt = common.RenderLyrics(lyrics.Sections)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return common.Music{}, fmt.Errorf("no lyrics to generate music from")
+	}
+
+	taskID, err := g.suno.Submit(ctx, SunoRequest{
+		Prompt:      prompt,
+		Style:       styleTags(lyrics),
+		Title:       lyrics.Title,
+		CustomMode:  true,
+		CallBackURL: g.config.SunoCallbackURL,
+	})
+	if err != nil {
+		return common.Music{}, fmt.Errorf("error submitting lyrics to Suno: %w", err)
+	}
 
-	// Generate a unique filename
-	safeTitle := strings.ReplaceAll(strings.ToLower(lyrics.Title), " ", "_")
-	safeTitle = strings.ReplaceAll(safeTitle, ":", "")
-	filename := fmt.Sprintf("music_%s_%s.mp3", safeTitle[:20], uuid.New().String()[:8])
+	log.Printf("Submitted lyrics to Suno as task %s", taskID)
 
-	musicPath := filepath.Join(g.config.OutputDir, filename)
+	track, err := g.suno.Wait(ctx, taskID)
+	if err != nil {
+		return common.Music{}, fmt.Errorf("error generating music: %w", err)
+	}
 
-	// In a real implementation, this would save the music from Suno AI
-	// For now, we'll create an empty file as a placeholder
-	if err := createPlaceholderMusic(musicPath); err != nil {
-		return common.Music{}, fmt.Errorf("error creating placeholder music: %w", err)
+	// The videos are fitted to the music, so a track of unknown length is no use
+	if track.Duration <= 0 {
+		return common.Music{}, fmt.Errorf("suno did not report the duration of track %s", track.ID)
+	}
+
+	musicPath := filepath.Join(g.config.OutputDir, musicFilename(lyrics.Title, audioExtension(track.AudioURL)))
+	if err := g.suno.Download(ctx, track, musicPath); err != nil {
+		return common.Music{}, fmt.Errorf("error downloading music: %w", err)
 	}
 
 	music := common.Music{
 		Path:     musicPath,
-		LyricsID: lyrics.Title, // Using title as ID for simplicity
-		Length:   180,          // 3 minutes as a default length
+		LyricsID: lyricsID(lyrics),
+		Lyrics:   lyrics,
+		Length:   int(math.Ceil(track.Duration)),
 	}
 
-	log.Printf("Created music: %s", musicPath)
+	log.Printf("Created music: %s (%d seconds)", musicPath, music.Length)
 	return music, nil
 }
 
-// createPlaceholderMusic creates an empty file as a placeholder
-// In a real implementation, this would be replaced by saving the actual music from Suno AI
-func createPlaceholderMusic(path string) error {
-	// Ensure the directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+// lyricsID identifies the lyrics a track was made from, using the title for simplicity
+func lyricsID(lyrics common.Lyrics) string {
+	if lyrics.Title == "" {
+		return "untitled"
 	}
+	return lyrics.Title
+}
 
-	// Create an empty file
-	file, err := os.Create(path)
-	if err != nil {
-		return err
+// styleTags describes the genre, mood and tempo of the lyrics for Suno, e.g. "indie folk, wistful, 96 bpm"
+func styleTags(lyrics common.Lyrics) string {
+	var tags []string
+	if lyrics.Genre != "" {
+		tags = append(tags, lyrics.Genre)
+	}
+	if lyrics.Mood != "" {
+		tags = append(tags, lyrics.Mood)
 	}
-	defer file.Close()
+	if lyrics.Tempo > 0 {
+		tags = append(tags, fmt.Sprintf("%d bpm", lyrics.Tempo))
+	}
+	if len(tags) == 0 {
+		return "pop"
+	}
+	return strings.Join(tags, ", ")
+}
 
-	// In a real implementation, we would write the music data here
-	// For now, just write a placeholder message
-	_, err = file.WriteString("This is a placeholder for music that would be generated by Suno AI")
-	return err
+// musicFilename makes a unique file name from the song title
+func musicFilename(title, ext string) string {
+	safeTitle := strings.ReplaceAll(strings.ToLower(title), " ", "_")
+	safeTitle = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return r
+		}
+		return -1
+	}, safeTitle)
+	if runes := []rune(safeTitle); len(runes) > 20 {
+		safeTitle = string(runes[:20])
+	}
+	if safeTitle == "" {
+		safeTitle = "untitled"
+	}
+
+	return fmt.Sprintf("music_%s_%s%s", safeTitle, uuid.New().String()[:8], ext)
+}
+
+// audioExtension returns the file extension of an audio URL, defaulting to .mp3
+func audioExtension(audioURL string) string {
+	if u, err := url.Parse(audioURL); err == nil {
+		switch ext := strings.ToLower(path.Ext(u.Path)); ext {
+		case ".mp3", ".wav", ".m4a", ".ogg", ".flac":
+			return ext
+		}
+	}
+	return ".mp3"
 }
@@ -23,8 +23,8 @@ func TestGenerator_Generate(t *testing.T) {
 		OutputDir: tempDir,
 	}
 
-	// Create generator instance
-	generator := New(cfg)
+	// Create generator instance, synthesizing offline
+	generator := NewSynthesizer(cfg)
 
 	// Create test lyrics
 	lyrics := common.Lyrics{
@@ -72,7 +72,7 @@ Some things are meant to last`,
 	}
 
 	// Verify file has correct extension
-	if filepath.Ext(music.Path) != ".mp3" {
+	if filepath.Ext(music.Path) != ".wav" {
 		t.Errorf("Music file has incorrect extension: %s", filepath.Ext(music.Path))
 	}
 }
@@ -88,7 +88,7 @@ func TestGenerator_Generate_EmptyLyrics(t *testing.T) {
 	cfg := config.MusicGenerationConfig{
 		OutputDir: tempDir,
 	}
-	generator := New(cfg)
+	generator := NewSynthesizer(cfg)
 	ctx := context.Background()
 	lyrics := common.Lyrics{
 		Title:   "",
@@ -96,8 +96,7 @@ func TestGenerator_Generate_EmptyLyrics(t *testing.T) {
 	}
 
 	music, err := generator.Generate(ctx, lyrics)
-	// The current implementation still generates a placeholder
-	// In a real implementation, this might return an error
+	// Music is synthesized even without lyrics
 	if err != nil {
 		t.Errorf("Generate() with empty lyrics error = %v", err)
 	}
@@ -111,7 +110,7 @@ func TestGenerator_Generate_InvalidOutputDir(t *testing.T) {
 	cfg := config.MusicGenerationConfig{
 		OutputDir: "/nonexistent/directory",
 	}
-	generator := New(cfg)
+	generator := NewSynthesizer(cfg)
 	ctx := context.Background()
 	lyrics := common.Lyrics{
 		Title:   "Test Song",
@@ -131,3 +130,12 @@ func TestGenerator_Generate_InvalidOutputDir(t *testing.T) {
 	// Clean up created directory
 	os.RemoveAll("/nonexistent/directory")
 }
+
+func TestGenerator_Generate_NoKey(t *testing.T) {
+	// Without a key there is no track, rather than a synthesized one in its place
+	generator := New(config.MusicGenerationConfig{OutputDir: t.TempDir()})
+
+	if _, err := generator.Generate(context.Background(), common.Lyrics{Title: "Test Song", Content: "Test lyrics content"}); err == nil {
+		t.Error("Generate() without a Suno key should return error")
+	}
+}
@@ -0,0 +1,195 @@
+package musicgeneration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/transport"
+)
+
+const (
+	// DefaultSunoBaseURL is the Suno API endpoint
+	DefaultSunoBaseURL = "https://api.sunoapi.org"
+
+	// DefaultSunoModel is the Suno model used when none is configured
+	DefaultSunoModel = "V4"
+
+	// defaultPollInterval is how often a task is checked when no interval is configured
+	defaultPollInterval = 10 * time.Second
+
+	// defaultMaxWait is how long a task may take when no limit is configured
+	defaultMaxWait = 10 * time.Minute
+)
+
+// Suno task statuses
+const (
+	sunoPending      = "PENDING"
+	sunoTextSuccess  = "TEXT_SUCCESS"
+	sunoFirstSuccess = "FIRST_SUCCESS"
+	sunoSuccess      = "SUCCESS"
+)
+
+// SunoClient submits songs to the Suno API and downloads the finished audio
+type SunoClient struct {
+	APIKey       string
+	BaseURL      string
+	Model        string
+	PollInterval time.Duration
+	MaxWait      time.Duration
+	HTTPClient   *http.Client
+}
+
+// NewSunoClient creates a Suno client with default settings
+func NewSunoClient(apiKey string) *SunoClient {
+	return &SunoClient{
+		APIKey:       apiKey,
+		BaseURL:      DefaultSunoBaseURL,
+		Model:        DefaultSunoModel,
+		PollInterval: defaultPollInterval,
+		MaxWait:      defaultMaxWait,
+		HTTPClient:   transport.NewClient(transport.Suno, 120*time.Second),
+	}
+}
+
+// SunoRequest is a song to generate
+type SunoRequest struct {
+	Prompt       string `json:"prompt"` // the lyrics
+	Style        string `json:"style"`  // comma-separated style tags
+	Title        string `json:"title"`
+	CustomMode   bool   `json:"customMode"`
+	Instrumental bool   `json:"instrumental"`
+	Model        string `json:"model"`
+	CallBackURL  string `json:"callBackUrl,omitempty"` // where Suno posts the results; tasks are polled either way
+}
+
+// SunoTrack is a finished track
+type SunoTrack struct {
+	ID       string  `json:"id"`
+	AudioURL string  `json:"audioUrl"`
+	Title    string  `json:"title"`
+	Tags     string  `json:"tags"`
+	Duration float64 `json:"duration"` // in seconds
+}
+
+// sunoResponse is the envelope of every Suno API response
+type sunoResponse struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// sunoTask is the status of a generation task
+type sunoTask struct {
+	TaskID       string `json:"taskId"`
+	Status       string `json:"status"`
+	ErrorMessage string `json:"errorMessage"`
+	Response     struct {
+		SunoData []SunoTrack `json:"sunoData"`
+	} `json:"response"`
+}
+
+// Submit starts generating a song and returns its task ID
+func (c *SunoClient) Submit(ctx context.Context, request SunoRequest) (string, error) {
+	if request.Model == "" {
+		request.Model = c.Model
+	}
+
+	var data struct {
+		TaskID string `json:"taskId"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/generate", request, &data); err != nil {
+		return "", err
+	}
+	if data.TaskID == "" {
+		return "", fmt.Errorf("no task ID in response")
+	}
+
+	return data.TaskID, nil
+}
+
+// Wait polls a task until it has finished, it fails or MaxWait passes. A task whose first track
+// can already be streamed (FIRST_SUCCESS) is waited on too, since its duration is not yet known.
+func (c *SunoClient) Wait(ctx context.Context, taskID string) (SunoTrack, error) {
+	maxWait := c.MaxWait
+	if maxWait <= 0 {
+		maxWait = defaultMaxWait
+	}
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	path := "/api/v1/generate/record-info?taskId=" + url.QueryEscape(taskID)
+	for {
+		var task sunoTask
+		if err := c.do(ctx, http.MethodGet, path, nil, &task); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return SunoTrack{}, fmt.Errorf("timed out after %s waiting for task %s", maxWait, taskID)
+			}
+			return SunoTrack{}, err
+		}
+
+		switch task.Status {
+		case sunoSuccess:
+			for _, track := range task.Response.SunoData {
+				if track.AudioURL != "" {
+					return track, nil
+				}
+			}
+			return SunoTrack{}, fmt.Errorf("task %s finished without audio", taskID)
+		case sunoPending, sunoTextSuccess, sunoFirstSuccess, "":
+		default:
+			// Every other status is a failure, e.g. CREATE_TASK_FAILED or SENSITIVE_WORD_ERROR
+			message := task.ErrorMessage
+			if message == "" {
+				message = "no error message"
+			}
+			return SunoTrack{}, fmt.Errorf("task %s failed with status %s: %s", taskID, task.Status, message)
+		}
+
+		log.Printf("Suno task %s is %s, checking again in %s", taskID, strings.ToLower(task.Status), interval)
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return SunoTrack{}, fmt.Errorf("timed out after %s waiting for task %s", maxWait, taskID)
+			}
+			return SunoTrack{}, ctx.Err()
+		}
+	}
+}
+
+// Download saves a track's audio to path
+func (c *SunoClient) Download(ctx context.Context, track SunoTrack, path string) error {
+	return transport.Download(ctx, c.HTTPClient, track.AudioURL, path)
+}
+
+// do sends a request to the Suno API and decodes the data of its response into out
+func (c *SunoClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	header := http.Header{"Authorization": {"Bearer " + c.APIKey}}
+
+	// Errors are also reported in the envelope with a 200 status
+	var envelope sunoResponse
+	if err := transport.DoJSON(ctx, c.HTTPClient, method, strings.TrimSuffix(c.BaseURL, "/")+path, header, body, &envelope); err != nil {
+		return err
+	}
+	if envelope.Code != http.StatusOK {
+		return fmt.Errorf("suno API error %d: %s", envelope.Code, envelope.Msg)
+	}
+
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("failed to parse response data: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package musicgeneration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+// sunoServer stands in for the Suno API, finishing each task after pending polls
+func sunoServer(t *testing.T, pending int, status string) (*httptest.Server, *SunoRequest) {
+	t.Helper()
+
+	var submitted SunoRequest
+	var polls int32
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/api/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			fmt.Fprint(w, `{"code": 401, "msg": "invalid key"}`)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&submitted); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		fmt.Fprint(w, `{"code": 200, "msg": "success", "data": {"taskId": "task-1"}}`)
+	})
+
+	mux.HandleFunc("/api/v1/generate/record-info", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("taskId") != "task-1" {
+			t.Errorf("polled task %q", r.URL.Query().Get("taskId"))
+		}
+		if int(atomic.AddInt32(&polls, 1)) <= pending {
+			fmt.Fprint(w, `{"code": 200, "msg": "success", "data": {"taskId": "task-1", "status": "PENDING"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"code": 200, "msg": "success", "data": {"taskId": "task-1", "status": %q, "errorMessage": "", "response": {"sunoData": [
+			{"id": "track-1", "audioUrl": "%s/audio/track-1.mp3", "title": "Test Song", "tags": "folk", "duration": 151.4}
+		]}}}`, status, server.URL)
+	})
+
+	mux.HandleFunc("/audio/track-1.mp3", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ID3 fake audio"))
+	})
+
+	return server, &submitted
+}
+
+// sunoGenerator creates a generator pointed at the stand-in server that polls without waiting
+func sunoGenerator(t *testing.T, baseURL string) *Generator {
+	t.Helper()
+
+	generator := New(config.MusicGenerationConfig{
+		SunoAPIKey:  "test-key",
+		SunoBaseURL: baseURL,
+		OutputDir:   t.TempDir(),
+	}).(*Generator)
+	generator.suno.PollInterval = time.Millisecond
+	generator.suno.HTTPClient = http.DefaultClient
+
+	return generator
+}
+
+func TestGenerator_Generate_Suno(t *testing.T) {
+	server, submitted := sunoServer(t, 2, "SUCCESS")
+	generator := sunoGenerator(t, server.URL)
+
+	lyrics := common.Lyrics{
+		Title: "Test Song",
+		Sections: []common.Section{
+			{Type: common.SectionVerse, Lines: []common.Line{{Text: "Headlines flash across the screen"}}},
+			{Type: common.SectionChorus, Lines: []common.Line{{Text: "This is the news of today"}}},
+		},
+		Genre: "indie folk",
+		Mood:  "wistful",
+		Tempo: 96,
+	}
+
+	music, err := generator.Generate(context.Background(), lyrics)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// The lyrics and style were submitted
+	if submitted.Style != "indie folk, wistful, 96 bpm" || submitted.Title != "Test Song" || !submitted.CustomMode {
+		t.Errorf("submitted %+v", submitted)
+	}
+	if !strings.Contains(submitted.Prompt, "VERSE 1:\nHeadlines flash across the screen") || submitted.Model != DefaultSunoModel {
+		t.Errorf("submitted prompt %q with model %q", submitted.Prompt, submitted.Model)
+	}
+	if submitted.CallBackURL != "" {
+		t.Errorf("callback URL = %q, want none unless configured", submitted.CallBackURL)
+	}
+
+	// The track was downloaded with its real duration
+	if music.Length != 152 {
+		t.Errorf("Length = %d, want 152", music.Length)
+	}
+	if filepath.Dir(music.Path) != generator.config.OutputDir || filepath.Ext(music.Path) != ".mp3" {
+		t.Errorf("Path = %s", music.Path)
+	}
+	data, err := os.ReadFile(music.Path)
+	if err != nil || string(data) != "ID3 fake audio" {
+		t.Errorf("downloaded %q, %v", data, err)
+	}
+}
+
+func TestGenerator_Generate_SunoFailure(t *testing.T) {
+	server, _ := sunoServer(t, 0, "SENSITIVE_WORD_ERROR")
+	generator := sunoGenerator(t, server.URL)
+
+	_, err := generator.Generate(context.Background(), common.Lyrics{Title: "Test Song", Content: "Test lyrics"})
+	if err == nil || !strings.Contains(err.Error(), "SENSITIVE_WORD_ERROR") {
+		t.Errorf("Generate() error = %v, want the failed status", err)
+	}
+
+	// Nothing is left in the output directory
+	if files, _ := os.ReadDir(generator.config.OutputDir); len(files) != 0 {
+		t.Errorf("output directory has %d files", len(files))
+	}
+}
+
+// recordServer answers every poll of task-1 with the next of the given task records
+func recordServer(t *testing.T, records ...string) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&polls, 1))
+		fmt.Fprintf(w, `{"code": 200, "msg": "success", "data": %s}`, records[min(n, len(records))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &polls
+}
+
+func TestSunoClient_WaitForSuccess(t *testing.T) {
+	// The first track can be streamed before its length is known, so it is not ready yet
+	server, polls := recordServer(t,
+		`{"taskId": "task-1", "status": "FIRST_SUCCESS", "response": {"sunoData": [{"id": "track-1", "audioUrl": "https://cdn.example.com/stream/track-1"}]}}`,
+		`{"taskId": "task-1", "status": "SUCCESS", "response": {"sunoData": [{"id": "track-1", "audioUrl": "https://cdn.example.com/track-1.mp3", "duration": 151.4}]}}`,
+	)
+	client := sunoGenerator(t, server.URL).suno
+
+	track, err := client.Wait(context.Background(), "task-1")
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if *polls != 2 || track.Duration != 151.4 || !strings.HasSuffix(track.AudioURL, ".mp3") {
+		t.Errorf("Wait() = %+v after %d polls, want the finished track", track, *polls)
+	}
+}
+
+func TestGenerator_Generate_SunoNoDuration(t *testing.T) {
+	// The submission, then a finished task that never reports its length
+	server, _ := recordServer(t,
+		`{"taskId": "task-1"}`,
+		`{"taskId": "task-1", "status": "SUCCESS", "response": {"sunoData": [{"id": "track-1", "audioUrl": "https://cdn.example.com/track-1.mp3"}]}}`,
+	)
+	generator := sunoGenerator(t, server.URL)
+
+	if _, err := generator.Generate(context.Background(), common.Lyrics{Title: "Test Song", Content: "Test lyrics"}); err == nil || !strings.Contains(err.Error(), "duration") {
+		t.Errorf("Generate() error = %v, want the missing duration", err)
+	}
+}
+
+func TestSunoClient_WaitTimeout(t *testing.T) {
+	server, _ := sunoServer(t, 1000, "SUCCESS")
+	client := sunoGenerator(t, server.URL).suno
+	client.MaxWait = 20 * time.Millisecond
+
+	if _, err := client.Wait(context.Background(), "task-1"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Wait() error = %v, want a timeout", err)
+	}
+}
+
+func TestSunoClient_APIError(t *testing.T) {
+	server, _ := sunoServer(t, 0, "SUCCESS")
+	client := sunoGenerator(t, server.URL).suno
+	client.APIKey = "wrong-key"
+
+	if _, err := client.Submit(context.Background(), SunoRequest{Prompt: "lyrics"}); err == nil || !strings.Contains(err.Error(), "invalid key") {
+		t.Errorf("Submit() error = %v, want the API error", err)
+	}
+}
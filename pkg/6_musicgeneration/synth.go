@@ -0,0 +1,306 @@
+package musicgeneration
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+const (
+	// sampleRate is the sample rate of synthesized audio in Hz
+	sampleRate = 44100
+
+	// defaultDuration is the length of synthesized music when neither the config nor the lyrics give one
+	defaultDuration = 60
+
+	// defaultTempo is used when neither the lyrics nor their mood suggest a tempo
+	defaultTempo = 100
+
+	// fadeSeconds is how long the track takes to fade in and out
+	fadeSeconds = 2.0
+)
+
+// Scales as semitones above the root
+var (
+	majorScale = []int{0, 2, 4, 5, 7, 9, 11}
+	minorScale = []int{0, 2, 3, 5, 7, 8, 10}
+)
+
+// Chord progressions as scale degrees, one chord to a bar
+var (
+	majorProgression  = []int{0, 4, 5, 3} // I-V-vi-IV
+	upbeatProgression = []int{0, 5, 3, 4} // I-vi-IV-V
+	minorProgression  = []int{0, 5, 2, 6} // i-VI-III-VII
+)
+
+// Mood words, matched against the start of each word of the mood
+var (
+	minorMoods = []string{"sad", "wist", "melanchol", "somb", "dark", "grie", "mourn", "lonel", "bitter", "reflect", "nostalg", "anxi", "tense", "brood", "haunt"}
+	fastMoods  = []string{"upbeat", "energ", "defian", "angr", "joy", "exci", "urgen", "fierce", "trium", "celebr", "playf"}
+	slowMoods  = []string{"calm", "gentle", "peace", "somb", "mourn", "grie", "tender", "dream", "medit", "quiet"}
+)
+
+// Keys as MIDI notes of their roots, below middle C
+var (
+	majorRoots = []int{48, 55, 50, 53} // C, G, D and F
+	minorRoots = []int{57, 50, 52, 55} // A, D, E and G
+	noteNames  = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+)
+
+// Synthesizer generates music offline, writing a WAV file of chords, bass and arpeggios
+// whose key and tempo come from the lyrics' mood
+type Synthesizer struct {
+	config config.MusicGenerationConfig
+}
+
+// NewSynthesizer creates an offline music generator
+func NewSynthesizer(config config.MusicGenerationConfig) common.MusicGenerator {
+	return &Synthesizer{
+		config: config,
+	}
+}
+
+// arrangement is the musical plan for a synthesized track
+type arrangement struct {
+	root        int // MIDI note of the key's root
+	minor       bool
+	tempo       int   // beats per minute
+	progression []int // scale degrees, one chord to a bar
+}
+
+// Generate synthesizes a track for the lyrics
+func (s *Synthesizer) Generate(ctx context.Context, lyrics common.Lyrics) (common.Music, error) {
+	duration := s.duration(lyrics)
+	plan := arrange(lyrics)
+
+	log.Printf("Synthesizing %d seconds of music in %s at %d bpm", duration, plan.key(), plan.tempo)
+
+	musicPath := filepath.Join(s.config.OutputDir, musicFilename(lyrics.Title, ".wav"))
+	if err := os.MkdirAll(filepath.Dir(musicPath), 0755); err != nil {
+		return common.Music{}, fmt.Errorf("error creating music directory: %w", err)
+	}
+
+	file, err := os.Create(musicPath)
+	if err != nil {
+		return common.Music{}, fmt.Errorf("error creating music file: %w", err)
+	}
+
+	if err := writeWAV(ctx, file, duration*sampleRate, plan.sample(duration)); err != nil {
+		file.Close()
+		os.Remove(musicPath)
+		return common.Music{}, fmt.Errorf("error synthesizing music: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(musicPath)
+		return common.Music{}, fmt.Errorf("error writing music: %w", err)
+	}
+
+	music := common.Music{
+		Path:     musicPath,
+		LyricsID: lyricsID(lyrics),
+		Lyrics:   lyrics,
+		Length:   duration,
+	}
+
+	log.Printf("Created music: %s (%d seconds)", musicPath, music.Length)
+	return music, nil
+}
+
+// duration returns the track length in seconds: the configured length, else the lyrics' line timings
+func (s *Synthesizer) duration(lyrics common.Lyrics) int {
+	if s.config.Duration > 0 {
+		return s.config.Duration
+	}
+
+	total := 0.0
+	for _, line := range lyrics.Lines() {
+		total += line.Duration
+	}
+	if total > 0 {
+		return int(math.Ceil(total))
+	}
+
+	return defaultDuration
+}
+
+// arrange chooses the key, tempo and chord progression from the lyrics' mood
+func arrange(lyrics common.Lyrics) arrangement {
+	mood := strings.ToLower(lyrics.Mood)
+
+	plan := arrangement{
+		minor:       matchesMood(mood, minorMoods),
+		tempo:       lyrics.Tempo,
+		progression: majorProgression,
+	}
+
+	fast := matchesMood(mood, fastMoods)
+	if plan.tempo <= 0 {
+		switch {
+		case fast:
+			plan.tempo = 124
+		case matchesMood(mood, slowMoods):
+			plan.tempo = 76
+		default:
+			plan.tempo = defaultTempo
+		}
+	}
+
+	// The same mood always gives the same key
+	hash := fnv.New32a()
+	hash.Write([]byte(mood))
+	roots := majorRoots
+	if plan.minor {
+		roots = minorRoots
+		plan.progression = minorProgression
+	} else if fast {
+		plan.progression = upbeatProgression
+	}
+	plan.root = roots[hash.Sum32()%uint32(len(roots))]
+
+	return plan
+}
+
+// matchesMood reports whether any word of the mood starts with one of the prefixes
+func matchesMood(mood string, prefixes []string) bool {
+	for _, word := range common.LineWords(mood) {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(word, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key names the arrangement's key, e.g. "A minor"
+func (a arrangement) key() string {
+	if a.minor {
+		return noteNames[a.root%12] + " minor"
+	}
+	return noteNames[a.root%12] + " major"
+}
+
+// chord returns the MIDI notes of the triad on a scale degree
+func (a arrangement) chord(degree int) []int {
+	scale := majorScale
+	if a.minor {
+		scale = minorScale
+	}
+
+	notes := make([]int, 3)
+	for i := range notes {
+		step := degree + i*2
+		notes[i] = a.root + scale[step%len(scale)] + 12*(step/len(scale))
+	}
+	return notes
+}
+
+// sample returns a function giving the track's sample at each index, between -1 and 1
+func (a arrangement) sample(duration int) func(i int) float64 {
+	beat := 60 / float64(a.tempo)
+	bar := 4 * beat
+	eighth := beat / 2
+	length := float64(duration)
+	fade := math.Min(fadeSeconds, length/4)
+
+	// Work out every frequency up front rather than for each sample
+	type voicing struct {
+		pad  []float64 // the chord
+		bass float64   // the root an octave down
+		arp  []float64 // the chord an octave up
+	}
+	chords := make([]voicing, len(a.progression))
+	for i, degree := range a.progression {
+		for _, note := range a.chord(degree) {
+			chords[i].pad = append(chords[i].pad, frequency(note))
+			chords[i].arp = append(chords[i].arp, frequency(note+12))
+		}
+		chords[i].bass = chords[i].pad[0] / 2
+	}
+
+	return func(i int) float64 {
+		t := float64(i) / sampleRate
+		chord := chords[int(t/bar)%len(chords)]
+
+		// Pad: the chord held for the bar, swelling in and out so chord changes do not click
+		inBar := math.Mod(t, bar)
+		padLevel := math.Min(1, math.Min(inBar/0.3, (bar-inBar)/0.3))
+		pad := 0.0
+		for _, freq := range chord.pad {
+			pad += math.Sin(2 * math.Pi * freq * t)
+		}
+		pad *= 0.12 * padLevel
+
+		// Bass: the root an octave down, struck on every beat
+		inBeat := math.Mod(t, beat)
+		bass := math.Sin(2*math.Pi*chord.bass*t) + 0.3*math.Sin(4*math.Pi*chord.bass*t)
+		bass *= 0.25 * math.Exp(-3*inBeat) * math.Min(1, inBeat/0.01)
+
+		// Arpeggio: chord tones an octave up, one to each eighth note
+		step := int(t / eighth)
+		inEighth := math.Mod(t, eighth)
+		arpFreq := chord.arp[step%len(chord.arp)]
+		arp := 0.15 * math.Sin(2*math.Pi*arpFreq*t) * math.Exp(-6*inEighth) * math.Min(1, inEighth/0.005)
+
+		// Fade the whole track in and out
+		level := math.Min(1, math.Min(t/fade, (length-t)/fade))
+
+		return (pad + bass + arp) * math.Max(level, 0)
+	}
+}
+
+// frequency returns the frequency of a MIDI note in Hz
+func frequency(note int) float64 {
+	return 440 * math.Pow(2, float64(note-69)/12)
+}
+
+// writeWAV writes samples as a 16-bit mono PCM WAV file
+func writeWAV(ctx context.Context, w io.Writer, samples int, sample func(i int) float64) error {
+	const (
+		channels      = 1
+		bitsPerSample = 16
+		blockAlign    = channels * bitsPerSample / 8
+	)
+	dataSize := uint32(samples * blockAlign)
+
+	bw := bufio.NewWriter(w)
+
+	header := []interface{}{
+		[]byte("RIFF"), 36 + dataSize, []byte("WAVE"),
+		[]byte("fmt "), uint32(16), uint16(1), uint16(channels), uint32(sampleRate),
+		uint32(sampleRate * blockAlign), uint16(blockAlign), uint16(bitsPerSample),
+		[]byte("data"), dataSize,
+	}
+	for _, field := range header {
+		if err := binary.Write(bw, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, blockAlign)
+	for i := 0; i < samples; i++ {
+		// Check for cancellation once a second of audio
+		if i%sampleRate == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		value := math.Max(-1, math.Min(1, sample(i)))
+		binary.LittleEndian.PutUint16(buf, uint16(int16(value*math.MaxInt16)))
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
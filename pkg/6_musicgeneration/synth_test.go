@@ -0,0 +1,160 @@
+package musicgeneration
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+func TestSynthesizer_Generate(t *testing.T) {
+	cfg := config.MusicGenerationConfig{
+		OutputDir: t.TempDir(),
+		Duration:  3,
+	}
+
+	music, err := NewSynthesizer(cfg).Generate(context.Background(), common.Lyrics{Title: "Test Song", Mood: "wistful", Tempo: 90})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if music.Length != 3 {
+		t.Errorf("Length = %d, want 3", music.Length)
+	}
+
+	data, err := os.ReadFile(music.Path)
+	if err != nil {
+		t.Fatalf("failed to read music: %v", err)
+	}
+
+	// A 16-bit mono PCM WAV file of the configured length
+	if len(data) < 44 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" || string(data[36:40]) != "data" {
+		t.Fatalf("not a WAV file: % x", data[:min(len(data), 44)])
+	}
+	if format := binary.LittleEndian.Uint16(data[20:22]); format != 1 {
+		t.Errorf("format = %d, want PCM", format)
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:28]); rate != sampleRate {
+		t.Errorf("sample rate = %d", rate)
+	}
+	dataSize := binary.LittleEndian.Uint32(data[40:44])
+	if dataSize != 3*sampleRate*2 || int(dataSize) != len(data)-44 {
+		t.Errorf("data size = %d for a %d byte file, want %d", dataSize, len(data), 3*sampleRate*2)
+	}
+
+	// The track is not silent, and fades in from silence
+	samples := make([]int16, dataSize/2)
+	binary.Read(bytes.NewReader(data[44:]), binary.LittleEndian, samples)
+	peak := int16(0)
+	for _, sample := range samples {
+		peak = max(peak, sample)
+	}
+	if peak < 5000 {
+		t.Errorf("peak = %d, want audible music", peak)
+	}
+	if samples[0] != 0 {
+		t.Errorf("first sample = %d, want silence", samples[0])
+	}
+}
+
+func TestSynthesizer_Duration(t *testing.T) {
+	lyrics := common.Lyrics{Sections: []common.Section{
+		{Type: common.SectionVerse, Lines: []common.Line{{Text: "one", Duration: 3.5}, {Text: "two", Duration: 4}}},
+	}}
+
+	tests := []struct {
+		name     string
+		duration int
+		lyrics   common.Lyrics
+		want     int
+	}{
+		{"configured", 40, lyrics, 40},
+		{"line timings", 0, lyrics, 8},
+		{"default", 0, common.Lyrics{}, defaultDuration},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Synthesizer{config: config.MusicGenerationConfig{Duration: tt.duration}}
+			if got := s.duration(tt.lyrics); got != tt.want {
+				t.Errorf("duration() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArrange(t *testing.T) {
+	tests := []struct {
+		mood      string
+		tempo     int
+		wantMinor bool
+		wantTempo int
+	}{
+		{"wistful", 0, true, defaultTempo},
+		{"Somber and grieving", 0, true, 76},
+		{"defiant", 0, false, 124},
+		{"hopeful", 96, false, 96},
+		{"", 0, false, defaultTempo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mood, func(t *testing.T) {
+			plan := arrange(common.Lyrics{Mood: tt.mood, Tempo: tt.tempo})
+			if plan.minor != tt.wantMinor || plan.tempo != tt.wantTempo {
+				t.Errorf("arrange() = %s at %d bpm, want minor %v at %d bpm", plan.key(), plan.tempo, tt.wantMinor, tt.wantTempo)
+			}
+
+			// The same mood always gives the same key
+			if again := arrange(common.Lyrics{Mood: tt.mood}); again.root != plan.root {
+				t.Errorf("arrange() gave roots %d and %d for the same mood", plan.root, again.root)
+			}
+		})
+	}
+}
+
+func TestArrangement_Chord(t *testing.T) {
+	major := arrangement{root: 48} // C major
+	minor := arrangement{root: 57, minor: true}
+
+	tests := []struct {
+		name   string
+		plan   arrangement
+		degree int
+		want   []int
+	}{
+		{"C major I", major, 0, []int{48, 52, 55}},   // C E G
+		{"C major V", major, 4, []int{55, 59, 62}},   // G B D
+		{"C major vi", major, 5, []int{57, 60, 64}},  // A C E
+		{"A minor i", minor, 0, []int{57, 60, 64}},   // A C E
+		{"A minor VII", minor, 6, []int{67, 71, 74}}, // G B D
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.plan.chord(tt.degree)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("chord(%d) = %v, want %v", tt.degree, got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSynthesizer_Generate_Cancelled(t *testing.T) {
+	cfg := config.MusicGenerationConfig{OutputDir: t.TempDir(), Duration: 30}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewSynthesizer(cfg).Generate(ctx, common.Lyrics{Title: "Test Song"}); err == nil {
+		t.Error("Generate() with a cancelled context should return an error")
+	}
+	if files, _ := os.ReadDir(cfg.OutputDir); len(files) != 0 {
+		t.Errorf("output directory has %d files", len(files))
+	}
+}
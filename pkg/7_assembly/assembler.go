@@ -46,12 +46,12 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/iantozer/stitch-up/internal/ffmpeg"
 	"github.com/iantozer/stitch-up/pkg/common"
 	"github.com/iantozer/stitch-up/pkg/config"
 )
@@ -59,83 +59,211 @@ import (
 // Assembler implements the Assembler interface
 type Assembler struct {
 	config config.AssemblyConfig
+	mock   bool // write a placeholder listing the inputs instead of running ffmpeg
 }
 
 // New creates a new assembler
 func New(config config.AssemblyConfig) common.Assembler {
+	if config.FFMPEGPath == "" {
+		config.FFMPEGPath = ffmpeg.DefaultPath
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		config.Width, config.Height = ffmpeg.DefaultWidth, ffmpeg.DefaultHeight
+	}
+	if config.FPS <= 0 {
+		config.FPS = ffmpeg.DefaultFPS
+	}
+	if config.VideoCodec == "" {
+		config.VideoCodec = defaultVideoCodec
+	}
+	if config.AudioCodec == "" {
+		config.AudioCodec = defaultAudioCodec
+	}
+	if config.AudioBitrate == "" {
+		config.AudioBitrate = defaultAudioBitrate
+	}
+	if config.SubtitleSize <= 0 {
+		config.SubtitleSize = defaultSubtitleSize
+	}
+	if config.SubtitlePosition == "" {
+		config.SubtitlePosition = SubtitleBottom
+	}
+
 	return &Assembler{
 		config: config,
 	}
 }
 
+// NewMock creates an assembler that writes a placeholder listing the inputs, for runs without ffmpeg
+func NewMock(config config.AssemblyConfig) common.Assembler {
+	a := New(config).(*Assembler)
+	a.mock = true
+	return a
+}
+
 // Assemble combines videos and music into a final output using ffmpeg
 func (a *Assembler) Assemble(ctx context.Context, videos []common.Video, music common.Music) (string, error) {
-	log.Println("Assembling final output using ffmpeg")
-
-	// In a real implementation, this would:
-	// 1. Create a temporary file list for ffmpeg
-	// 2. Run ffmpeg to concatenate videos
-	// 3. Run ffmpeg to add music to the video
+	if len(videos) == 0 {
+		return "", fmt.Errorf("no videos to assemble")
+	}
 
 	// Generate a unique output filename
 	timestamp := time.Now().Format("20060102_150405")
 	filename := fmt.Sprintf("final_output_%s_%s.mp4", timestamp, uuid.New().String()[:8])
 	outputPath := filepath.Join(a.config.OutputDir, filename)
 
-	// In a real implementation, this would use ffmpeg to combine videos and music
-	// For now, we'll create a placeholder file and simulate the process
-	if err := createPlaceholderOutput(outputPath, videos, music, a); err != nil {
-		return "", fmt.Errorf("error creating placeholder output: %w", err)
+	if a.mock {
+		if err := createPlaceholderOutput(outputPath, videos, music); err != nil {
+			return "", fmt.Errorf("error creating placeholder output: %w", err)
+		}
+		if cues := subtitleCues(music.Lyrics, plannedLength(videos, music)); a.config.Subtitles && len(cues) > 0 {
+			if err := writeSubtitles(outputPath, cues); err != nil {
+				return "", err
+			}
+		}
+		log.Printf("Created placeholder output: %s", outputPath)
+		return outputPath, nil
+	}
+
+	ffmpegPath, err := ffmpeg.Find(a.config.FFMPEGPath)
+	if err != nil {
+		return "", err
+	}
+
+	log.Println("Assembling final output using ffmpeg")
+	if err := a.assemble(ctx, ffmpegPath, videos, music, outputPath); err != nil {
+		return "", err
 	}
 
 	log.Printf("Created final output: %s", outputPath)
 	return outputPath, nil
 }
 
-// createPlaceholderOutput creates a placeholder for the final output
-// In a real implementation, this would be replaced by actual ffmpeg commands
-func createPlaceholderOutput(outputPath string, videos []common.Video, music common.Music, a *Assembler) error {
-	// Ensure the directory exists
-	dir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+// assemble normalizes and joins the clips with their transitions, lays the music under them and moves the result to outputPath
+func (a *Assembler) assemble(ctx context.Context, ffmpegPath string, videos []common.Video, music common.Music, outputPath string) error {
+	paths := make([]string, len(videos))
+	for i, video := range videos {
+		if _, err := os.Stat(video.Path); err != nil {
+			return fmt.Errorf("video %d is not readable: %w", i+1, err)
+		}
+		paths[i] = video.Path
+	}
+	if music.Path != "" {
+		if _, err := os.Stat(music.Path); err != nil {
+			return fmt.Errorf("music is not readable: %w", err)
+		}
+	}
+	if a.config.NarrationPath != "" {
+		if _, err := os.Stat(a.config.NarrationPath); err != nil {
+			return fmt.Errorf("narration is not readable: %w", err)
+		}
 	}
 
-	// Create an empty file
-	file, err := os.Create(outputPath)
+	cuts, err := a.timeline(videos)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	if _, ok := subtitleAlignments[a.config.SubtitlePosition]; a.config.BurnSubtitles && !ok {
+		return fmt.Errorf("unknown subtitle position %q", a.config.SubtitlePosition)
+	}
 
-	// In a real implementation, we would run ffmpeg here
-	// For now, just write a placeholder message
-	sb := strings.Builder{}
-	sb.WriteString("This is a placeholder for the final video that would be created by ffmpeg\n\n")
-	sb.WriteString(fmt.Sprintf("Music: %s\n", music.Path))
-	sb.WriteString("Videos:\n")
-	for i, video := range videos {
-		sb.WriteString(fmt.Sprintf("  %d. %s\n", i+1, video.Path))
+	if err := os.MkdirAll(a.config.OutputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	// Intermediate files live next to the output so the final rename never crosses file systems
+	workDir, err := os.MkdirTemp(a.config.OutputDir, ".assembly_")
+	if err != nil {
+		return fmt.Errorf("error creating work directory: %w", err)
 	}
+	defer os.RemoveAll(workDir)
 
-	// Check if ffmpeg is available
-	ffmpegPath := a.config.FFMPEGPath
-	if ffmpegPath == "" {
-		ffmpegPath = "ffmpeg"
+	clips, err := a.normalizeVideos(ctx, ffmpegPath, workDir, paths, clipLengths(videos, cuts))
+	if err != nil {
+		return err
 	}
 
-	_, err = exec.LookPath(ffmpegPath)
+	// Hard cuts are joined without re-encoding; transitions need the clips blended
+	joined := filepath.Join(workDir, "joined.mp4")
+	if hasTransitions(cuts) {
+		if err := a.joinWithTransitions(ctx, ffmpegPath, clips, cuts, joined); err != nil {
+			return err
+		}
+	} else {
+		listPath := filepath.Join(workDir, "concat.txt")
+		if err := createConcatFile(listPath, clips); err != nil {
+			return fmt.Errorf("error creating concat file: %w", err)
+		}
+		if err := concatenateVideos(ctx, ffmpegPath, listPath, joined); err != nil {
+			return err
+		}
+	}
+
+	duration, err := probeDuration(ctx, ffmpegPath, joined)
 	if err != nil {
-		sb.WriteString("\nWarning: ffmpeg not found in PATH. In a real implementation, this would be required.\n")
+		return err
+	}
+
+	cues := subtitleCues(music.Lyrics, duration)
+	if a.config.BurnSubtitles && len(cues) > 0 {
+		subtitlePath := filepath.Join(workDir, "lyrics.srt")
+		if err := os.WriteFile(subtitlePath, []byte(formatSRT(cues)), 0644); err != nil {
+			return fmt.Errorf("error writing subtitles: %w", err)
+		}
+		subtitled := filepath.Join(workDir, "subtitled.mp4")
+		if err := a.burnSubtitles(ctx, ffmpegPath, joined, subtitlePath, subtitled); err != nil {
+			return err
+		}
+		joined = subtitled
+	}
+
+	final := joined
+	if music.Path != "" {
+		final = filepath.Join(workDir, "final.mp4")
+		if err := a.addMusicToVideo(ctx, ffmpegPath, joined, music.Path, final, duration); err != nil {
+			return err
+		}
 	} else {
-		sb.WriteString("\nffmpeg found. In a real implementation, it would be used to combine videos and music.\n")
+		log.Println("Warning: no music to add, the final output has only the clips' audio")
+	}
+
+	if err := os.Rename(final, outputPath); err != nil {
+		return err
+	}
+
+	if a.config.Subtitles && len(cues) > 0 {
+		return writeSubtitles(outputPath, cues)
 	}
+	return nil
+}
 
-	_, err = file.WriteString(sb.String())
-	return err
+// plannedLength returns how long the output would be: the videos' lengths, or the music's
+func plannedLength(videos []common.Video, music common.Music) float64 {
+	total := 0
+	for _, video := range videos {
+		total += video.Length
+	}
+	if total == 0 {
+		total = music.Length
+	}
+	return float64(total)
 }
 
-// In a real implementation, we would have additional helper functions:
-// - createConcatFile: to create a file list for ffmpeg concatenation
-// - concatenateVideos: to run ffmpeg to concatenate videos
-// - addMusicToVideo: to run ffmpeg to add music to the video
+// createPlaceholderOutput writes a text file listing the inputs, for the mock assembler
+func createPlaceholderOutput(outputPath string, videos []common.Video, music common.Music) error {
+	// Ensure the directory exists
+	dir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("This is a placeholder for the final video, made without ffmpeg\n\n")
+	sb.WriteString(fmt.Sprintf("Music: %s\n", music.Path))
+	sb.WriteString("Videos:\n")
+	for i, video := range videos {
+		sb.WriteString(fmt.Sprintf("  %d. %s\n", i+1, video.Path))
+	}
+
+	return os.WriteFile(outputPath, []byte(sb.String()), 0644)
+}
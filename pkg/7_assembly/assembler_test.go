@@ -31,8 +31,11 @@ func TestAssembler_Assemble(t *testing.T) {
 		FFMPEGPath: ffmpegPath,
 	}
 
-	// Create assembler instance
+	// Create assembler instance, writing a placeholder when ffmpeg is not available
 	assembler := New(cfg)
+	if ffmpegPath == "" {
+		assembler = NewMock(cfg)
+	}
 
 	// Create temporary video and music files for testing
 	videoDir, err := os.MkdirTemp("", "videotest")
@@ -55,8 +58,12 @@ func TestAssembler_Assemble(t *testing.T) {
 		},
 	}
 
-	// Create placeholder video files
+	// Create test video files, real clips when ffmpeg can make them
 	for _, video := range videos {
+		if ffmpegPath != "" {
+			makeTestClip(t, ffmpegPath, video.Path, "320x240", 1)
+			continue
+		}
 		if err := os.WriteFile(video.Path, []byte("test video data"), 0644); err != nil {
 			t.Fatalf("Failed to create test video: %v", err)
 		}
@@ -64,7 +71,9 @@ func TestAssembler_Assemble(t *testing.T) {
 
 	// Create test music file
 	musicPath := filepath.Join(videoDir, "music.mp3")
-	if err := os.WriteFile(musicPath, []byte("test music data"), 0644); err != nil {
+	if ffmpegPath != "" {
+		makeTestTone(t, ffmpegPath, musicPath, 1)
+	} else if err := os.WriteFile(musicPath, []byte("test music data"), 0644); err != nil {
 		t.Fatalf("Failed to create test music: %v", err)
 	}
 
@@ -159,14 +168,8 @@ func TestAssembler_Assemble_InvalidMusic(t *testing.T) {
 	}
 
 	outputPath, err := assembler.Assemble(ctx, videos, music)
-	// In current implementation, this still creates a placeholder
-	// In a real implementation, this would likely fail
-	if err != nil {
-		t.Errorf("Assemble() with invalid music error = %v", err)
-	}
-	if outputPath == "" {
-		t.Error("Assemble() with invalid music returned empty output path")
-	} else {
+	if err == nil {
+		t.Error("Assemble() with invalid music should return error")
 		os.Remove(outputPath)
 	}
 }
@@ -199,14 +202,9 @@ func TestAssembler_Assemble_FFMPEGNotFound(t *testing.T) {
 	}
 
 	outputPath, err := assembler.Assemble(ctx, videos, music)
-	// In current implementation, this still creates a placeholder
-	// In a real implementation, this would likely fail if ffmpeg is required
-	if err != nil {
-		t.Errorf("Assemble() with invalid ffmpeg path error = %v", err)
-	}
-	if outputPath == "" {
-		t.Error("Assemble() with invalid ffmpeg path returned empty output path")
-	} else {
+	// Without ffmpeg there is no output, rather than a placeholder in its place
+	if err == nil {
+		t.Error("Assemble() with invalid ffmpeg path should return error")
 		os.Remove(outputPath)
 	}
 }
@@ -0,0 +1,210 @@
+package assembly
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/iantozer/stitch-up/internal/ffmpeg"
+)
+
+// Loudness normalization follows EBU R128; these are the limits most platforms ask for
+const (
+	truePeak      = -1.5 // dBTP
+	loudnessRange = 11   // LU
+)
+
+// Ducking lowers the music by up to ratio whenever the voices rise above threshold
+const (
+	duckThreshold = 0.03
+	duckRatio     = 8
+	duckAttack    = 20  // ms
+	duckRelease   = 400 // ms
+)
+
+var (
+	// durationPattern matches the duration ffmpeg reports for an input
+	durationPattern = regexp.MustCompile(`Duration: (\d+):(\d+):(\d+(?:\.\d+)?)`)
+
+	// audioStreamPattern matches an audio stream in ffmpeg's description of an input
+	audioStreamPattern = regexp.MustCompile(`Stream #\d+:\d+.*: Audio:`)
+)
+
+// loudness is what the first loudnorm pass measured
+type loudness struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// mixFilter builds the filter graph that trims and fades the music, ducks it under the voices
+// (clip audio and narration) and mixes them, labelling the result [aout]. Input 0 is the video,
+// 1 the music and 2 the narration. normalize is appended to the mix, e.g. a loudnorm filter.
+func (a *Assembler) mixFilter(duration float64, clipAudio, narration bool, normalize string) string {
+	var chain []string
+
+	// Music: cut to the video, then faded in and out
+	music := fmt.Sprintf("[1:a]atrim=0:%s,asetpts=PTS-STARTPTS", seconds(duration))
+	fadeIn := min(a.config.MusicFadeIn, duration/2)
+	fadeOut := min(a.config.MusicFadeOut, duration/2)
+	if fadeIn > 0 {
+		music += fmt.Sprintf(",afade=t=in:st=0:d=%s", seconds(fadeIn))
+	}
+	if fadeOut > 0 {
+		music += fmt.Sprintf(",afade=t=out:st=%s:d=%s", seconds(duration-fadeOut), seconds(fadeOut))
+	}
+	chain = append(chain, music+"[music]")
+
+	// Voices: the clips' own audio and the narration
+	var voices []string
+	if clipAudio {
+		voices = append(voices, "[0:a]")
+	}
+	if narration {
+		voices = append(voices, "[2:a]")
+	}
+
+	mix := "[music]"
+	if len(voices) > 0 {
+		voice := voices[0]
+		if len(voices) > 1 {
+			chain = append(chain, strings.Join(voices, "")+"amix=inputs=2:duration=longest:normalize=0[voice]")
+			voice = "[voice]"
+		}
+
+		if a.config.Duck {
+			chain = append(chain,
+				voice+"asplit=2[sidechain][voices]",
+				fmt.Sprintf("[music][sidechain]sidechaincompress=threshold=%s:ratio=%d:attack=%d:release=%d[ducked]",
+					seconds(duckThreshold), duckRatio, duckAttack, duckRelease),
+			)
+			mix, voice = "[ducked]", "[voices]"
+		}
+
+		// The music comes first so the mix lasts exactly as long as the video
+		chain = append(chain, mix+voice+"amix=inputs=2:duration=first:normalize=0[mix]")
+		mix = "[mix]"
+	}
+
+	if normalize == "" {
+		normalize = "anull"
+	}
+	chain = append(chain, mix+normalize+"[aout]")
+
+	return strings.Join(chain, ";")
+}
+
+// mixArgs builds the ffmpeg arguments for a mixing pass. With an empty output the pass only
+// measures the mix and the video is not written.
+func (a *Assembler) mixArgs(video, music, filter, output string) []string {
+	args := []string{
+		"-y", "-i", video,
+		"-stream_loop", "-1", "-i", music,
+	}
+	if a.config.NarrationPath != "" {
+		args = append(args, "-i", a.config.NarrationPath)
+	}
+	args = append(args, "-filter_complex", filter)
+
+	if output == "" {
+		return append(args, "-map", "[aout]", "-f", "null", "-")
+	}
+
+	return append(args,
+		"-map", "0:v:0", "-map", "[aout]",
+		"-c:v", "copy",
+		"-c:a", a.config.AudioCodec, "-b:a", a.config.AudioBitrate,
+		"-movflags", "+faststart",
+		output,
+	)
+}
+
+// loudnormMeasure is the first loudnorm pass, which prints what it measured
+func (a *Assembler) loudnormMeasure() string {
+	return fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%d:print_format=json", seconds(a.config.TargetLoudness), seconds(truePeak), loudnessRange)
+}
+
+// loudnormApply is the second loudnorm pass, which normalizes linearly using the measurements
+func (a *Assembler) loudnormApply(measured loudness) string {
+	return fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%d:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true:print_format=summary,aresample=48000",
+		seconds(a.config.TargetLoudness), seconds(truePeak), loudnessRange,
+		measured.InputI, measured.InputTP, measured.InputLRA, measured.InputThresh, measured.TargetOffset)
+}
+
+// parseLoudness reads the measurements loudnorm prints as JSON at the end of its output
+func parseLoudness(output string) (loudness, error) {
+	start := strings.LastIndex(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return loudness{}, fmt.Errorf("no loudness measurements in ffmpeg output")
+	}
+
+	var measured loudness
+	if err := json.Unmarshal([]byte(output[start:end+1]), &measured); err != nil {
+		return loudness{}, fmt.Errorf("failed to parse loudness measurements: %w", err)
+	}
+	if measured.InputI == "" {
+		return loudness{}, fmt.Errorf("no loudness measurements in ffmpeg output")
+	}
+
+	return measured, nil
+}
+
+// mixAudio lays the music, clip audio and narration under a video of the given length. When a
+// target loudness is set, a first pass measures the mix and a second normalizes it to the target.
+func (a *Assembler) mixAudio(ctx context.Context, ffmpegPath, video, music, output string, duration float64) error {
+	narration := a.config.NarrationPath != ""
+
+	normalize := ""
+	if a.config.TargetLoudness != 0 {
+		filter := a.mixFilter(duration, a.config.ClipAudio, narration, a.loudnormMeasure())
+		out, err := ffmpeg.Output(ctx, ffmpegPath, a.mixArgs(video, music, filter, "")...)
+		if err != nil {
+			return fmt.Errorf("error measuring loudness: %w", err)
+		}
+
+		measured, err := parseLoudness(out)
+		if err != nil {
+			return err
+		}
+
+		// Silence has no loudness to normalize
+		if strings.Contains(measured.InputI, "inf") {
+			log.Println("Warning: the mix is silent, skipping loudness normalization")
+		} else {
+			log.Printf("Normalizing loudness from %s to %s LUFS", measured.InputI, seconds(a.config.TargetLoudness))
+			normalize = a.loudnormApply(measured)
+		}
+	}
+
+	filter := a.mixFilter(duration, a.config.ClipAudio, narration, normalize)
+	return ffmpeg.Run(ctx, ffmpegPath, a.mixArgs(video, music, filter, output)...)
+}
+
+// probeDuration returns the length of a media file in seconds
+func probeDuration(ctx context.Context, ffmpegPath, path string) (float64, error) {
+	// ffmpeg describes its inputs and then fails for want of an output, which is all we need
+	out, _ := ffmpeg.Output(ctx, ffmpegPath, "-i", path)
+
+	match := durationPattern.FindStringSubmatch(out)
+	if match == nil {
+		return 0, fmt.Errorf("could not read the duration of %s", path)
+	}
+
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	secs, _ := strconv.ParseFloat(match[3], 64)
+	return float64(hours*3600+minutes*60) + secs, nil
+}
+
+// probeAudio reports whether a media file has an audio stream
+func probeAudio(ctx context.Context, ffmpegPath, path string) bool {
+	out, _ := ffmpeg.Output(ctx, ffmpegPath, "-i", path)
+	return audioStreamPattern.MatchString(out)
+}
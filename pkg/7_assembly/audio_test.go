@@ -0,0 +1,114 @@
+package assembly
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+func TestMixFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    config.AssemblyConfig
+		duration  float64
+		clipAudio bool
+		narration bool
+		normalize string
+		want      string
+	}{
+		{
+			name:     "music only",
+			config:   config.AssemblyConfig{MusicFadeIn: 2, MusicFadeOut: 3},
+			duration: 20,
+			want: "[1:a]atrim=0:20,asetpts=PTS-STARTPTS,afade=t=in:st=0:d=2,afade=t=out:st=17:d=3[music];" +
+				"[music]anull[aout]",
+		},
+		{
+			name:     "fades capped for short videos",
+			config:   config.AssemblyConfig{MusicFadeIn: 2, MusicFadeOut: 3},
+			duration: 4,
+			want: "[1:a]atrim=0:4,asetpts=PTS-STARTPTS,afade=t=in:st=0:d=2,afade=t=out:st=2:d=2[music];" +
+				"[music]anull[aout]",
+		},
+		{
+			name:      "ducked under clip audio",
+			config:    config.AssemblyConfig{Duck: true},
+			duration:  10,
+			clipAudio: true,
+			normalize: "loudnorm",
+			want: "[1:a]atrim=0:10,asetpts=PTS-STARTPTS[music];" +
+				"[0:a]asplit=2[sidechain][voices];" +
+				"[music][sidechain]sidechaincompress=threshold=0.03:ratio=8:attack=20:release=400[ducked];" +
+				"[ducked][voices]amix=inputs=2:duration=first:normalize=0[mix];" +
+				"[mix]loudnorm[aout]",
+		},
+		{
+			name:      "clip audio and narration without ducking",
+			duration:  10,
+			clipAudio: true,
+			narration: true,
+			want: "[1:a]atrim=0:10,asetpts=PTS-STARTPTS[music];" +
+				"[0:a][2:a]amix=inputs=2:duration=longest:normalize=0[voice];" +
+				"[music][voice]amix=inputs=2:duration=first:normalize=0[mix];" +
+				"[mix]anull[aout]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(tt.config).(*Assembler)
+			if got := a.mixFilter(tt.duration, tt.clipAudio, tt.narration, tt.normalize); got != tt.want {
+				t.Errorf("mixFilter() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMixArgs(t *testing.T) {
+	a := New(config.AssemblyConfig{NarrationPath: "narration.wav"}).(*Assembler)
+
+	// The measuring pass writes nothing
+	measure := strings.Join(a.mixArgs("video.mp4", "music.wav", "graph", ""), " ")
+	if !strings.Contains(measure, "-i video.mp4 -stream_loop -1 -i music.wav -i narration.wav") || !strings.HasSuffix(measure, "-map [aout] -f null -") {
+		t.Errorf("mixArgs() = %s", measure)
+	}
+
+	args := strings.Join(a.mixArgs("video.mp4", "music.wav", "graph", "out.mp4"), " ")
+	for _, want := range []string{"-filter_complex graph", "-map 0:v:0 -map [aout]", "-c:v copy", "-c:a aac -b:a 192k"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("mixArgs() = %s, missing %q", args, want)
+		}
+	}
+}
+
+func TestParseLoudness(t *testing.T) {
+	output := `[Parsed_loudnorm_3 @ 0x5581] 
+{
+	"input_i" : "-23.54",
+	"input_tp" : "-7.12",
+	"input_lra" : "4.30",
+	"input_thresh" : "-33.80",
+	"output_i" : "-14.02",
+	"output_tp" : "-1.50",
+	"output_lra" : "3.90",
+	"output_thresh" : "-24.20",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.02"
+}
+`
+	measured, err := parseLoudness(output)
+	if err != nil {
+		t.Fatalf("parseLoudness() error = %v", err)
+	}
+
+	a := New(config.AssemblyConfig{TargetLoudness: -14}).(*Assembler)
+	want := "loudnorm=I=-14:TP=-1.5:LRA=11:measured_I=-23.54:measured_TP=-7.12:measured_LRA=4.30:measured_thresh=-33.80:offset=0.02:linear=true"
+	if got := a.loudnormApply(measured); !strings.HasPrefix(got, want) {
+		t.Errorf("loudnormApply() = %s, want prefix %s", got, want)
+	}
+
+	if _, err := parseLoudness("Output #0, null, to 'pipe:':"); err == nil {
+		t.Error("parseLoudness() without measurements should return error")
+	}
+}
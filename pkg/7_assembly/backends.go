@@ -0,0 +1,31 @@
+package assembly
+
+import (
+	"github.com/iantozer/stitch-up/internal/ffmpeg"
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+	"github.com/iantozer/stitch-up/pkg/registry"
+)
+
+// Backends are the assemblers that can be selected by name in the configuration
+var Backends = registry.New("assembly", "ffmpeg",
+	registry.Backend[config.AssemblyConfig, common.Assembler]{
+		Name:        "ffmpeg",
+		Kind:        registry.Local,
+		Description: "joins the clips with transitions and mixes in the music with ffmpeg",
+		New: func(config config.AssemblyConfig) (common.Assembler, error) {
+			if _, err := ffmpeg.Find(config.FFMPEGPath); err != nil {
+				return nil, err
+			}
+			return New(config), nil
+		},
+	},
+	registry.Backend[config.AssemblyConfig, common.Assembler]{
+		Name:        "mock",
+		Kind:        registry.Mock,
+		Description: "a text file listing the inputs, with the subtitles beside it",
+		New: func(config config.AssemblyConfig) (common.Assembler, error) {
+			return NewMock(config), nil
+		},
+	},
+)
@@ -0,0 +1,118 @@
+package assembly
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/iantozer/stitch-up/internal/ffmpeg"
+)
+
+// Defaults used when the assembly config leaves an encoding setting empty
+const (
+	defaultVideoCodec   = "libx264"
+	defaultAudioCodec   = "aac"
+	defaultAudioBitrate = "192k"
+	defaultSubtitleSize = 24
+)
+
+// normalizeArgs builds the ffmpeg arguments that re-encode a clip to the common size, frame
+// rate and codec, letterboxing clips of a different aspect ratio. A positive length trims the
+// clip or holds its last frame to make it exactly that long. Clip audio is dropped unless the
+// config keeps it, in which case clips without audio are given silence so every clip matches.
+func (a *Assembler) normalizeArgs(input, output string, length float64, hasAudio bool) []string {
+	filter := fmt.Sprintf(
+		"scale=%[1]d:%[2]d:force_original_aspect_ratio=decrease,pad=%[1]d:%[2]d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%[3]d,format=yuv420p",
+		a.config.Width, a.config.Height, a.config.FPS,
+	)
+	if length > 0 {
+		filter += ",tpad=stop_mode=clone:stop_duration=" + seconds(length)
+	}
+
+	args := []string{"-y", "-i", input}
+	switch {
+	case !a.config.ClipAudio:
+		args = append(args, "-vf", filter, "-an")
+	case hasAudio:
+		args = append(args, "-vf", filter, "-map", "0:v:0", "-map", "0:a:0", "-af", "apad,aresample=48000", "-ac", "2")
+	default:
+		args = append(args, "-f", "lavfi", "-i", "anullsrc=r=48000:cl=stereo",
+			"-vf", filter, "-map", "0:v:0", "-map", "1:a:0")
+	}
+	if a.config.ClipAudio {
+		// Padded audio and generated silence never end, so stop with the video
+		args = append(args, "-c:a", a.config.AudioCodec, "-b:a", a.config.AudioBitrate, "-shortest")
+	}
+
+	args = append(args, "-c:v", a.config.VideoCodec, "-r", strconv.Itoa(a.config.FPS))
+	if length > 0 {
+		args = append(args, "-t", seconds(length))
+	}
+
+	return append(args, output)
+}
+
+// concatArgs builds the ffmpeg arguments that join the clips in a concat list without re-encoding
+func concatArgs(listPath, output string) []string {
+	return []string{
+		"-y", "-f", "concat", "-safe", "0", "-i", listPath,
+		"-c", "copy",
+		output,
+	}
+}
+
+// normalizeVideos re-encodes every clip into workDir to the given lengths, so they can be joined
+func (a *Assembler) normalizeVideos(ctx context.Context, ffmpegPath, workDir string, paths []string, lengths []float64) ([]string, error) {
+	normalized := make([]string, len(paths))
+	for i, path := range paths {
+		normalized[i] = filepath.Join(workDir, fmt.Sprintf("clip_%03d.mp4", i))
+		hasAudio := a.config.ClipAudio && probeAudio(ctx, ffmpegPath, path)
+		if err := ffmpeg.Run(ctx, ffmpegPath, a.normalizeArgs(path, normalized[i], lengths[i], hasAudio)...); err != nil {
+			return nil, fmt.Errorf("error normalizing %s: %w", path, err)
+		}
+	}
+	return normalized, nil
+}
+
+// createConcatFile writes the list of clips for ffmpeg's concat demuxer
+func createConcatFile(path string, videos []string) error {
+	var sb strings.Builder
+	for _, video := range videos {
+		abs, err := filepath.Abs(video)
+		if err != nil {
+			return err
+		}
+		// Quote the path, escaping single quotes the way the concat demuxer expects
+		sb.WriteString("file '" + strings.ReplaceAll(abs, "'", `'\''`) + "'\n")
+	}
+
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
+// concatenateVideos joins the clips in a concat list into a single video
+func concatenateVideos(ctx context.Context, ffmpegPath, listPath, output string) error {
+	if err := ffmpeg.Run(ctx, ffmpegPath, concatArgs(listPath, output)...); err != nil {
+		return fmt.Errorf("error concatenating videos: %w", err)
+	}
+	return nil
+}
+
+// joinWithTransitions joins the clips with the transitions between them
+func (a *Assembler) joinWithTransitions(ctx context.Context, ffmpegPath string, clips []string, cuts []cut, output string) error {
+	if err := ffmpeg.Run(ctx, ffmpegPath, a.transitionArgs(clips, cuts, output)...); err != nil {
+		return fmt.Errorf("error joining videos with transitions: %w", err)
+	}
+	return nil
+}
+
+// addMusicToVideo lays the music under the video, trimmed to the video's length and mixed
+// with any clip audio and narration
+func (a *Assembler) addMusicToVideo(ctx context.Context, ffmpegPath, video, music, output string, duration float64) error {
+	if err := a.mixAudio(ctx, ffmpegPath, video, music, output, duration); err != nil {
+		return fmt.Errorf("error adding music: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package assembly
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/iantozer/stitch-up/internal/ffmpeg"
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+// testAssembler creates an assembler with the default settings
+func testAssembler(outputDir string) *Assembler {
+	return New(config.AssemblyConfig{OutputDir: outputDir}).(*Assembler)
+}
+
+// makeTestClip renders a test pattern clip with ffmpeg
+func makeTestClip(t *testing.T, ffmpegPath, path, size string, seconds float64) {
+	t.Helper()
+	duration := strconv.FormatFloat(seconds, 'f', -1, 64)
+	if err := ffmpeg.Run(context.Background(), ffmpegPath, "-y", "-f", "lavfi", "-i", "testsrc=size="+size+":rate=25:duration="+duration, "-pix_fmt", "yuv420p", path); err != nil {
+		t.Fatalf("failed to create test clip: %v", err)
+	}
+}
+
+// makeTestTone renders a sine tone with ffmpeg
+func makeTestTone(t *testing.T, ffmpegPath, path string, seconds float64) {
+	t.Helper()
+	duration := strconv.FormatFloat(seconds, 'f', -1, 64)
+	if err := ffmpeg.Run(context.Background(), ffmpegPath, "-y", "-f", "lavfi", "-i", "sine=frequency=440:duration="+duration, path); err != nil {
+		t.Fatalf("failed to create test tone: %v", err)
+	}
+}
+
+func TestNormalizeArgs(t *testing.T) {
+	a := testAssembler(t.TempDir())
+	args := strings.Join(a.normalizeArgs("in.mp4", "out.mp4", 10.5, false), " ")
+
+	for _, want := range []string{
+		"-i in.mp4",
+		"scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=30,format=yuv420p",
+		"-an",
+		",tpad=stop_mode=clone:stop_duration=10.5",
+		"-c:v libx264",
+		"-t 10.5",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("normalizeArgs() = %s, missing %q", args, want)
+		}
+	}
+	if !strings.HasSuffix(args, " out.mp4") {
+		t.Errorf("normalizeArgs() = %s, want the output last", args)
+	}
+}
+
+func TestNormalizeArgs_ClipAudio(t *testing.T) {
+	a := New(config.AssemblyConfig{ClipAudio: true}).(*Assembler)
+
+	// Clips keep their audio, padded to the clip's length
+	args := strings.Join(a.normalizeArgs("in.mp4", "out.mp4", 5, true), " ")
+	for _, want := range []string{"-map 0:a:0", "-af apad,aresample=48000", "-c:a aac", "-shortest"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("normalizeArgs() = %s, missing %q", args, want)
+		}
+	}
+
+	// Clips without audio are given silence
+	args = strings.Join(a.normalizeArgs("in.mp4", "out.mp4", 5, false), " ")
+	for _, want := range []string{"-i in.mp4 -f lavfi -i anullsrc=r=48000:cl=stereo", "-map 1:a:0", "-shortest"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("normalizeArgs() = %s, missing %q", args, want)
+		}
+	}
+	if strings.Contains(args, "-an") {
+		t.Errorf("normalizeArgs() = %s, want audio kept", args)
+	}
+}
+
+func TestCreateConcatFile(t *testing.T) {
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "concat.txt")
+
+	if err := createConcatFile(listPath, []string{filepath.Join(dir, "clip_000.mp4"), filepath.Join(dir, "it's.mp4")}); err != nil {
+		t.Fatalf("createConcatFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(listPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "file '" + filepath.Join(dir, "clip_000.mp4") + "'\n" +
+		"file '" + filepath.Join(dir, `it'\''s.mp4`) + "'\n"
+	if string(data) != want {
+		t.Errorf("concat file = %q, want %q", data, want)
+	}
+}
+
+func TestAssembler_Assemble_FFmpeg(t *testing.T) {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg not found in PATH")
+	}
+
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	// Clips of different sizes joined with a crossfade, and music shorter than the video so it
+	// has to loop, faded and normalized
+	videos := []common.Video{
+		{Path: filepath.Join(inputDir, "video1.mp4"), Length: 1},
+		{Path: filepath.Join(inputDir, "video2.mp4"), Length: 1},
+	}
+	makeTestClip(t, ffmpegPath, videos[0].Path, "640x480", 1)
+	makeTestClip(t, ffmpegPath, videos[1].Path, "320x240", 1)
+
+	music := common.Music{Path: filepath.Join(inputDir, "music.wav"), Length: 1}
+	makeTestTone(t, ffmpegPath, music.Path, 0.5)
+
+	a := New(config.AssemblyConfig{
+		OutputDir:          outputDir,
+		FFMPEGPath:         ffmpegPath,
+		Width:              320,
+		Height:             180,
+		Transition:         "crossfade",
+		TransitionDuration: 0.25,
+		MusicFadeIn:        0.2,
+		MusicFadeOut:       0.3,
+		TargetLoudness:     -16,
+	})
+	outputPath, err := a.Assemble(context.Background(), videos, music)
+	if err != nil {
+		t.Fatalf("Assemble() error = %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		t.Fatalf("output is not an MP4 file")
+	}
+
+	// Only the output is left in the output directory
+	if files, _ := os.ReadDir(outputDir); len(files) != 1 {
+		t.Errorf("output directory has %d files, want 1", len(files))
+	}
+
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return
+	}
+	out, err := exec.Command(ffprobePath, "-v", "error", "-show_entries", "stream=codec_type,width,height:format=duration", "-of", "default=noprint_wrappers=1", outputPath).Output()
+	if err != nil {
+		t.Fatalf("ffprobe failed: %v", err)
+	}
+	probe := string(out)
+	for _, want := range []string{"codec_type=video", "codec_type=audio", "width=320", "height=180"} {
+		if !strings.Contains(probe, want) {
+			t.Errorf("ffprobe output %q missing %q", probe, want)
+		}
+	}
+	for _, line := range strings.Split(probe, "\n") {
+		if value, ok := strings.CutPrefix(line, "duration="); ok {
+			if duration, _ := strconv.ParseFloat(value, 64); duration < 1.8 || duration > 2.3 {
+				t.Errorf("duration = %s, want about 2 seconds", value)
+			}
+		}
+	}
+}
+
+func TestAssembler_Assemble_MissingVideo(t *testing.T) {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg not found in PATH")
+	}
+
+	a := New(config.AssemblyConfig{OutputDir: t.TempDir(), FFMPEGPath: ffmpegPath})
+	_, err = a.Assemble(context.Background(), []common.Video{{Path: "/nonexistent/video.mp4"}}, common.Music{})
+	if err == nil || !strings.Contains(err.Error(), "video 1") {
+		t.Errorf("Assemble() error = %v, want the missing video", err)
+	}
+}
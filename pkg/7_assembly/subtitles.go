@@ -0,0 +1,169 @@
+package assembly
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/iantozer/stitch-up/internal/ffmpeg"
+	"github.com/iantozer/stitch-up/pkg/common"
+)
+
+// Subtitle positions
+const (
+	SubtitleBottom = "bottom"
+	SubtitleMiddle = "middle"
+	SubtitleTop    = "top"
+)
+
+// subtitleAlignments maps each position to its ASS alignment, numbered like a keypad
+var subtitleAlignments = map[string]int{
+	SubtitleBottom: 2,
+	SubtitleMiddle: 5,
+	SubtitleTop:    8,
+}
+
+// subtitleMargin keeps burned-in subtitles clear of the edge of the frame, in pixels
+const subtitleMargin = 40
+
+// cue is a line of lyrics shown between two times, in seconds
+type cue struct {
+	start float64
+	end   float64
+	text  string
+}
+
+// subtitleCues spreads the lyrics over the video. Lines with a duration keep it; the rest share
+// the time left evenly. Cues past the end of the video are dropped or cut short.
+func subtitleCues(lyrics common.Lyrics, duration float64) []cue {
+	lines := lyrics.Lines()
+	if len(lines) == 0 {
+		// Lyrics without sections only have their text
+		for _, section := range common.ParseLyrics(lyrics.Content) {
+			lines = append(lines, section.Lines...)
+		}
+	}
+	if len(lines) == 0 || duration <= 0 {
+		return nil
+	}
+
+	timed, untimed := 0.0, 0
+	for _, line := range lines {
+		if line.Duration > 0 {
+			timed += line.Duration
+		} else {
+			untimed++
+		}
+	}
+	share := 0.0
+	if untimed > 0 {
+		share = math.Max(duration-timed, 0) / float64(untimed)
+	}
+
+	var cues []cue
+	start := 0.0
+	for _, line := range lines {
+		length := line.Duration
+		if length <= 0 {
+			length = share
+		}
+		if length <= 0 || start >= duration {
+			continue
+		}
+
+		end := math.Min(start+length, duration)
+		cues = append(cues, cue{start: start, end: end, text: line.Text})
+		start = end
+	}
+
+	return cues
+}
+
+// formatSRT writes cues as SubRip subtitles
+func formatSRT(cues []cue) string {
+	var sb strings.Builder
+	for i, c := range cues {
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(c.start, ","), timestamp(c.end, ","), c.text)
+	}
+	return sb.String()
+}
+
+// formatVTT writes cues as WebVTT subtitles
+func formatVTT(cues []cue) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n\n")
+	for _, c := range cues {
+		fmt.Fprintf(&sb, "%s --> %s\n%s\n\n", timestamp(c.start, "."), timestamp(c.end, "."), c.text)
+	}
+	return sb.String()
+}
+
+// timestamp formats seconds as hours, minutes, seconds and milliseconds, e.g. 00:01:02,500
+func timestamp(seconds float64, separator string) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
+
+// writeSubtitles writes SRT and WebVTT files next to the video, named after it
+func writeSubtitles(videoPath string, cues []cue) error {
+	base := strings.TrimSuffix(videoPath, ".mp4")
+	if err := os.WriteFile(base+".srt", []byte(formatSRT(cues)), 0644); err != nil {
+		return fmt.Errorf("error writing SRT subtitles: %w", err)
+	}
+	if err := os.WriteFile(base+".vtt", []byte(formatVTT(cues)), 0644); err != nil {
+		return fmt.Errorf("error writing WebVTT subtitles: %w", err)
+	}
+	return nil
+}
+
+// subtitleStyle overrides the SubRip styling with the configured font, size and position
+func (a *Assembler) subtitleStyle() string {
+	style := []string{
+		fmt.Sprintf("FontSize=%d", a.config.SubtitleSize),
+		fmt.Sprintf("Alignment=%d", subtitleAlignments[a.config.SubtitlePosition]),
+		fmt.Sprintf("MarginV=%d", subtitleMargin),
+		"Outline=2",
+	}
+	if a.config.SubtitleFont != "" {
+		style = append([]string{"FontName=" + a.config.SubtitleFont}, style...)
+	}
+	return strings.Join(style, ",")
+}
+
+// burnArgs builds the ffmpeg arguments that draw the subtitles onto the video
+func (a *Assembler) burnArgs(video, subtitles, output string) []string {
+	filter := "subtitles=filename=" + escapeFilterValue(subtitles) + ":force_style=" + escapeFilterValue(a.subtitleStyle())
+
+	return []string{
+		"-y", "-i", video,
+		"-vf", filter,
+		"-c:v", a.config.VideoCodec,
+		"-c:a", "copy",
+		output,
+	}
+}
+
+// burnSubtitles draws the subtitles onto the video
+func (a *Assembler) burnSubtitles(ctx context.Context, ffmpegPath, video, subtitles, output string) error {
+	if err := ffmpeg.Run(ctx, ffmpegPath, a.burnArgs(video, subtitles, output)...); err != nil {
+		return fmt.Errorf("error burning in subtitles: %w", err)
+	}
+	return nil
+}
+
+// escapeFilterValue escapes a value for a filter option and then for the filter graph around it
+func escapeFilterValue(value string) string {
+	escape := func(s, special string) string {
+		var sb strings.Builder
+		for _, r := range s {
+			if strings.ContainsRune(special, r) {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(r)
+		}
+		return sb.String()
+	}
+	return escape(escape(value, `\':`), `\'[],;`)
+}
@@ -0,0 +1,122 @@
+package assembly
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+func TestSubtitleCues(t *testing.T) {
+	tests := []struct {
+		name     string
+		lyrics   common.Lyrics
+		duration float64
+		want     []cue
+	}{
+		{
+			name: "even",
+			lyrics: common.Lyrics{Sections: []common.Section{
+				{Type: common.SectionVerse, Lines: []common.Line{{Text: "one"}, {Text: "two"}}},
+				{Type: common.SectionChorus, Lines: []common.Line{{Text: "three"}, {Text: "four"}}},
+			}},
+			duration: 20,
+			want:     []cue{{0, 5, "one"}, {5, 10, "two"}, {10, 15, "three"}, {15, 20, "four"}},
+		},
+		{
+			name: "timed lines keep their durations",
+			lyrics: common.Lyrics{Sections: []common.Section{
+				{Type: common.SectionVerse, Lines: []common.Line{{Text: "one", Duration: 2}, {Text: "two"}, {Text: "three", Duration: 4}}},
+			}},
+			duration: 10,
+			want:     []cue{{0, 2, "one"}, {2, 6, "two"}, {6, 10, "three"}},
+		},
+		{
+			name: "cut at the end of the video",
+			lyrics: common.Lyrics{Sections: []common.Section{
+				{Type: common.SectionVerse, Lines: []common.Line{{Text: "one", Duration: 4}, {Text: "two", Duration: 4}, {Text: "three", Duration: 4}}},
+			}},
+			duration: 6,
+			want:     []cue{{0, 4, "one"}, {4, 6, "two"}},
+		},
+		{
+			name:     "content only",
+			lyrics:   common.Lyrics{Content: "VERSE 1:\none\ntwo"},
+			duration: 4,
+			want:     []cue{{0, 2, "one"}, {2, 4, "two"}},
+		},
+		{
+			name:     "no lyrics",
+			duration: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subtitleCues(tt.lyrics, tt.duration)
+			if len(got) != len(tt.want) {
+				t.Fatalf("subtitleCues() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("cue %d = %v, want %v", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFormatSubtitles(t *testing.T) {
+	cues := []cue{{0, 2.5, "Headlines flash across the screen"}, {62.25, 3725.5, "Stories of a world unseen"}}
+
+	wantSRT := "1\n00:00:00,000 --> 00:00:02,500\nHeadlines flash across the screen\n\n" +
+		"2\n00:01:02,250 --> 01:02:05,500\nStories of a world unseen\n\n"
+	if got := formatSRT(cues); got != wantSRT {
+		t.Errorf("formatSRT() = %q, want %q", got, wantSRT)
+	}
+
+	wantVTT := "WEBVTT\n\n00:00:00.000 --> 00:00:02.500\nHeadlines flash across the screen\n\n" +
+		"00:01:02.250 --> 01:02:05.500\nStories of a world unseen\n\n"
+	if got := formatVTT(cues); got != wantVTT {
+		t.Errorf("formatVTT() = %q, want %q", got, wantVTT)
+	}
+}
+
+func TestBurnArgs(t *testing.T) {
+	a := New(config.AssemblyConfig{SubtitleFont: "DejaVu Sans", SubtitleSize: 30, SubtitlePosition: SubtitleTop}).(*Assembler)
+	args := strings.Join(a.burnArgs("in.mp4", "/tmp/it's:here/lyrics.srt", "out.mp4"), " ")
+
+	// The path and style are escaped for the option and again for the filter graph
+	want := `subtitles=filename=/tmp/it\\\'s\\:here/lyrics.srt:force_style=FontName=DejaVu Sans\,FontSize=30\,Alignment=8\,MarginV=40\,Outline=2`
+	if !strings.Contains(args, want) {
+		t.Errorf("burnArgs() = %s, want %s", args, want)
+	}
+}
+
+func TestAssembler_Assemble_Subtitles(t *testing.T) {
+	// The mock assembler still writes the subtitles next to its placeholder output
+	outputDir := t.TempDir()
+	a := NewMock(config.AssemblyConfig{OutputDir: outputDir, FFMPEGPath: "/nonexistent/ffmpeg", Subtitles: true})
+
+	music := common.Music{Lyrics: common.Lyrics{Content: "VERSE 1:\nHeadlines flash across the screen\nStories of a world unseen"}}
+	outputPath, err := a.Assemble(context.Background(), []common.Video{{Path: "video1.mp4", Length: 5}, {Path: "video2.mp4", Length: 5}}, music)
+	if err != nil {
+		t.Fatalf("Assemble() error = %v", err)
+	}
+
+	base := strings.TrimSuffix(outputPath, ".mp4")
+	srt, err := os.ReadFile(base + ".srt")
+	if err != nil || !strings.Contains(string(srt), "00:00:05,000 --> 00:00:10,000\nStories of a world unseen") {
+		t.Errorf("SRT = %q, %v", srt, err)
+	}
+	if vtt, err := os.ReadFile(base + ".vtt"); err != nil || !strings.HasPrefix(string(vtt), "WEBVTT") {
+		t.Errorf("WebVTT = %q, %v", vtt, err)
+	}
+	if filepath.Dir(base) != outputDir {
+		t.Errorf("subtitles written to %s", filepath.Dir(base))
+	}
+}
@@ -0,0 +1,166 @@
+package assembly
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+)
+
+// Transition types
+const (
+	TransitionNone       = "none"
+	TransitionCrossfade  = "crossfade"
+	TransitionDipToBlack = "dip-to-black"
+	TransitionWipe       = "wipe"
+	TransitionSlide      = "slide"
+)
+
+// defaultTransitionDuration is used when a transition is chosen without a duration
+const defaultTransitionDuration = 0.5
+
+// xfadeTransitions maps each transition type to ffmpeg's xfade filter transition
+var xfadeTransitions = map[string]string{
+	TransitionCrossfade:  "fade",
+	TransitionDipToBlack: "fadeblack",
+	TransitionWipe:       "wipeleft",
+	TransitionSlide:      "slideleft",
+}
+
+// cut is the join between one clip and the next
+type cut struct {
+	transition string  // a transition type
+	duration   float64 // seconds the two clips overlap; 0 for a hard cut
+	offset     float64 // seconds into the joined video where the transition starts
+}
+
+// timeline plans the joins between clips. Each clip is held for the length of its outgoing
+// transition, so the transitions overlap that extra time and the clips start and end exactly
+// where they would with hard cuts: the total length stays the sum of the clip lengths,
+// matching music made for them.
+func (a *Assembler) timeline(videos []common.Video) ([]cut, error) {
+	cuts := make([]cut, 0, len(videos)-1)
+	start := 0.0
+
+	for i := 0; i < len(videos)-1; i++ {
+		transition, duration := a.config.Transition, a.config.TransitionDuration
+		if override, ok := a.config.SceneTransitions[i+1]; ok {
+			if override.Type != "" {
+				transition = override.Type
+			}
+			if override.Duration > 0 {
+				duration = override.Duration
+			}
+		}
+
+		if transition == "" {
+			transition = TransitionNone
+		}
+		if transition != TransitionNone {
+			if _, ok := xfadeTransitions[transition]; !ok {
+				return nil, fmt.Errorf("unknown transition %q after scene %d", transition, i+1)
+			}
+			if videos[i].Length <= 0 || videos[i+1].Length <= 0 {
+				return nil, fmt.Errorf("scenes %d and %d need lengths for a %s transition", i+1, i+2, transition)
+			}
+			if duration <= 0 {
+				duration = defaultTransitionDuration
+			}
+			// Never let a transition take more than half of either scene
+			duration = min(duration, float64(videos[i].Length)/2, float64(videos[i+1].Length)/2)
+		} else {
+			duration = 0
+		}
+
+		start += float64(videos[i].Length)
+		cuts = append(cuts, cut{transition: transition, duration: duration, offset: start})
+	}
+
+	return cuts, nil
+}
+
+// hasTransitions reports whether any join is more than a hard cut
+func hasTransitions(cuts []cut) bool {
+	for _, c := range cuts {
+		if c.transition != TransitionNone {
+			return true
+		}
+	}
+	return false
+}
+
+// clipLengths returns how long each normalized clip must be: its scene's length plus its
+// outgoing transition, or 0 to keep the clip as it is
+func clipLengths(videos []common.Video, cuts []cut) []float64 {
+	lengths := make([]float64, len(videos))
+	for i, video := range videos {
+		if video.Length <= 0 {
+			continue
+		}
+		lengths[i] = float64(video.Length)
+		if i < len(cuts) {
+			lengths[i] += cuts[i].duration
+		}
+	}
+	return lengths
+}
+
+// transitionFilter builds the filter graph that joins the clips, labelling the result [vout].
+// With audio, the clips' audio is crossfaded over the same overlaps and labelled [aout].
+func transitionFilter(cuts []cut, audio bool) string {
+	var chain []string
+	previous, previousAudio := "[0:v]", "[0:a]"
+
+	for i, c := range cuts {
+		label, audioLabel := fmt.Sprintf("[v%d]", i+1), fmt.Sprintf("[a%d]", i+1)
+		if i == len(cuts)-1 {
+			label, audioLabel = "[vout]", "[aout]"
+		}
+		next, nextAudio := fmt.Sprintf("[%d:v]", i+1), fmt.Sprintf("[%d:a]", i+1)
+
+		if c.transition == TransitionNone {
+			chain = append(chain, previous+next+"concat=n=2:v=1:a=0"+label)
+			if audio {
+				chain = append(chain, previousAudio+nextAudio+"concat=n=2:v=0:a=1"+audioLabel)
+			}
+		} else {
+			chain = append(chain, fmt.Sprintf("%s%sxfade=transition=%s:duration=%s:offset=%s%s",
+				previous, next, xfadeTransitions[c.transition], seconds(c.duration), seconds(c.offset), label))
+			if audio {
+				chain = append(chain, fmt.Sprintf("%s%sacrossfade=d=%s%s", previousAudio, nextAudio, seconds(c.duration), audioLabel))
+			}
+		}
+		previous, previousAudio = label, audioLabel
+	}
+
+	return strings.Join(chain, ";")
+}
+
+// transitionArgs builds the ffmpeg arguments that join the clips with transitions
+func (a *Assembler) transitionArgs(clips []string, cuts []cut, output string) []string {
+	var args []string
+	args = append(args, "-y")
+	for _, clip := range clips {
+		args = append(args, "-i", clip)
+	}
+
+	args = append(args,
+		"-filter_complex", transitionFilter(cuts, a.config.ClipAudio),
+		"-map", "[vout]",
+		"-c:v", a.config.VideoCodec,
+		"-pix_fmt", "yuv420p",
+		"-r", strconv.Itoa(a.config.FPS),
+	)
+	if a.config.ClipAudio {
+		args = append(args, "-map", "[aout]", "-c:a", a.config.AudioCodec, "-b:a", a.config.AudioBitrate)
+	}
+
+	return append(args, output)
+}
+
+// seconds formats a time in seconds for ffmpeg, to the millisecond
+func seconds(s float64) string {
+	return strconv.FormatFloat(math.Round(s*1000)/1000, 'f', -1, 64)
+}
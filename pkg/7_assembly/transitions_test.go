@@ -0,0 +1,122 @@
+package assembly
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iantozer/stitch-up/pkg/common"
+	"github.com/iantozer/stitch-up/pkg/config"
+)
+
+func TestTimeline(t *testing.T) {
+	videos := []common.Video{{Length: 10}, {Length: 10}, {Length: 4}, {Length: 10}}
+
+	a := New(config.AssemblyConfig{
+		Transition:         TransitionCrossfade,
+		TransitionDuration: 1,
+		SceneTransitions: map[int]config.TransitionConfig{
+			2: {Type: TransitionNone},
+			3: {Type: TransitionWipe, Duration: 5},
+		},
+	}).(*Assembler)
+
+	cuts, err := a.timeline(videos)
+	if err != nil {
+		t.Fatalf("timeline() error = %v", err)
+	}
+
+	want := []cut{
+		{transition: TransitionCrossfade, duration: 1, offset: 10},
+		{transition: TransitionNone, duration: 0, offset: 20},
+		{transition: TransitionWipe, duration: 2, offset: 24}, // capped at half the shorter scene
+	}
+	if len(cuts) != len(want) {
+		t.Fatalf("timeline() = %+v, want %+v", cuts, want)
+	}
+	for i := range want {
+		if cuts[i] != want[i] {
+			t.Errorf("cut %d = %+v, want %+v", i+1, cuts[i], want[i])
+		}
+	}
+
+	// Each clip is held through its outgoing transition, so the joined length is the sum of the scenes
+	lengths := clipLengths(videos, cuts)
+	wantLengths := []float64{11, 10, 6, 10}
+	total := 0.0
+	for i := range wantLengths {
+		if lengths[i] != wantLengths[i] {
+			t.Errorf("clip %d length = %v, want %v", i+1, lengths[i], wantLengths[i])
+		}
+		total += lengths[i]
+	}
+	for _, c := range cuts {
+		total -= c.duration
+	}
+	if total != 34 {
+		t.Errorf("joined length = %v, want 34", total)
+	}
+}
+
+func TestTimeline_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.AssemblyConfig
+		videos []common.Video
+		want   string
+	}{
+		{
+			name:   "unknown transition",
+			config: config.AssemblyConfig{Transition: "spin"},
+			videos: []common.Video{{Length: 5}, {Length: 5}},
+			want:   `unknown transition "spin" after scene 1`,
+		},
+		{
+			name:   "unknown override",
+			config: config.AssemblyConfig{SceneTransitions: map[int]config.TransitionConfig{2: {Type: "spin"}}},
+			videos: []common.Video{{Length: 5}, {Length: 5}, {Length: 5}},
+			want:   `unknown transition "spin" after scene 2`,
+		},
+		{
+			name:   "missing length",
+			config: config.AssemblyConfig{Transition: TransitionSlide},
+			videos: []common.Video{{Length: 5}, {}},
+			want:   "scenes 1 and 2 need lengths",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.config).(*Assembler).timeline(tt.videos)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("timeline() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransitionFilter(t *testing.T) {
+	cuts := []cut{
+		{transition: TransitionDipToBlack, duration: 0.5, offset: 10},
+		{transition: TransitionNone, offset: 20},
+		{transition: TransitionSlide, duration: 0.75, offset: 30},
+	}
+
+	want := "[0:v][1:v]xfade=transition=fadeblack:duration=0.5:offset=10[v1];" +
+		"[v1][2:v]concat=n=2:v=1:a=0[v2];" +
+		"[v2][3:v]xfade=transition=slideleft:duration=0.75:offset=30[vout]"
+	if got := transitionFilter(cuts, false); got != want {
+		t.Errorf("transitionFilter() = %s, want %s", got, want)
+	}
+
+	// Clip audio follows the same overlaps
+	got := transitionFilter(cuts, true)
+	for _, wantAudio := range []string{
+		"[0:a][1:a]acrossfade=d=0.5[a1]",
+		"[a1][2:a]concat=n=2:v=0:a=1[a2]",
+		"[a2][3:a]acrossfade=d=0.75[aout]",
+	} {
+		if !strings.Contains(got, wantAudio) {
+			t.Errorf("transitionFilter() = %s, missing %s", got, wantAudio)
+		}
+	}
+}
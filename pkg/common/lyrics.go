@@ -0,0 +1,156 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Section types
+const (
+	SectionIntro     = "intro"
+	SectionVerse     = "verse"
+	SectionPreChorus = "pre-chorus"
+	SectionChorus    = "chorus"
+	SectionBridge    = "bridge"
+	SectionOutro     = "outro"
+)
+
+// SectionTypes lists every section type a song may use
+var SectionTypes = []string{SectionIntro, SectionVerse, SectionPreChorus, SectionChorus, SectionBridge, SectionOutro}
+
+var (
+	// headingPattern matches section headings such as "VERSE 1:", "Chorus" or "[Pre-Chorus]"
+	headingPattern = regexp.MustCompile(`^\[?\s*(?i:(intro|verse|pre[- ]?chorus|chorus|bridge|outro))\s*(\d+)?\s*\]?\s*:?$`)
+
+	// durationPattern matches a line's target duration written after it, e.g. "{3.5s}"
+	durationPattern = regexp.MustCompile(`\s*\{(\d+(?:\.\d+)?)s\}$`)
+)
+
+// ParseLyrics splits heading-based lyrics text into sections. Lines before the
+// first heading form a verse, and a line may end with its target duration in
+// braces, e.g. "Headlines flash across the screen {3.5s}".
+func ParseLyrics(text string) []Section {
+	var sections []Section
+	var current *Section
+
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			sectionType := strings.ToLower(match[1])
+			if strings.HasPrefix(sectionType, "pre") {
+				sectionType = SectionPreChorus
+			}
+			number, _ := strconv.Atoi(match[2])
+
+			sections = append(sections, Section{Type: sectionType, Number: number})
+			current = &sections[len(sections)-1]
+			continue
+		}
+
+		if current == nil {
+			sections = append(sections, Section{Type: SectionVerse})
+			current = &sections[len(sections)-1]
+		}
+
+		current.Lines = append(current.Lines, parseLine(line))
+	}
+
+	// Drop headings with no lines under them
+	kept := sections[:0]
+	for _, section := range sections {
+		if len(section.Lines) > 0 {
+			kept = append(kept, section)
+		}
+	}
+
+	return numberVerses(kept)
+}
+
+// LineWords returns the lowercase words of a line, keeping apostrophes so
+// contractions such as "don't" stay whole
+func LineWords(line string) []string {
+	return strings.FieldsFunc(strings.ToLower(line), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+}
+
+// RenderLyrics writes sections back to heading-based text that ParseLyrics reads
+func RenderLyrics(sections []Section) string {
+	var sb strings.Builder
+
+	for i, section := range numberVerses(sections) {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+
+		heading := strings.ToUpper(section.Type)
+		if section.Number > 0 {
+			heading += " " + strconv.Itoa(section.Number)
+		}
+		sb.WriteString(heading + ":\n")
+
+		for _, line := range section.Lines {
+			sb.WriteString(line.Text)
+			if line.Duration > 0 {
+				sb.WriteString(" {" + strconv.FormatFloat(line.Duration, 'f', -1, 64) + "s}")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// Lines returns every sung line in order
+func (l Lyrics) Lines() []Line {
+	var lines []Line
+	for _, section := range l.Sections {
+		lines = append(lines, section.Lines...)
+	}
+	return lines
+}
+
+// String returns a short description of the section, e.g. "verse 2" or "chorus"
+func (s Section) String() string {
+	if s.Number > 0 {
+		return fmt.Sprintf("%s %d", s.Type, s.Number)
+	}
+	return s.Type
+}
+
+// parseLine reads a line and its optional target duration
+func parseLine(line string) Line {
+	match := durationPattern.FindStringSubmatchIndex(line)
+	if match == nil {
+		return Line{Text: line}
+	}
+
+	duration, _ := strconv.ParseFloat(line[match[2]:match[3]], 64)
+	return Line{Text: line[:match[0]], Duration: duration}
+}
+
+// numberVerses returns the sections with any unnumbered verses numbered in order
+func numberVerses(sections []Section) []Section {
+	numbered := make([]Section, len(sections))
+	copy(numbered, sections)
+
+	verses := 0
+	for i := range numbered {
+		if numbered[i].Type != SectionVerse {
+			continue
+		}
+		verses++
+		if numbered[i].Number == 0 {
+			numbered[i].Number = verses
+		}
+	}
+
+	return numbered
+}
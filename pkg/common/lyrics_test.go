@@ -0,0 +1,82 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLyrics(t *testing.T) {
+	text := `Headlines flash across the screen
+Stories of a world unseen
+
+[Chorus]
+This is the news of today {3.5s}
+Moments that will fade away
+
+Pre-Chorus:
+Hold on
+
+VERSE 2:
+From the markets to the sea
+
+BRIDGE:
+
+CHORUS:
+This is the news of today
+`
+
+	want := []Section{
+		{Type: SectionVerse, Number: 1, Lines: []Line{{Text: "Headlines flash across the screen"}, {Text: "Stories of a world unseen"}}},
+		{Type: SectionChorus, Lines: []Line{{Text: "This is the news of today", Duration: 3.5}, {Text: "Moments that will fade away"}}},
+		{Type: SectionPreChorus, Lines: []Line{{Text: "Hold on"}}},
+		{Type: SectionVerse, Number: 2, Lines: []Line{{Text: "From the markets to the sea"}}},
+		{Type: SectionChorus, Lines: []Line{{Text: "This is the news of today"}}},
+	}
+
+	got := ParseLyrics(text)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLyrics() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestRenderLyrics(t *testing.T) {
+	sections := []Section{
+		{Type: SectionVerse, Lines: []Line{{Text: "Headlines flash across the screen", Duration: 2.75}}},
+		{Type: SectionChorus, Lines: []Line{{Text: "This is the news of today"}}},
+		{Type: SectionVerse, Lines: []Line{{Text: "From the markets to the sea"}}},
+	}
+
+	want := `VERSE 1:
+Headlines flash across the screen {2.75s}
+
+CHORUS:
+This is the news of today
+
+VERSE 2:
+From the markets to the sea
+`
+
+	text := RenderLyrics(sections)
+	if text != want {
+		t.Errorf("RenderLyrics() =\n%s\nwant\n%s", text, want)
+	}
+
+	// Rendered text parses back to the same sections, numbered
+	parsed := ParseLyrics(text)
+	if len(parsed) != 3 || parsed[2].Number != 2 || parsed[0].Lines[0].Duration != 2.75 {
+		t.Errorf("ParseLyrics(RenderLyrics()) = %+v", parsed)
+	}
+
+	lyrics := Lyrics{Sections: parsed}
+	if lines := lyrics.Lines(); len(lines) != 3 || lines[1].Text != "This is the news of today" {
+		t.Errorf("Lines() = %+v", lines)
+	}
+}
+
+func TestLineWords(t *testing.T) {
+	got := LineWords("Don't stop, the café's NEWS-ROOM")
+	want := []string{"don't", "stop", "the", "café's", "news", "room"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LineWords() = %q, want %q", got, want)
+	}
+}
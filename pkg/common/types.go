@@ -18,16 +18,23 @@ type Article struct {
 	Summary     string
 	Content     string
 	URL         string
+	Section     string
+	Byline      string
 	PublishedAt string
+	Sources     []string // outlets that covered the story, when aggregated
 }
 
 // Scene represents a visual scene description
 type Scene struct {
-	Description string `json:"description"`
-	SourceTitle string `json:"source_title"`
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Mood        string `json:"mood"`
+	Description string   `json:"description"`
+	SourceTitle string   `json:"source_title"`
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Mood        string   `json:"mood"`
+	Setting     string   `json:"setting,omitempty"`
+	Subjects    []string `json:"subjects,omitempty"`
+	Palette     string   `json:"palette,omitempty"`
+	Camera      string   `json:"camera,omitempty"`
 }
 
 // Image represents a generated image
@@ -35,6 +42,8 @@ type Image struct {
 	Path        string
 	SceneID     string
 	Description string
+	Mood        string // the scene's mood
+	Camera      string // the scene's camera direction, e.g. "slow push in"
 }
 
 // Video represents a generated video clip
@@ -46,15 +55,34 @@ type Video struct {
 
 // Lyrics represents generated song lyrics
 type Lyrics struct {
-	Title   string
-	Content string
+	Title    string
+	Content  string    // the lyrics as text, with a heading such as "VERSE 1:" before each section
+	Sections []Section // the lyrics in the order they are sung; see ParseLyrics
+	Themes   []string  // the news themes the song is about
+	Genre    string    // genre hint for music generation, e.g. "indie folk"
+	Tempo    int       // tempo hint in beats per minute, 0 if unknown
+	Mood     string    // mood hint for music generation, e.g. "wistful"
+}
+
+// Section is a single section of a song
+type Section struct {
+	Type   string // one of the Section* types
+	Number int    // the verse number, 0 for sections that are not numbered
+	Lines  []Line
+}
+
+// Line is a single sung line
+type Line struct {
+	Text     string
+	Duration float64 // target length in seconds, 0 if unspecified
 }
 
 // Music represents a generated music track
 type Music struct {
 	Path     string
 	LyricsID string
-	Length   int // in seconds
+	Length   int    // in seconds
+	Lyrics   Lyrics // the lyrics sung in the track, for subtitles
 }
 
 // ContentExtractor extracts news content
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/iantozer/stitch-up/pkg/transport"
 	"github.com/joho/godotenv"
 )
 
@@ -19,50 +21,159 @@ type Config struct {
 	MusicGeneration   MusicGenerationConfig   `json:"music_generation"`
 	Assembly          AssemblyConfig          `json:"assembly"`
 	OutputDir         string                  `json:"output_dir"`
+
+	// MockFallback lets a stage whose backend cannot run, e.g. without its API key, use a mock or
+	// local backend instead of failing
+	MockFallback bool `json:"mock_fallback"`
+
+	// RateLimits overrides the built-in request rate of a provider ("anthropic", "huggingface", "runway", ...)
+	RateLimits map[string]transport.Limit `json:"rate_limits"`
 }
 
 // ContentExtractionConfig holds configuration for content extraction
 type ContentExtractionConfig struct {
-	Source       string `json:"source"`
-	ClaudeAPIKey string `json:"claude_api_key"`
+	Backend string `json:"backend"` // "feed", "pages", "screenshots" or "aggregator"; feeds by default
+
+	Source        string   `json:"source"`
+	Feeds         []string `json:"feeds"`          // RSS/Atom URLs or local files; takes precedence over Source
+	PageDir       string   `json:"page_dir"`       // directory of saved article HTML pages
+	ScreenshotDir string   `json:"screenshot_dir"` // directory of news screenshots read with Claude vision
+	FetchArticles bool     `json:"fetch_articles"` // fetch each article's page for its full text
+	MaxArticles   int      `json:"max_articles"`
+	ClaudeAPIKey  string   `json:"claude_api_key"`
+	ClaudeBaseURL string   `json:"claude_base_url"`
+	ClaudeModel   string   `json:"claude_model"`
+	PromptDir     string   `json:"prompt_dir"` // directory of prompt templates overriding the built-in ones
+
+	// Sources lists several outlets to merge; duplicate stories are clustered and ranked by coverage
+	Sources             []SourceConfig `json:"sources"`
+	TopStories          int            `json:"top_stories"`
+	SimilarityThreshold float64        `json:"similarity_threshold"` // 0-1 word overlap for two articles to be one story
+}
+
+// SourceConfig describes a single content source for aggregation
+type SourceConfig struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`     // "feed", "pages" or "screenshots"
+	Location      string `json:"location"` // feed URL or file, or directory for pages and screenshots
+	FetchArticles bool   `json:"fetch_articles"`
 }
 
 // SceneGenerationConfig holds configuration for scene generation
 type SceneGenerationConfig struct {
-	ClaudeKey string `json:"claude_key"`
-	MaxScenes int    `json:"max_scenes"`
+	Backend       string `json:"backend"` // "claude" or "mock"; Claude by default
+	ClaudeKey     string `json:"claude_key"`
+	ClaudeBaseURL string `json:"claude_base_url"`
+	ClaudeModel   string `json:"claude_model"`
+	MaxScenes     int    `json:"max_scenes"`
+	Mode          string `json:"mode"`         // "text", "screenshots" or "" to use articles when there are any
+	InputDir      string `json:"input_dir"`    // headline screenshots for screenshot mode
+	Concurrency   int    `json:"concurrency"`  // number of scenes generated in parallel
+	MaxAttempts   int    `json:"max_attempts"` // requests per scene before a scene that fails validation is an error
+	PromptDir     string `json:"prompt_dir"`   // directory of prompt templates overriding the built-in ones
 }
 
 // ImageCreationConfig holds configuration for image creation
 type ImageCreationConfig struct {
+	Backend           string `json:"backend"` // "huggingface" or "mock"; Hugging Face by default
 	HuggingFaceAPIKey string `json:"huggingface_api_key"`
 	HuggingFaceModel  string `json:"huggingface_model"`
 	OutputDir         string `json:"output_dir"`
+	PromptDir         string `json:"prompt_dir"` // directory of prompt templates overriding the built-in ones
 }
 
 // VideoConversionConfig holds configuration for video conversion
 type VideoConversionConfig struct {
+	Backend               string `json:"backend"` // "runway", "replicate", "local" or "node"; Runway by default
 	RunwayAPIKey          string `json:"runway_api_key"`
+	RunwayBaseURL         string `json:"runway_base_url"`
+	RunwayModel           string `json:"runway_model"` // e.g. gen3a_turbo
 	OutputDir             string `json:"output_dir"`
 	VideoLength           int    `json:"video_length"` // in seconds
+	ReplicateAPIToken     string `json:"replicate_api_token"`
+	ReplicateBaseURL      string `json:"replicate_base_url"`
+	ReplicateModel        string `json:"replicate_model"` // owner/name:version, Stable Video Diffusion by default
 	UseNodeImplementation bool   `json:"use_node_implementation"`
+	Concurrency           int    `json:"concurrency"`   // number of Runway jobs submitted or checked at once
+	PollInterval          int    `json:"poll_interval"` // in seconds
+	MaxWait               int    `json:"max_wait"`      // in seconds the jobs may take to generate
+
+	// Clips rendered locally from the still images by the "local" backend
+	FFMPEGPath string `json:"ffmpeg_path"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	FPS        int    `json:"fps"`
+	Motion     string `json:"motion"` // "zoom-in", "zoom-out", "pan-left", "pan-right", "pan-up" or "pan-down"; empty picks one per scene
 }
 
 // LyricCreationConfig holds configuration for lyric creation
 type LyricCreationConfig struct {
-	ClaudeKey string `json:"claude_key"`
+	Backend       string  `json:"backend"` // "claude" or "mock"; Claude by default
+	ClaudeKey     string  `json:"claude_key"`
+	ClaudeBaseURL string  `json:"claude_base_url"`
+	ClaudeModel   string  `json:"claude_model"`
+	PromptDir     string  `json:"prompt_dir"`   // directory of prompt templates overriding the built-in ones
+	MaxAttempts   int     `json:"max_attempts"` // requests before lyrics that fail validation are an error
+	MinScore      float64 `json:"min_score"`    // 0-1 meter and rhyme score below which lyrics are sent back; 0 disables the check
+	Offline       bool    `json:"offline"`      // the same as the "mock" backend
+
+	// Originality checks against known lyrics and our own past songs
+	CorpusDir     string  `json:"corpus_dir"`     // directory of known lyrics as .txt files
+	ArchiveDir    string  `json:"archive_dir"`    // directory where every run's lyrics are archived and checked against
+	ShingleSize   int     `json:"shingle_size"`   // consecutive words compared
+	MaxSimilarity float64 `json:"max_similarity"` // 0-1 share of a section's shingles found in one song above which lyrics are rejected
 }
 
 // MusicGenerationConfig holds configuration for music generation
 type MusicGenerationConfig struct {
-	SunoAPIKey string `json:"suno_api_key"`
-	OutputDir  string `json:"output_dir"`
+	Backend         string `json:"backend"` // "suno" or "synth"; Suno by default
+	SunoAPIKey      string `json:"suno_api_key"`
+	SunoBaseURL     string `json:"suno_base_url"`
+	SunoModel       string `json:"suno_model"`
+	SunoCallbackURL string `json:"suno_callback_url"` // where Suno posts finished tasks; empty as tasks are polled
+	OutputDir       string `json:"output_dir"`
+	PollInterval    int    `json:"poll_interval"` // in seconds
+	MaxWait         int    `json:"max_wait"`      // in seconds a track may take to generate
+	Duration        int    `json:"duration"`      // in seconds of music synthesized by the "synth" backend; 0 matches the videos
 }
 
 // AssemblyConfig holds configuration for final assembly
 type AssemblyConfig struct {
-	FFMPEGPath string `json:"ffmpeg_path"`
-	OutputDir  string `json:"output_dir"`
+	Backend      string `json:"backend"` // "ffmpeg" or "mock"; ffmpeg by default
+	FFMPEGPath   string `json:"ffmpeg_path"`
+	OutputDir    string `json:"output_dir"`
+	Width        int    `json:"width"` // every clip is scaled and padded to this size
+	Height       int    `json:"height"`
+	FPS          int    `json:"fps"`
+	VideoCodec   string `json:"video_codec"`   // ffmpeg encoder, e.g. libx264
+	AudioCodec   string `json:"audio_codec"`   // ffmpeg encoder, e.g. aac
+	AudioBitrate string `json:"audio_bitrate"` // e.g. 192k
+
+	// Transitions between clips: "none", "crossfade", "dip-to-black", "wipe" or "slide"
+	Transition         string                   `json:"transition"`
+	TransitionDuration float64                  `json:"transition_duration"` // in seconds
+	SceneTransitions   map[int]TransitionConfig `json:"scene_transitions"`   // transition out of a scene, by scene number from 1
+
+	// Audio mix
+	MusicFadeIn    float64 `json:"music_fade_in"`   // in seconds
+	MusicFadeOut   float64 `json:"music_fade_out"`  // in seconds
+	ClipAudio      bool    `json:"clip_audio"`      // keep the clips' own audio under the music
+	NarrationPath  string  `json:"narration_path"`  // narration laid over the video from the start
+	Duck           bool    `json:"duck"`            // lower the music under clip audio and narration
+	TargetLoudness float64 `json:"target_loudness"` // integrated loudness in LUFS; 0 leaves the mix as it is
+
+	// Subtitles of the lyrics
+	Subtitles        bool   `json:"subtitles"`         // write SRT and WebVTT files next to the output
+	BurnSubtitles    bool   `json:"burn_subtitles"`    // draw the lyrics onto the video
+	SubtitleFont     string `json:"subtitle_font"`     // font name for burned-in subtitles
+	SubtitleSize     int    `json:"subtitle_size"`     // font size for burned-in subtitles
+	SubtitlePosition string `json:"subtitle_position"` // "bottom", "middle" or "top"
+}
+
+// TransitionConfig overrides the transition out of a single scene
+type TransitionConfig struct {
+	Type     string  `json:"type"`
+	Duration float64 `json:"duration"` // in seconds; 0 uses the assembly's transition duration
 }
 
 // DefaultConfig returns a default configuration
@@ -72,24 +183,62 @@ func DefaultConfig() Config {
 
 	return Config{
 		ContentExtraction: ContentExtractionConfig{
-			Source: "https://www.wsj.com",
+			Source:              "https://feeds.bbci.co.uk/news/rss.xml",
+			MaxArticles:         20,
+			TopStories:          10,
+			SimilarityThreshold: 0.5,
 		},
 		SceneGeneration: SceneGenerationConfig{
-			MaxScenes: 5,
+			MaxScenes:   5,
+			InputDir:    "input/12_march_2025_bbc",
+			Concurrency: 4,
+			MaxAttempts: 3,
 		},
 		ImageCreation: ImageCreationConfig{
 			OutputDir: filepath.Join(outputDir, "images"),
 		},
 		VideoConversion: VideoConversionConfig{
-			OutputDir:   filepath.Join(outputDir, "videos"),
-			VideoLength: 10,
+			OutputDir:    filepath.Join(outputDir, "videos"),
+			VideoLength:  10,
+			Concurrency:  4,
+			PollInterval: 5,
+			MaxWait:      900,
+			FFMPEGPath:   "ffmpeg",
+			Width:        1280,
+			Height:       720,
+			FPS:          30,
+		},
+		LyricCreation: LyricCreationConfig{
+			MaxAttempts:   3,
+			MinScore:      0.7,
+			ArchiveDir:    filepath.Join(outputDir, "lyrics"),
+			ShingleSize:   5,
+			MaxSimilarity: 0.3,
 		},
 		MusicGeneration: MusicGenerationConfig{
-			OutputDir: filepath.Join(outputDir, "music"),
+			OutputDir:    filepath.Join(outputDir, "music"),
+			PollInterval: 10,
+			MaxWait:      600,
 		},
 		Assembly: AssemblyConfig{
-			FFMPEGPath: "ffmpeg",
-			OutputDir:  filepath.Join(outputDir, "final"),
+			FFMPEGPath:         "ffmpeg",
+			OutputDir:          filepath.Join(outputDir, "final"),
+			Width:              1280,
+			Height:             720,
+			FPS:                30,
+			VideoCodec:         "libx264",
+			AudioCodec:         "aac",
+			AudioBitrate:       "192k",
+			Transition:         "crossfade",
+			TransitionDuration: 0.5,
+			MusicFadeIn:        2,
+			MusicFadeOut:       3,
+			Duck:               true,
+			TargetLoudness:     -14,
+			Subtitles:          true,
+			SubtitleFont:       "Arial",
+			SubtitleSize:       24,
+			SubtitlePosition:   "bottom",
 		},
 		OutputDir: outputDir,
 	}
@@ -129,12 +278,79 @@ func Load() (Config, error) {
 		config.ContentExtraction.Source = source
 	}
 
+	if feeds := os.Getenv("NEWS_FEEDS"); feeds != "" {
+		config.ContentExtraction.Feeds = splitList(feeds)
+	}
+
+	if pageDir := os.Getenv("NEWS_PAGE_DIR"); pageDir != "" {
+		config.ContentExtraction.PageDir = pageDir
+	}
+
+	if screenshotDir := os.Getenv("NEWS_SCREENSHOT_DIR"); screenshotDir != "" {
+		config.ContentExtraction.ScreenshotDir = screenshotDir
+	}
+
+	if fetch := os.Getenv("FETCH_ARTICLES"); fetch != "" {
+		config.ContentExtraction.FetchArticles = fetch == "true"
+	}
+
+	if backend := os.Getenv("CONTENT_BACKEND"); backend != "" {
+		config.ContentExtraction.Backend = backend
+	}
+
 	if apiKey := os.Getenv("CLAUDE_API_KEY"); apiKey != "" {
 		config.ContentExtraction.ClaudeAPIKey = apiKey
 		config.SceneGeneration.ClaudeKey = apiKey
 		config.LyricCreation.ClaudeKey = apiKey
 	}
 
+	if baseURL := os.Getenv("CLAUDE_BASE_URL"); baseURL != "" {
+		config.ContentExtraction.ClaudeBaseURL = baseURL
+		config.SceneGeneration.ClaudeBaseURL = baseURL
+		config.LyricCreation.ClaudeBaseURL = baseURL
+	}
+
+	if model := os.Getenv("CLAUDE_MODEL"); model != "" {
+		config.ContentExtraction.ClaudeModel = model
+		config.SceneGeneration.ClaudeModel = model
+		config.LyricCreation.ClaudeModel = model
+	}
+
+	if mode := os.Getenv("SCENE_MODE"); mode != "" {
+		config.SceneGeneration.Mode = mode
+	}
+
+	if inputDir := os.Getenv("SCENE_INPUT_DIR"); inputDir != "" {
+		config.SceneGeneration.InputDir = inputDir
+	}
+
+	if promptDir := os.Getenv("PROMPT_DIR"); promptDir != "" {
+		config.ContentExtraction.PromptDir = promptDir
+		config.SceneGeneration.PromptDir = promptDir
+		config.ImageCreation.PromptDir = promptDir
+		config.LyricCreation.PromptDir = promptDir
+	}
+
+	if backend := os.Getenv("SCENE_BACKEND"); backend != "" {
+		config.SceneGeneration.Backend = backend
+	}
+
+	if backend := os.Getenv("LYRICS_BACKEND"); backend != "" {
+		config.LyricCreation.Backend = backend
+	}
+
+	if offline := os.Getenv("LYRICS_OFFLINE"); offline != "" {
+		config.LyricCreation.Offline = offline == "true"
+	}
+
+	if corpusDir := os.Getenv("LYRICS_CORPUS_DIR"); corpusDir != "" {
+		config.LyricCreation.CorpusDir = corpusDir
+	}
+
+	if backend := os.Getenv("IMAGE_BACKEND"); backend != "" {
+		config.ImageCreation.Backend = backend
+	}
+
 	if apiKey := os.Getenv("HUGGINGFACE_API_KEY"); apiKey != "" {
 		config.ImageCreation.HuggingFaceAPIKey = apiKey
 	}
@@ -150,15 +366,74 @@ func Load() (Config, error) {
 		config.VideoConversion.RunwayAPIKey = apiKey
 	}
 
+	if baseURL := os.Getenv("RUNWAY_BASE_URL"); baseURL != "" {
+		config.VideoConversion.RunwayBaseURL = baseURL
+	}
+
+	if backend := os.Getenv("VIDEO_BACKEND"); backend != "" {
+		config.VideoConversion.Backend = backend
+	}
+
+	// REPLICATE_API_KEY is the name the Node.js scripts use
+	if token := os.Getenv("REPLICATE_API_TOKEN"); token != "" {
+		config.VideoConversion.ReplicateAPIToken = token
+	} else if token := os.Getenv("REPLICATE_API_KEY"); token != "" {
+		config.VideoConversion.ReplicateAPIToken = token
+	}
+
+	if backend := os.Getenv("MUSIC_BACKEND"); backend != "" {
+		config.MusicGeneration.Backend = backend
+	}
+
 	if apiKey := os.Getenv("SUNO_API_KEY"); apiKey != "" {
 		config.MusicGeneration.SunoAPIKey = apiKey
 	}
 
+	if baseURL := os.Getenv("SUNO_BASE_URL"); baseURL != "" {
+		config.MusicGeneration.SunoBaseURL = baseURL
+	}
+
+	if callbackURL := os.Getenv("SUNO_CALLBACK_URL"); callbackURL != "" {
+		config.MusicGeneration.SunoCallbackURL = callbackURL
+	}
+
+	if backend := os.Getenv("ASSEMBLY_BACKEND"); backend != "" {
+		config.Assembly.Backend = backend
+	}
+
+	if ffmpegPath := os.Getenv("FFMPEG_PATH"); ffmpegPath != "" {
+		config.VideoConversion.FFMPEGPath = ffmpegPath
+		config.Assembly.FFMPEGPath = ffmpegPath
+	}
+
+	if fallback := os.Getenv("MOCK_FALLBACK"); fallback != "" {
+		config.MockFallback = fallback == "true"
+	}
+
+	// The older switches select their backend unless another is named
+	if config.ContentExtraction.Backend == "" {
+		switch {
+		case len(config.ContentExtraction.Sources) > 0:
+			config.ContentExtraction.Backend = "aggregator"
+		case config.ContentExtraction.ScreenshotDir != "":
+			config.ContentExtraction.Backend = "screenshots"
+		case config.ContentExtraction.PageDir != "":
+			config.ContentExtraction.Backend = "pages"
+		}
+	}
+	if config.VideoConversion.UseNodeImplementation && config.VideoConversion.Backend == "" {
+		config.VideoConversion.Backend = "node"
+	}
+	if config.LyricCreation.Offline && config.LyricCreation.Backend == "" {
+		config.LyricCreation.Backend = "mock"
+	}
+
 	if outputDir := os.Getenv("OUTPUT_DIR"); outputDir != "" {
 		config.OutputDir = outputDir
 		config.ImageCreation.OutputDir = filepath.Join(outputDir, "images")
 		config.VideoConversion.OutputDir = filepath.Join(outputDir, "videos")
 		config.MusicGeneration.OutputDir = filepath.Join(outputDir, "music")
+		config.LyricCreation.ArchiveDir = filepath.Join(outputDir, "lyrics")
 		config.Assembly.OutputDir = filepath.Join(outputDir, "final")
 	}
 
@@ -172,6 +447,17 @@ func Load() (Config, error) {
 	return config, nil
 }
 
+// splitList splits a comma-separated environment value into trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // LoadForTest loads the configuration for testing, ensuring .env is loaded
 func LoadForTest() (Config, error) {
 	// Try to load from project root and test directory
@@ -0,0 +1,325 @@
+/*
+Package llm provides the Claude client shared by the Stitch-Up pipeline stages.
+
+It wraps the Anthropic Messages API so that every stage that needs Claude
+(content extraction from screenshots, scene generation and lyric creation)
+builds its requests the same way.
+*/
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iantozer/stitch-up/pkg/transport"
+)
+
+const (
+	// DefaultBaseURL is the Anthropic API endpoint
+	DefaultBaseURL = "https://api.anthropic.com"
+
+	// DefaultModel is the Claude model used when none is configured
+	DefaultModel = "claude-sonnet-4-5-20250929"
+
+	// DefaultMaxTokens is the response token limit used when none is configured
+	DefaultMaxTokens = 4000
+
+	// apiVersion is the Messages API version we speak
+	apiVersion = "2023-06-01"
+)
+
+// Client sends requests to Claude's Messages API
+type Client struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	MaxTokens  int
+	HTTPClient *http.Client
+
+	// Token usage of every request sent by the client
+	mu    sync.Mutex
+	usage Usage
+}
+
+// Options configures a new client; zero values use the defaults
+type Options struct {
+	APIKey    string
+	BaseURL   string
+	Model     string
+	MaxTokens int
+}
+
+// NewClient creates a new Claude client with default settings
+func NewClient(apiKey string) *Client {
+	return New(Options{APIKey: apiKey})
+}
+
+// New creates a new Claude client from the options
+func New(options Options) *Client {
+	client := &Client{
+		APIKey:     options.APIKey,
+		BaseURL:    options.BaseURL,
+		Model:      options.Model,
+		MaxTokens:  options.MaxTokens,
+		HTTPClient: transport.NewClient(transport.Anthropic, 60*time.Second),
+	}
+
+	if client.BaseURL == "" {
+		client.BaseURL = DefaultBaseURL
+	}
+	if client.Model == "" {
+		client.Model = DefaultModel
+	}
+	if client.MaxTokens <= 0 {
+		client.MaxTokens = DefaultMaxTokens
+	}
+
+	return client
+}
+
+// Message is a single turn of a conversation
+type Message struct {
+	Role    string         `json:"role"`
+	Content []ContentBlock `json:"content"`
+}
+
+// ContentBlock is a single piece of message content: text, an image, a tool call or a tool result
+type ContentBlock struct {
+	Type   string       `json:"type"`
+	Text   string       `json:"text,omitempty"`
+	Source *ImageSource `json:"source,omitempty"`
+
+	// Tool calls made by Claude
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// Results sent back for a tool call
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+// ImageSource holds the data of an image content block
+type ImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// Tool describes a tool Claude may call, with a JSON schema for its input
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// ToolChoice controls whether and which tool Claude must call
+type ToolChoice struct {
+	Type string `json:"type"` // "auto", "any" or "tool"
+	Name string `json:"name,omitempty"`
+}
+
+// Request is a Messages API request
+type Request struct {
+	Model      string      `json:"model"`
+	MaxTokens  int         `json:"max_tokens"`
+	System     string      `json:"system,omitempty"`
+	Messages   []Message   `json:"messages"`
+	Tools      []Tool      `json:"tools,omitempty"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+}
+
+// Response is a Messages API response
+type Response struct {
+	ID         string         `json:"id"`
+	Model      string         `json:"model"`
+	Role       string         `json:"role"`
+	StopReason string         `json:"stop_reason"`
+	Content    []ContentBlock `json:"content"`
+	Usage      Usage          `json:"usage"`
+}
+
+// Usage counts the tokens billed for one or more requests
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+// Add returns the sum of two usages
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		InputTokens:              u.InputTokens + other.InputTokens,
+		OutputTokens:             u.OutputTokens + other.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens + other.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens + other.CacheReadInputTokens,
+	}
+}
+
+// String formats the usage for logging
+func (u Usage) String() string {
+	return fmt.Sprintf("%d input tokens, %d output tokens", u.InputTokens+u.CacheCreationInputTokens+u.CacheReadInputTokens, u.OutputTokens)
+}
+
+// APIError is an error response from the Messages API
+type APIError struct {
+	StatusCode int
+	Type       string // e.g. "invalid_request_error", "rate_limit_error", "overloaded_error"
+	Message    string
+	RequestID  string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("unexpected status code: %d, body: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("claude API error (status %d, %s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Text returns the text of all the text blocks in the response
+func (r Response) Text() string {
+	var parts []string
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// ToolUse returns the first call to the named tool in the response
+func (r Response) ToolUse(name string) (ContentBlock, bool) {
+	for _, block := range r.Content {
+		if block.Type == "tool_use" && block.Name == name {
+			return block, true
+		}
+	}
+	return ContentBlock{}, false
+}
+
+// TextBlock creates a text content block
+func TextBlock(text string) ContentBlock {
+	return ContentBlock{Type: "text", Text: text}
+}
+
+// ImageBlock creates an image content block from base64-encoded data
+func ImageBlock(mediaType, base64Data string) ContentBlock {
+	return ContentBlock{
+		Type: "image",
+		Source: &ImageSource{
+			Type:      "base64",
+			MediaType: mediaType,
+			Data:      base64Data,
+		},
+	}
+}
+
+// ToolResultBlock creates the result of a tool call, flagged as an error when the call must be retried
+func ToolResultBlock(toolUseID, content string, isError bool) ContentBlock {
+	return ContentBlock{
+		Type:      "tool_result",
+		ToolUseID: toolUseID,
+		Content:   content,
+		IsError:   isError,
+	}
+}
+
+// ImageMediaType returns the media type Claude expects for raw image data
+func ImageMediaType(data []byte) string {
+	switch contentType := http.DetectContentType(data); contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return contentType
+	default:
+		return "image/png"
+	}
+}
+
+// Complete sends a single user message made of the prompt and any images, and returns Claude's text reply
+func (c *Client) Complete(ctx context.Context, prompt string, images ...ContentBlock) (string, error) {
+	response, err := c.Send(ctx, Request{
+		Messages: []Message{
+			{Role: "user", Content: append([]ContentBlock{TextBlock(prompt)}, images...)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Join the text of every text block
+	text := response.Text()
+	if text == "" {
+		return "", fmt.Errorf("invalid response format: no text content")
+	}
+
+	return text, nil
+}
+
+// Usage returns the tokens used by every request the client has sent
+func (c *Client) Usage() Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usage
+}
+
+// Send sends a request to the Messages API, filling in the client's model and token limit when unset
+func (c *Client) Send(ctx context.Context, request Request) (Response, error) {
+	if request.Model == "" {
+		request.Model = c.Model
+	}
+	if request.MaxTokens == 0 {
+		request.MaxTokens = c.MaxTokens
+	}
+
+	header := http.Header{
+		"X-Api-Key":         {c.APIKey},
+		"Anthropic-Version": {apiVersion},
+	}
+
+	var response Response
+	err := transport.DoJSON(ctx, c.HTTPClient, http.MethodPost, strings.TrimSuffix(c.BaseURL, "/")+"/v1/messages", header, request, &response)
+
+	var statusErr *transport.StatusError
+	if errors.As(err, &statusErr) {
+		return Response{}, parseAPIError(statusErr)
+	}
+	if err != nil {
+		return Response{}, err
+	}
+
+	c.mu.Lock()
+	c.usage = c.usage.Add(response.Usage)
+	c.mu.Unlock()
+
+	return response, nil
+}
+
+// parseAPIError builds an APIError from an error response, keeping the raw body when it is not the API's error JSON
+func parseAPIError(statusErr *transport.StatusError) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusErr.StatusCode,
+		RequestID:  statusErr.Header.Get("request-id"),
+		Message:    string(statusErr.Body),
+	}
+
+	var errorBody struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(statusErr.Body, &errorBody); err == nil && errorBody.Error.Type != "" {
+		apiErr.Type = errorBody.Error.Type
+		apiErr.Message = errorBody.Error.Message
+	}
+
+	return apiErr
+}
@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Send(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != apiVersion {
+			t.Errorf("missing headers: %v", r.Header)
+		}
+
+		var request Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Model != "claude-test" || request.MaxTokens != DefaultMaxTokens {
+			t.Errorf("request model = %q, max tokens = %d", request.Model, request.MaxTokens)
+		}
+		if len(request.Messages) != 1 || len(request.Messages[0].Content) != 2 || request.Messages[0].Content[1].Source.MediaType != "image/png" {
+			t.Errorf("unexpected messages: %+v", request.Messages)
+		}
+
+		w.Write([]byte(`{
+  "id": "msg_1",
+  "model": "claude-test",
+  "role": "assistant",
+  "stop_reason": "end_turn",
+  "content": [{"type": "text", "text": "Hello, "}, {"type": "tool_use", "id": "toolu_1", "name": "noop", "input": {}}, {"type": "text", "text": "world"}],
+  "usage": {"input_tokens": 12, "output_tokens": 5, "cache_read_input_tokens": 3}
+}`))
+	}))
+	defer server.Close()
+
+	client := New(Options{APIKey: "test-key", BaseURL: server.URL + "/", Model: "claude-test"})
+
+	text, err := client.Complete(context.Background(), "Say hello", ImageBlock("image/png", "aGVsbG8="))
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	// Text blocks either side of a tool call are joined
+	if text != "Hello, world" {
+		t.Errorf("Complete() = %q", text)
+	}
+
+	// Usage accumulates across requests
+	client.Complete(context.Background(), "Say hello again", ImageBlock("image/png", "aGVsbG8="))
+	if usage := client.Usage(); usage.InputTokens != 24 || usage.OutputTokens != 10 || usage.CacheReadInputTokens != 6 {
+		t.Errorf("Usage() = %+v", usage)
+	}
+}
+
+func TestClient_Send_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("request-id", "req_123")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type": "error", "error": {"type": "rate_limit_error", "message": "Number of requests has exceeded your rate limit"}}`))
+	}))
+	defer server.Close()
+
+	// Retries are the transport's concern, so send once
+	client := New(Options{APIKey: "test-key", BaseURL: server.URL})
+	client.HTTPClient = server.Client()
+
+	_, err := client.Send(context.Background(), Request{Messages: []Message{{Role: "user", Content: []ContentBlock{TextBlock("Hi")}}}})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Send() error = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Type != "rate_limit_error" || apiErr.RequestID != "req_123" {
+		t.Errorf("unexpected API error: %+v", apiErr)
+	}
+	if apiErr.Message != "Number of requests has exceeded your rate limit" {
+		t.Errorf("Message = %q", apiErr.Message)
+	}
+}
+
+func TestClient_Send_NonJSONError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := New(Options{BaseURL: server.URL})
+	client.HTTPClient = server.Client()
+
+	_, err := client.Complete(context.Background(), "Hi")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Type != "" {
+		t.Fatalf("Complete() error = %v, want untyped *APIError", err)
+	}
+}
+
+func TestNew_Defaults(t *testing.T) {
+	client := New(Options{APIKey: "key"})
+
+	if client.BaseURL != DefaultBaseURL || client.Model != DefaultModel || client.MaxTokens != DefaultMaxTokens {
+		t.Errorf("New() = %+v, want defaults", client)
+	}
+}
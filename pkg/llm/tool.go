@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// CallTool forces Claude to call the tool and passes its input to accept. When accept rejects
+// the input, the error is sent back as the tool result and Claude is asked again, until the
+// input is accepted or maxAttempts requests have been made.
+func (c *Client) CallTool(ctx context.Context, request Request, tool Tool, maxAttempts int, accept func(input json.RawMessage) error) error {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	request.Tools = []Tool{tool}
+	request.ToolChoice = &ToolChoice{Type: "tool", Name: tool.Name}
+	messages := append([]Message(nil), request.Messages...)
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		request.Messages = messages
+		response, err := c.Send(ctx, request)
+		if err != nil {
+			return err
+		}
+
+		call, ok := response.ToolUse(tool.Name)
+		if !ok {
+			// Without a tool call there is nothing to attach a tool result to
+			lastErr = fmt.Errorf("response did not call %s", tool.Name)
+			messages = append(messages,
+				Message{Role: "assistant", Content: response.Content},
+				Message{Role: "user", Content: []ContentBlock{TextBlock("Please call the " + tool.Name + " tool.")}},
+			)
+			continue
+		}
+
+		err = accept(call.Input)
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("%s attempt %d/%d rejected: %v", tool.Name, attempt, maxAttempts, err)
+		lastErr = err
+
+		// Send the problems back so Claude can correct them
+		messages = append(messages,
+			Message{Role: "assistant", Content: response.Content},
+			Message{Role: "user", Content: []ContentBlock{
+				ToolResultBlock(call.ID, "The input is invalid: "+err.Error()+". Call "+tool.Name+" again with corrected values.", true),
+			}},
+		)
+	}
+
+	return fmt.Errorf("no valid %s call after %d attempts: %w", tool.Name, maxAttempts, lastErr)
+}
@@ -0,0 +1,139 @@
+/*
+Package prompts loads the text templates used to build the prompts sent to the
+generative models by the Stitch-Up pipeline stages.
+
+Every prompt is a text/template file named after the prompt, e.g.
+"scene_article.tmpl". The defaults are embedded in the binary; a prompt
+directory given in the configuration can override any of them, so prompts
+can be edited between runs without rebuilding.
+*/
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode/utf8"
+)
+
+// Names of the prompts used by the pipeline
+const (
+	// SceneImage asks Claude for a scene from a headline screenshot
+	SceneImage = "scene_image"
+
+	// SceneArticle asks Claude for a scene from an extracted article
+	SceneArticle = "scene_article"
+
+	// Screenshot asks Claude for the stories in a news screenshot
+	Screenshot = "screenshot"
+
+	// Image is the text-to-image prompt for a scene
+	Image = "image"
+
+	// Lyrics asks Claude for a song about the day's content
+	Lyrics = "lyrics"
+)
+
+// templateExt is the file extension of prompt templates
+const templateExt = ".tmpl"
+
+//go:embed templates/*.tmpl
+var defaults embed.FS
+
+// funcs are the helper functions available to every template
+var funcs = template.FuncMap{
+	"add":      func(a, b int) int { return a + b },
+	"contains": strings.Contains,
+	"join":     strings.Join,
+	"lower":    strings.ToLower,
+	"truncate": truncate,
+}
+
+// Set is a collection of prompt templates, loaded on first use
+type Set struct {
+	dir string
+
+	once      sync.Once
+	templates *template.Template
+	err       error
+}
+
+// New creates a prompt set from the embedded defaults, overridden by any templates in dir
+func New(dir string) *Set {
+	return &Set{dir: dir}
+}
+
+// Render executes the named prompt with data and returns the prompt text
+func (s *Set) Render(name string, data interface{}) (string, error) {
+	s.once.Do(func() {
+		s.templates, s.err = s.load()
+	})
+	if s.err != nil {
+		return "", s.err
+	}
+
+	tmpl := s.templates.Lookup(name + templateExt)
+	if tmpl == nil {
+		return "", fmt.Errorf("unknown prompt: %s", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// load parses the embedded templates and then the overrides, which replace defaults of the same name
+func (s *Set) load() (*template.Template, error) {
+	templates, err := template.New("").Funcs(funcs).ParseFS(defaults, "templates/*"+templateExt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse default prompts: %w", err)
+	}
+
+	if s.dir == "" {
+		return templates, nil
+	}
+
+	if _, err := os.Stat(s.dir); err != nil {
+		return nil, fmt.Errorf("failed to read prompt directory: %w", err)
+	}
+
+	overrides, err := filepath.Glob(filepath.Join(s.dir, "*"+templateExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+
+	for _, path := range overrides {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt %s: %w", path, err)
+		}
+		if _, err := templates.New(filepath.Base(path)).Parse(string(text)); err != nil {
+			return nil, fmt.Errorf("failed to parse prompt %s: %w", path, err)
+		}
+	}
+
+	return templates, nil
+}
+
+// truncate shortens s to at most n bytes, cutting at a word boundary, or failing that at a
+// character boundary so a multi-byte character is never split
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	if cut := strings.LastIndex(s[:n], " "); cut > 0 {
+		n = cut
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,129 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSet_Render_Defaults(t *testing.T) {
+	set := New("")
+
+	data := struct {
+		Title, Summary, Content, Tool string
+	}{
+		Title:   "Bank holds rates",
+		Content: strings.Repeat("The decision was widely expected. ", 200),
+		Tool:    "record_scene",
+	}
+
+	prompt, err := set.Render(SceneArticle, data)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if !strings.Contains(prompt, "Headline: Bank holds rates\nStory:\n") {
+		t.Errorf("prompt is missing the article, got:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "Summary:") {
+		t.Errorf("prompt includes an empty summary")
+	}
+	if !strings.Contains(prompt, "...") || len(prompt) > 6000 {
+		t.Errorf("story was not truncated, prompt has %d bytes", len(prompt))
+	}
+	if !strings.HasSuffix(prompt, "Record the scene with the record_scene tool.") {
+		t.Errorf("prompt does not name the tool, got:\n%s", prompt)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"cut at a word", 10, "cut at a..."},
+		// Without a space the cut backs off to the start of the character it would split
+		{"naïveté", 3, "na..."},
+		{"日本語", 4, "日..."},
+	}
+
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.n)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q is not valid UTF-8", tt.s, tt.n, got)
+		}
+	}
+}
+
+func TestSet_Render_Image(t *testing.T) {
+	set := New("")
+
+	type scene struct {
+		Description, Mood, Model string
+	}
+
+	prompt, err := set.Render(Image, scene{Description: "A quiet harbour at dawn.", Mood: "calm", Model: "stabilityai/stable-diffusion-xl-base-1.0"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if want := "A quiet harbour at dawn. The mood is calm. Photorealistic"; !strings.HasPrefix(prompt, want) {
+		t.Errorf("Render() = %q, want prefix %q", prompt, want)
+	}
+
+	// Other models get the description alone
+	prompt, err = set.Render(Image, scene{Description: "A quiet harbour at dawn.", Model: "other"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if prompt != "A quiet harbour at dawn." {
+		t.Errorf("Render() = %q", prompt)
+	}
+}
+
+func TestSet_Render_Override(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "image.tmpl"), []byte("{{.Description}}, in the style of a woodcut\n"), 0644); err != nil {
+		t.Fatalf("Failed to write prompt: %v", err)
+	}
+
+	set := New(dir)
+
+	prompt, err := set.Render(Image, struct{ Description string }{"A quiet harbour"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if prompt != "A quiet harbour, in the style of a woodcut" {
+		t.Errorf("Render() = %q", prompt)
+	}
+
+	// Prompts without an override keep the default
+	prompt, err = set.Render(Screenshot, struct{ Tool string }{"record_stories"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if !strings.HasPrefix(prompt, "You are a news editor.") {
+		t.Errorf("Render() = %q", prompt)
+	}
+}
+
+func TestSet_Render_Errors(t *testing.T) {
+	if _, err := New("").Render("missing", nil); err == nil {
+		t.Error("Render() of an unknown prompt should return error")
+	}
+
+	if _, err := New(filepath.Join(t.TempDir(), "missing")).Render(Image, nil); err == nil {
+		t.Error("Render() with a missing prompt directory should return error")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "image.tmpl"), []byte("{{.Description"), 0644)
+	if _, err := New(dir).Render(Image, nil); err == nil {
+		t.Error("Render() with a malformed override should return error")
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Package registry lets each stage of the Stitch-Up pipeline offer several backends
+for its work, selected by name from the configuration.
+
+A stage creates a Registry of its backends: the real provider, implementations
+that run locally, mocks for offline runs and the Node.js scripts. A backend's
+constructor returns an error when it cannot run, e.g. without an API key, and a
+mock or local backend is only used in its place when the fallback is enabled.
+*/
+package registry
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Kind describes what a backend runs on
+type Kind string
+
+// Backend kinds
+const (
+	Provider Kind = "provider" // a paid or hosted API
+	Local    Kind = "local"    // runs on this machine, e.g. with ffmpeg
+	Mock     Kind = "mock"     // fixed or placeholder output for offline runs
+	Node     Kind = "node"     // the Node.js scripts
+)
+
+// Backend is a named implementation of a stage, created from the stage's configuration
+type Backend[C, T any] struct {
+	Name        string
+	Kind        Kind
+	Description string
+	New         func(config C) (T, error)
+}
+
+// Info describes a backend for listing
+type Info struct {
+	Name        string
+	Kind        Kind
+	Description string
+}
+
+// Lister is a registry of any stage, as listed by Write
+type Lister interface {
+	Stage() string
+	Default() string
+	Describe() []Info
+}
+
+// Registry holds the backends of a stage
+type Registry[C, T any] struct {
+	stage       string
+	defaultName string
+	backends    map[string]Backend[C, T]
+	order       []string // registration order, which fallbacks are tried in
+}
+
+// New creates a registry for a stage, whose backend is defaultName unless another is configured
+func New[C, T any](stage, defaultName string, backends ...Backend[C, T]) *Registry[C, T] {
+	r := &Registry[C, T]{
+		stage:       stage,
+		defaultName: defaultName,
+		backends:    make(map[string]Backend[C, T]),
+	}
+	for _, backend := range backends {
+		r.Register(backend)
+	}
+	return r
+}
+
+// Register adds a backend, panicking if its name is already taken
+func (r *Registry[C, T]) Register(backend Backend[C, T]) {
+	if _, ok := r.backends[backend.Name]; ok {
+		panic(fmt.Sprintf("registry: %s backend %q registered twice", r.stage, backend.Name))
+	}
+	r.backends[backend.Name] = backend
+	r.order = append(r.order, backend.Name)
+}
+
+// Stage returns the name of the stage
+func (r *Registry[C, T]) Stage() string {
+	return r.stage
+}
+
+// Default returns the name of the backend used when none is configured
+func (r *Registry[C, T]) Default() string {
+	return r.defaultName
+}
+
+// Backends returns the registered backends sorted by name
+func (r *Registry[C, T]) Backends() []Backend[C, T] {
+	backends := make([]Backend[C, T], 0, len(r.backends))
+	for _, backend := range r.backends {
+		backends = append(backends, backend)
+	}
+	sort.Slice(backends, func(i, j int) bool { return backends[i].Name < backends[j].Name })
+	return backends
+}
+
+// Describe returns the registered backends sorted by name, for listing
+func (r *Registry[C, T]) Describe() []Info {
+	var infos []Info
+	for _, backend := range r.Backends() {
+		infos = append(infos, Info{Name: backend.Name, Kind: backend.Kind, Description: backend.Description})
+	}
+	return infos
+}
+
+// Lookup returns the backend with the given name, or the default backend for an empty name
+func (r *Registry[C, T]) Lookup(name string) (Backend[C, T], error) {
+	if name == "" {
+		name = r.defaultName
+	}
+	backend, ok := r.backends[name]
+	if !ok {
+		return Backend[C, T]{}, fmt.Errorf("unknown %s backend %q (available: %s)", r.stage, name, strings.Join(r.names(), ", "))
+	}
+	return backend, nil
+}
+
+// New creates the named backend, or the default backend for an empty name. If it cannot be
+// created and fallback is set, the first local backend that can be is used instead, or else
+// the first mock one; otherwise the error says how to choose another backend.
+func (r *Registry[C, T]) New(name string, config C, fallback bool) (T, error) {
+	var zero T
+
+	backend, err := r.Lookup(name)
+	if err != nil {
+		return zero, err
+	}
+
+	impl, err := backend.New(config)
+	if err == nil {
+		return impl, nil
+	}
+	if !fallback {
+		return zero, fmt.Errorf("%s backend %q: %w; choose another backend or enable the mock fallback", r.stage, backend.Name, err)
+	}
+
+	for _, kind := range []Kind{Local, Mock} {
+		for _, name := range r.order {
+			alternative := r.backends[name]
+			if alternative.Kind != kind || name == backend.Name {
+				continue
+			}
+			impl, altErr := alternative.New(config)
+			if altErr != nil {
+				continue
+			}
+			log.Printf("Warning: %s backend %q unavailable (%v), falling back to %q", r.stage, backend.Name, err, name)
+			return impl, nil
+		}
+	}
+
+	return zero, fmt.Errorf("%s backend %q: %w; no local or mock backend to fall back to", r.stage, backend.Name, err)
+}
+
+// names returns the names of the registered backends, sorted
+func (r *Registry[C, T]) names() []string {
+	names := make([]string, 0, len(r.backends))
+	for name := range r.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write lists the backends of each registry, marking every stage's default
+func Write(w io.Writer, registries ...Lister) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tBACKEND\tKIND\tDESCRIPTION")
+	for _, r := range registries {
+		for _, info := range r.Describe() {
+			name := info.Name
+			if name == r.Default() {
+				name += " (default)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Stage(), name, info.Kind, info.Description)
+		}
+	}
+	return tw.Flush()
+}
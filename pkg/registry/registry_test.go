@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// backend returns a test backend that needs a non-empty key unless it is a mock
+func backend(name string, kind Kind) Backend[string, string] {
+	return Backend[string, string]{
+		Name:        name,
+		Kind:        kind,
+		Description: name + " backend",
+		New: func(key string) (string, error) {
+			if key == "" && kind != Mock {
+				return "", fmt.Errorf("no key")
+			}
+			return name, nil
+		},
+	}
+}
+
+func testRegistry() *Registry[string, string] {
+	return New("test", "paid",
+		backend("paid", Provider),
+		backend("fake", Mock),
+		backend("other", Provider),
+	)
+}
+
+func TestRegistry_New(t *testing.T) {
+	r := testRegistry()
+
+	tests := []struct {
+		name     string
+		key      string
+		fallback bool
+		want     string
+		wantErr  string
+	}{
+		{name: "", key: "k", want: "paid"},
+		{name: "other", key: "k", want: "other"},
+		{name: "fake", want: "fake"},
+		{name: "missing", key: "k", wantErr: `unknown test backend "missing" (available: fake, other, paid)`},
+		{name: "paid", wantErr: "no key; choose another backend or enable the mock fallback"},
+		{name: "paid", fallback: true, want: "fake"},
+	}
+
+	for _, tt := range tests {
+		got, err := r.New(tt.name, tt.key, tt.fallback)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New(%q, %q, %v) error = %v, want %q", tt.name, tt.key, tt.fallback, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("New(%q, %q, %v) = %q, %v, want %q", tt.name, tt.key, tt.fallback, got, err, tt.want)
+		}
+	}
+}
+
+func TestRegistry_New_FallbackOrder(t *testing.T) {
+	// A local backend that can run is preferred to a mock
+	r := testRegistry()
+	local := backend("local", Local)
+	local.New = func(string) (string, error) { return "local", nil }
+	r.Register(local)
+
+	if got, err := r.New("", "", true); err != nil || got != "local" {
+		t.Errorf("New() = %q, %v, want the local backend", got, err)
+	}
+
+	// With nothing to fall back to, the original error is kept
+	r = New("test", "paid", backend("paid", Provider), backend("broken", Local))
+	if _, err := r.New("", "", true); err == nil || !strings.Contains(err.Error(), "no key; no local or mock backend") {
+		t.Errorf("New() error = %v, want the backend's own error", err)
+	}
+}
+
+func TestRegistry_Register_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() of a taken name did not panic")
+		}
+	}()
+	testRegistry().Register(backend("paid", Provider))
+}
+
+func TestWrite(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, testRegistry()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Write() = %q, want a header and 3 backends", out.String())
+	}
+	if !strings.HasPrefix(lines[1], "test") || !strings.Contains(lines[1], "fake") || !strings.Contains(lines[1], "mock") {
+		t.Errorf("first backend = %q, want fake sorted first with its kind", lines[1])
+	}
+	if !strings.Contains(lines[3], "paid (default)") {
+		t.Errorf("last backend = %q, want the default marked", lines[3])
+	}
+}
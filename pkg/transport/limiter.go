@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limit is a token-bucket rate limit
+type Limit struct {
+	PerSecond float64 `json:"requests_per_second"` // sustained request rate
+	Burst     int     `json:"burst"`               // requests allowed at once after a quiet period
+}
+
+// defaultLimits are the rate limits applied to each provider unless configured otherwise
+var defaultLimits = map[string]Limit{
+	Anthropic:   {PerSecond: 50.0 / 60, Burst: 5},
+	HuggingFace: {PerSecond: 0.5, Burst: 1},
+	Runway:      {PerSecond: 1, Burst: 2},
+	Suno:        {PerSecond: 1, Burst: 2},
+	Replicate:   {PerSecond: 5, Burst: 10},
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*Limiter{}
+)
+
+// LimiterFor returns the limiter shared by every client of the provider, or nil if the provider is not limited
+func LimiterFor(provider string) *Limiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if limiter, ok := limiters[provider]; ok {
+		return limiter
+	}
+
+	limit, ok := defaultLimits[provider]
+	if !ok {
+		return nil
+	}
+
+	limiter := NewLimiter(limit)
+	limiters[provider] = limiter
+	return limiter
+}
+
+// SetLimit changes the rate limit of a provider for every client, existing and new
+func SetLimit(provider string, limit Limit) {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if limiter, ok := limiters[provider]; ok {
+		limiter.SetLimit(limit)
+		return
+	}
+	limiters[provider] = NewLimiter(limit)
+}
+
+// Limiter is a token bucket that spaces out requests
+type Limiter struct {
+	mu     sync.Mutex
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a limiter with a full bucket
+func NewLimiter(limit Limit) *Limiter {
+	limit = normalize(limit)
+	return &Limiter{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// SetLimit changes the limiter's rate and burst
+func (l *Limiter) SetLimit(limit Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	l.limit = normalize(limit)
+	if l.tokens > float64(l.limit.Burst) {
+		l.tokens = float64(l.limit.Burst)
+	}
+}
+
+// Wait blocks until a request may be sent or the context is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.limit.PerSecond <= 0 {
+			l.mu.Unlock()
+			return nil
+		}
+
+		now := time.Now()
+		l.refill(now)
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.limit.PerSecond * float64(time.Second))
+		l.mu.Unlock()
+
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// refill adds the tokens earned since the last refill; the caller holds the lock
+func (l *Limiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.limit.PerSecond
+	if l.tokens > float64(l.limit.Burst) {
+		l.tokens = float64(l.limit.Burst)
+	}
+	l.last = now
+}
+
+// normalize makes sure a limit lets at least one request through
+func normalize(limit Limit) Limit {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return limit
+}
@@ -0,0 +1,311 @@
+/*
+Package transport provides the HTTP client used for every outbound call made by
+the Stitch-Up pipeline stages.
+
+Requests are rate limited per provider with a token bucket, and requests that
+are throttled (429), fail on the server (5xx) or hit a model that is still
+loading on Hugging Face are retried with exponential backoff and jitter,
+honouring any Retry-After header sent by the provider. A request that is not
+safe to repeat, such as a POST starting a paid job, is only retried when the
+provider says it was not processed: throttled, or unavailable with a time to
+come back. Sending an Idempotency-Key header makes any request safe to repeat.
+
+The package also holds what the provider clients share on top of the client:
+sending a JSON request and decoding its response, and downloading a result to
+a file without leaving a partial file behind when the download fails.
+*/
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Providers with their own rate limits
+const (
+	Anthropic   = "anthropic"
+	HuggingFace = "huggingface"
+	Runway      = "runway"
+	Suno        = "suno"
+	Replicate   = "replicate"
+)
+
+const (
+	// DefaultMaxRetries is how many times a request is retried before its last response is returned
+	DefaultMaxRetries = 4
+
+	// DefaultBaseDelay is the backoff before the first retry, doubled for every retry after it
+	DefaultBaseDelay = time.Second
+
+	// DefaultMaxDelay caps the exponential backoff
+	DefaultMaxDelay = 30 * time.Second
+
+	// DefaultMaxWait caps waits requested by the provider through Retry-After or estimated_time
+	DefaultMaxWait = 2 * time.Minute
+)
+
+// Transport is an http.RoundTripper that rate limits requests and retries the ones that fail
+type Transport struct {
+	Base           http.RoundTripper // nil uses http.DefaultTransport
+	Limiter        *Limiter          // nil applies no rate limit
+	AttemptTimeout time.Duration     // limit on each attempt, including reading its body; 0 for none
+	MaxRetries     int
+	BaseDelay      time.Duration
+	MaxDelay       time.Duration
+	MaxWait        time.Duration
+
+	// sleep waits between attempts; replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient creates an HTTP client for the provider, each attempt of a request limited to timeout
+func NewClient(provider string, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &Transport{
+			Limiter:        LimiterFor(provider),
+			AttemptTimeout: timeout,
+			MaxRetries:     DefaultMaxRetries,
+			BaseDelay:      DefaultBaseDelay,
+			MaxDelay:       DefaultMaxDelay,
+			MaxWait:        DefaultMaxWait,
+		},
+	}
+}
+
+// RoundTrip sends the request, waiting for the rate limit and retrying throttled and failed attempts
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		if t.Limiter != nil {
+			if err := t.Limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		attemptReq, err := t.prepare(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.send(attemptReq)
+
+		wait, retry := t.retryAfter(req, resp, err)
+		replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if !retry || !replayable || attempt >= t.MaxRetries {
+			return resp, err
+		}
+
+		if wait == 0 {
+			wait = t.backoff(attempt)
+		}
+
+		if err != nil {
+			log.Printf("Retrying %s %s in %s after error: %v (retry %d/%d)", req.Method, req.URL.Redacted(), wait, err, attempt+1, t.MaxRetries)
+		} else {
+			log.Printf("Retrying %s %s in %s after status %d (retry %d/%d)", req.Method, req.URL.Redacted(), wait, resp.StatusCode, attempt+1, t.MaxRetries)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := t.wait(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// prepare returns the request for an attempt, with a fresh copy of the body for retries
+func (t *Transport) prepare(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewind request body: %w", err)
+	}
+
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+// send makes a single attempt, bounding it by the attempt timeout until its body is closed
+func (t *Transport) send(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if t.AttemptTimeout <= 0 {
+		return base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.AttemptTimeout)
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retryAfter reports whether an attempt should be retried, and how long the provider asked us to wait
+func (t *Transport) retryAfter(req *http.Request, resp *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		// Only requests that are safe to repeat are retried after a network error,
+		// and never once the caller has given up
+		if req.Context().Err() != nil {
+			return 0, false
+		}
+		return 0, idempotent(req)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529: // 529 is Anthropic's "overloaded"
+	default:
+		return 0, false
+	}
+
+	if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		// A throttled request, or one turned away until a given time, was not processed
+		if idempotent(req) || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			return t.capWait(wait), true
+		}
+		return 0, false
+	}
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		if wait, ok := estimatedTime(resp); ok {
+			// Hugging Face turns requests away while the model loads
+			return t.capWait(wait), true
+		}
+	}
+
+	// Other server errors may have happened after the request was processed, so repeating
+	// it could start a second paid job
+	return 0, idempotent(req) || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// idempotent reports whether a request can be repeated without doing its work twice
+func idempotent(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead || req.Header.Get("Idempotency-Key") != ""
+}
+
+// backoff returns a random delay up to the exponential backoff for the attempt ("full jitter")
+func (t *Transport) backoff(attempt int) time.Duration {
+	delay := t.BaseDelay
+	if delay <= 0 {
+		delay = DefaultBaseDelay
+	}
+	maxDelay := t.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxDelay
+	}
+
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// capWait limits a wait requested by the provider
+func (t *Transport) capWait(wait time.Duration) time.Duration {
+	maxWait := t.MaxWait
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWait
+	}
+	if wait > maxWait {
+		return maxWait
+	}
+	return wait
+}
+
+// wait sleeps for d or until the context is done
+func (t *Transport) wait(ctx context.Context, d time.Duration) error {
+	if t.sleep != nil {
+		return t.sleep(ctx, d)
+	}
+	return sleep(ctx, d)
+}
+
+// sleep waits for d or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// estimatedTime reads how long Hugging Face expects a loading model to take from a 503 response,
+// leaving the body readable for the caller
+func estimatedTime(resp *http.Response) (time.Duration, bool) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0, false
+	}
+
+	var loading struct {
+		EstimatedTime float64 `json:"estimated_time"`
+	}
+	if err := json.Unmarshal(body, &loading); err != nil || loading.EstimatedTime <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(loading.EstimatedTime * float64(time.Second)), true
+}
+
+// cancelBody releases an attempt's timeout once its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the attempt's context
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
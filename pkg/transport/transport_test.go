@@ -0,0 +1,316 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTransport returns a transport that records its waits instead of sleeping
+func newTestTransport(waits *[]time.Duration) *Transport {
+	return &Transport{
+		MaxRetries: 3,
+		BaseDelay:  time.Second,
+		MaxDelay:   4 * time.Second,
+		MaxWait:    time.Minute,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			*waits = append(*waits, d)
+			return ctx.Err()
+		},
+	}
+}
+
+func TestTransport_RetriesThrottledRequests(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		switch len(bodies) {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(&waits)}
+
+	// The idempotency key makes the POST safe to repeat after a server error
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+	req.Header.Set("Idempotency-Key", "request-1")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+
+	// Every attempt sends the whole body again
+	if len(bodies) != 3 || bodies[2] != "payload" {
+		t.Errorf("server saw bodies %q", bodies)
+	}
+
+	// Retry-After is honoured, then the backoff for the second retry is jittered below twice the base delay
+	if len(waits) != 2 || waits[0] != 7*time.Second || waits[1] <= 0 || waits[1] > 2*time.Second {
+		t.Errorf("waits = %v", waits)
+	}
+}
+
+func TestTransport_DoesNotRepeatPostAfterServerError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(&waits)}
+
+	// The job may have been started before the error, so it is not submitted again
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"model": "gen3a_turbo"}`))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if calls != 1 || len(waits) != 0 || resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("made %d calls with waits %v, want the POST sent exactly once", calls, waits)
+	}
+}
+
+func TestTransport_RetriesPostWhenNotProcessed(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(&waits)}
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if calls != 3 || resp.StatusCode != http.StatusOK {
+		t.Errorf("made %d calls, status %d, want the throttled and unavailable attempts retried", calls, resp.StatusCode)
+	}
+	if len(waits) != 2 || waits[1] != 3*time.Second {
+		t.Errorf("waits = %v", waits)
+	}
+}
+
+func TestTransport_HuggingFaceModelLoading(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error": "Model stabilityai/sdxl is currently loading", "estimated_time": 20.5}`))
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(&waits)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The last response is returned once the retries run out, with its body intact
+	if calls != 4 || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("made %d calls, status %d", calls, resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "currently loading") {
+		t.Errorf("body = %q", body)
+	}
+
+	for _, wait := range waits {
+		if wait != 20500*time.Millisecond {
+			t.Errorf("waits = %v, want the estimated time", waits)
+			break
+		}
+	}
+}
+
+func TestTransport_DoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(&waits)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if calls != 1 || len(waits) != 0 {
+		t.Errorf("made %d calls with waits %v, want a single call", calls, waits)
+	}
+}
+
+func TestTransport_Backoff(t *testing.T) {
+	tr := &Transport{BaseDelay: time.Second, MaxDelay: 4 * time.Second}
+
+	for attempt, limit := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := tr.backoff(attempt); d <= 0 || d > limit {
+				t.Fatalf("backoff(%d) = %v, want (0, %v]", attempt, d, limit)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 3, 12, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"0.5", 500 * time.Millisecond, true},
+		{"Wed, 12 Mar 2025 10:00:30 GMT", 30 * time.Second, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	limiter := NewLimiter(Limit{PerSecond: 20, Burst: 2})
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+
+	// The burst passes at once, the other two wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("4 requests took %v, want at least 100ms", elapsed)
+	}
+
+	// A cancelled context stops the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewLimiter(Limit{PerSecond: 0.001, Burst: 1}).Wait(ctx); err != nil {
+		t.Errorf("first Wait() error = %v, want the burst token", err)
+	}
+	slow := NewLimiter(Limit{PerSecond: 0.001, Burst: 1})
+	slow.Wait(context.Background())
+	if err := slow.Wait(ctx); err != context.Canceled {
+		t.Errorf("Wait() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestDoJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "missing headers", http.StatusUnauthorized)
+			return
+		}
+		var request struct{ Name string }
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Name == "bad" {
+			http.Error(w, `{"error":"bad name"}`, http.StatusUnprocessableEntity)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"greeting": "hello " + request.Name})
+	}))
+	defer server.Close()
+
+	header := http.Header{"Authorization": {"Bearer key"}}
+
+	var out struct{ Greeting string }
+	if err := DoJSON(context.Background(), server.Client(), http.MethodPost, server.URL, header, map[string]string{"name": "world"}, &out); err != nil {
+		t.Fatalf("DoJSON() error = %v", err)
+	}
+	if out.Greeting != "hello world" {
+		t.Errorf("DoJSON() decoded %+v, want the greeting", out)
+	}
+
+	// Failures carry the status and body for the caller to describe
+	err := DoJSON(context.Background(), server.Client(), http.MethodPost, server.URL, header, map[string]string{"name": "bad"}, &out)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(string(statusErr.Body), "bad name") {
+		t.Errorf("DoJSON() error = %v, want a StatusError with the body", err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("video data"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "videos", "clip.mp4")
+	if err := Download(context.Background(), server.Client(), server.URL+"/clip", path); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "video data" {
+		t.Errorf("downloaded %q, %v, want the file", data, err)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	// A failed download leaves nothing behind
+	missing := filepath.Join(filepath.Dir(path), "missing.mp4")
+	if err := Download(context.Background(), server.Client(), server.URL+"/missing", missing); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Download() error = %v, want the status", err)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("failed download left %s behind", missing)
+	}
+}